@@ -0,0 +1,118 @@
+package GeoTiff
+
+import (
+	"container/list"
+	"sync"
+)
+
+// blockKey is the block of an IFD of a file
+type blockKey struct {
+	file  string
+	ifd   int64
+	index int
+}
+
+type blockCacheItem struct {
+	key  blockKey
+	data []float64
+}
+
+// BlockCache is a LRU of the decoded blocks, it is safe for concurrent use and can be shared by many GeoTif
+// with WithBlockCache, the size is counted by the bytes of the decoded values
+type BlockCache struct {
+	mu        sync.Mutex
+	maxBytes  int64
+	bytes     int64
+	order     *list.List
+	items     map[blockKey]*list.Element
+	hits      uint64
+	misses    uint64
+	evictions uint64
+}
+
+// BlockCacheStats is the metrics of a BlockCache
+type BlockCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Blocks    int
+	Bytes     int64
+}
+
+// HitRate is Hits / (Hits + Misses), 0 when the cache is not used
+func (s BlockCacheStats) HitRate() float64 {
+	if s.Hits+s.Misses == 0 {
+		return 0
+	}
+	return float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// NewBlockCache keep the blocks up to maxBytes
+func NewBlockCache(maxBytes int64) *BlockCache {
+	return &BlockCache{maxBytes: maxBytes, order: list.New(), items: map[blockKey]*list.Element{}}
+}
+
+// get return the values of the block, they are shared and should not be modified
+func (bc *BlockCache) get(key blockKey) ([]float64, bool) {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	e, ok := bc.items[key]
+	if !ok {
+		bc.misses++
+		return nil, false
+	}
+	bc.hits++
+	bc.order.MoveToFront(e)
+	return e.Value.(*blockCacheItem).data, true
+}
+
+// put keep the values of the block, they should not be modified later
+// the block which is larger than the cache is not kept
+func (bc *BlockCache) put(key blockKey, data []float64) {
+	size := int64(len(data)) * 8
+	if size > bc.maxBytes {
+		return
+	}
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	if e, ok := bc.items[key]; ok {
+		item := e.Value.(*blockCacheItem)
+		bc.bytes += size - int64(len(item.data))*8
+		item.data = data
+		bc.order.MoveToFront(e)
+	} else {
+		bc.items[key] = bc.order.PushFront(&blockCacheItem{key: key, data: data})
+		bc.bytes += size
+	}
+	for bc.bytes > bc.maxBytes {
+		e := bc.order.Back()
+		item := e.Value.(*blockCacheItem)
+		bc.order.Remove(e)
+		delete(bc.items, item.key)
+		bc.bytes -= int64(len(item.data)) * 8
+		bc.evictions++
+	}
+}
+
+// Stats return the metrics since the cache is created or purged
+func (bc *BlockCache) Stats() BlockCacheStats {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	return BlockCacheStats{
+		Hits:      bc.hits,
+		Misses:    bc.misses,
+		Evictions: bc.evictions,
+		Blocks:    bc.order.Len(),
+		Bytes:     bc.bytes,
+	}
+}
+
+// Purge drop all blocks and reset the metrics
+func (bc *BlockCache) Purge() {
+	bc.mu.Lock()
+	defer bc.mu.Unlock()
+	bc.order.Init()
+	bc.items = map[blockKey]*list.Element{}
+	bc.bytes = 0
+	bc.hits, bc.misses, bc.evictions = 0, 0, 0
+}
@@ -0,0 +1,639 @@
+package GeoTiff
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"runtime"
+	"sync"
+)
+
+// memoryBlockRows is the height of the blocks of a raster which is not read from a file
+const memoryBlockRows = 256
+
+// blockLayout is how the pixels are stored in tiles or strips
+// the strips are the tiles which are as wide as the image
+type blockLayout struct {
+	width, height           int
+	blockWidth, blockHeight int
+	blocksAcross            int
+	blocksDown              int
+	offsets, counts         []uint
+	compression             CompressionType
+	predictor               uint
+	tiled                   bool
+	// orientation of the file, the layout is of the pixels of the file
+	orientation orientation
+}
+
+func (bl *blockLayout) count() int {
+	return bl.blocksAcross * bl.blocksDown
+}
+
+// window return the pixels [x0, x0+w) x [y0, y0+h) of the block, the tiles on the right and bottom are cut to the image
+func (bl *blockLayout) window(index int) (x0, y0, w, h int) {
+	x0 = index % bl.blocksAcross * bl.blockWidth
+	y0 = index / bl.blocksAcross * bl.blockHeight
+	w = minInt(bl.blockWidth, bl.width-x0)
+	h = minInt(bl.blockHeight, bl.height-y0)
+	return
+}
+
+// blockLayout read the layout from the tags, the raster which is not read from a file has strips of memoryBlockRows
+func (g *GeoTif) blockLayout() (*blockLayout, error) {
+	var gEC = NewGeoErrorCreator("GeoTif.blockLayout")
+	bl := &blockLayout{
+		width:       int(g.Meta.Columns),
+		height:      int(g.Meta.Rows),
+		compression: cNone,
+	}
+	bl.blockWidth = bl.width
+	bl.blockHeight = bl.height
+	if g.tFile == nil {
+		bl.blockHeight = minInt(memoryBlockRows, bl.height)
+	} else {
+		bl.orientation = g.Meta.orientation
+		if bl.orientation.transposed() {
+			bl.width, bl.height = bl.height, bl.width
+			bl.blockWidth, bl.blockHeight = bl.width, bl.height
+		}
+		tag := func(tag AttributeTag) []uint {
+			if atr, err := g.GeoTifHeader.Attribute.getAttributeByTag(tag); err == nil {
+				return atr.GeoAttributeValue.uint
+			}
+			return nil
+		}
+		if v := tag(Compression); len(v) > 0 {
+			bl.compression = v[0]
+		}
+		if v := tag(Predictor); len(v) > 0 {
+			bl.predictor = v[0]
+		}
+		if v := tag(TileWidth); len(v) > 0 && v[0] != 0 {
+			bl.tiled = true
+			bl.blockWidth = int(v[0])
+			v = tag(TileLength)
+			if len(v) == 0 || v[0] == 0 {
+				return nil, gEC(WithKind(ErrMissingTag), WithErrorText("can not found TileLength"))
+			}
+			bl.blockHeight = int(v[0])
+			bl.offsets = tag(TileOffsets)
+			bl.counts = tag(TileByteCounts)
+		} else {
+			if v := tag(RowsPerStrip); len(v) > 0 && v[0] != 0 {
+				bl.blockHeight = minInt(int(v[0]), bl.height)
+			}
+			bl.offsets = tag(StripOffsets)
+			bl.counts = tag(StripByteCounts)
+		}
+	}
+	if bl.width <= 0 || bl.height <= 0 || bl.blockWidth <= 0 || bl.blockHeight <= 0 {
+		return nil, gEC(WithKind(ErrCorrupt), WithErrorText(fmt.Sprintf("wrong size of the image %dx%d or the block %dx%d", bl.width, bl.height, bl.blockWidth, bl.blockHeight)))
+	}
+	bl.blocksAcross = (bl.width + bl.blockWidth - 1) / bl.blockWidth
+	bl.blocksDown = (bl.height + bl.blockHeight - 1) / bl.blockHeight
+	if g.tFile != nil {
+		// the number of the blocks is compared by division, the product can overflow
+		n := minInt(len(bl.offsets), len(bl.counts))
+		if bl.blocksAcross > n || bl.blocksDown > n/bl.blocksAcross {
+			return nil, gEC(WithKind(ErrCorrupt), WithErrorText(fmt.Sprintf("require %dx%d block offsets and byte counts, but get %d and %d", bl.blocksAcross, bl.blocksDown, len(bl.offsets), len(bl.counts))))
+		}
+		if err := g.checkPixels(bl.blockWidth, bl.blockHeight); err != nil {
+			return nil, gEC(WithError(err))
+		}
+	}
+	return bl, nil
+}
+
+// Sample return the pixel, when the pixels are not in memory (OpenGeoTifHeader) only its block is decoded,
+// through the block cache set by WithBlockCache
+func (g *GeoTif) Sample(col, row int) (float64, error) {
+	sample, err := g.sampler()
+	if err != nil {
+		return 0, gEC(WithFunction("GeoTif.Sample"), WithError(err))
+	}
+	return sample(col, row)
+}
+
+// sampler return Sample for many pixels, the layout is read once and the blocks which are decoded are kept
+// until the function is dropped, it is not safe for concurrent use
+func (g *GeoTif) sampler() (func(col, row int) (float64, error), error) {
+	var gEC = NewGeoErrorCreator("GeoTif.Sample")
+	width := int(g.Meta.Columns)
+	height := int(g.Meta.Rows)
+	outOfRange := func(col, row int) error {
+		return gEC(WithKind(ErrOutOfRange), WithErrorText(fmt.Sprintf("pixel [%d, %d] is out of the raster %dx%d", col, row, width, height)))
+	}
+	if g.tFile == nil || len(g.Data.Data) == width*height {
+		return func(col, row int) (float64, error) {
+			if col < 0 || row < 0 || col >= width || row >= height {
+				return 0, outOfRange(col, row)
+			}
+			return g.Data.Data[row*width+col], nil
+		}, nil
+	}
+	bl, err := g.blockLayout()
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	blocks := map[int][]float64{}
+	return func(col, row int) (float64, error) {
+		if col < 0 || row < 0 || col >= width || row >= height {
+			return 0, outOfRange(col, row)
+		}
+		col, row = bl.orientation.storedPixel(col, row, bl.width, bl.height)
+		index := row/bl.blockHeight*bl.blocksAcross + col/bl.blockWidth
+		data, ok := blocks[index]
+		if !ok {
+			var err error
+			if data, err = g.blockData(bl, index); err != nil {
+				return 0, gEC(WithError(err))
+			}
+			blocks[index] = data
+		}
+		x0, y0, w, _ := bl.window(index)
+		return data[(row-y0)*w+col-x0], nil
+	}, nil
+}
+
+// rowReader return the function which read the rows [y0, y1), when the pixels are not in memory (OpenGeoTifHeader)
+// the blocks under the rows are decoded through the block cache, only the blocks of the last call are kept,
+// the returned values should not be modified and are valid until the next call, it is not safe for concurrent use
+func (g *GeoTif) rowReader() (func(y0, y1 int) ([]float64, error), error) {
+	var gEC = NewGeoErrorCreator("GeoTif.rowReader")
+	width := int(g.Meta.Columns)
+	height := int(g.Meta.Rows)
+	outOfRange := func(y0, y1 int) error {
+		return gEC(WithKind(ErrOutOfRange), WithErrorText(fmt.Sprintf("rows [%d, %d) are out of the raster %dx%d", y0, y1, width, height)))
+	}
+	if g.tFile == nil || len(g.Data.Data) == width*height {
+		if len(g.Data.Data) != width*height {
+			return nil, gEC(WithKind(ErrOutOfRange), WithErrorText(fmt.Sprintf("Data has %d values, but the raster is %dx%d", len(g.Data.Data), width, height)))
+		}
+		return func(y0, y1 int) ([]float64, error) {
+			if y0 < 0 || y1 > height || y0 > y1 {
+				return nil, outOfRange(y0, y1)
+			}
+			return g.Data.Data[y0*width : y1*width], nil
+		}, nil
+	}
+	bl, err := g.blockLayout()
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	var prev map[int][]float64
+	var rows []float64
+	return func(y0, y1 int) ([]float64, error) {
+		if y0 < 0 || y1 > height || y0 > y1 {
+			return nil, outOfRange(y0, y1)
+		}
+		if n := (y1 - y0) * width; cap(rows) < n {
+			rows = make([]float64, n)
+		} else {
+			rows = rows[:n]
+		}
+		blocks := map[int][]float64{}
+		last := -1
+		var data []float64
+		var x0, b0, w int
+		for row := y0; row < y1; row++ {
+			for col := 0; col < width; col++ {
+				sc, sr := bl.orientation.storedPixel(col, row, bl.width, bl.height)
+				index := sr/bl.blockHeight*bl.blocksAcross + sc/bl.blockWidth
+				if index != last {
+					var ok bool
+					if data, ok = blocks[index]; !ok {
+						// the blocks on the edge of the last rows are used again
+						if data, ok = prev[index]; !ok {
+							var err error
+							if data, err = g.blockData(bl, index); err != nil {
+								return nil, gEC(WithError(err))
+							}
+						}
+						blocks[index] = data
+					}
+					last = index
+					x0, b0, w, _ = bl.window(index)
+				}
+				rows[(row-y0)*width+col] = data[(sr-b0)*w+sc-x0]
+			}
+		}
+		prev = blocks
+		return rows, nil
+	}, nil
+}
+
+// Block is a decoded tile or strip
+type Block struct {
+	Index int
+	// Col and Row are the position of the top left pixel of the block in the image
+	Col, Row int
+	// Width and Height are cut to the image, Data has Width*Height values row by row
+	Width, Height int
+	Data          []float64
+}
+
+// pixelDecoder return the function which decode the pixel at off of the buffer as it is stored in GeoData.Data,
+// and the size of a pixel in bytes
+func (g *GeoTif) pixelDecoder() (func(buf []byte, off int) float64, int, error) {
+	var gEC = NewGeoErrorCreator("GeoTif.pixelDecoder")
+	if len(g.Meta.BitsPerSample) == 0 {
+		return nil, 0, gEC(WithKind(ErrMissingTag), WithErrorText("can not found BitsPerSample"))
+	}
+	order := g.byteOrder
+	bits := g.Meta.BitsPerSample[0]
+	spp := len(g.Meta.BitsPerSample) // samples per pixel
+	pixelBytes := spp * int(bits) / 8
+	// rescale the 16-bits to an 8-bit channel for simplicity
+	channel := func(buf []byte, off int) uint32 {
+		if bits == 16 {
+			return uint32(float64(order.Uint16(buf[off:off+2])) / 65535.0 * 255.0)
+		}
+		return uint32(buf[off])
+	}
+	channelBytes := int(bits) / 8
+	switch g.Meta.mode {
+	case mGray, mGrayInvert:
+		switch {
+		case g.Meta.SampleFormat == SampleFormatUint && bits == 8:
+			return func(buf []byte, off int) float64 { return float64(buf[off]) }, pixelBytes, nil
+		case g.Meta.SampleFormat == SampleFormatUint && bits == 16:
+			return func(buf []byte, off int) float64 { return float64(order.Uint16(buf[off:])) }, pixelBytes, nil
+		case g.Meta.SampleFormat == SampleFormatUint && bits == 32:
+			return func(buf []byte, off int) float64 { return float64(order.Uint32(buf[off:])) }, pixelBytes, nil
+		case g.Meta.SampleFormat == SampleFormatUint && bits == 64:
+			return func(buf []byte, off int) float64 { return float64(order.Uint64(buf[off:])) }, pixelBytes, nil
+		case g.Meta.SampleFormat == SampleFormatInt && bits == 8:
+			return func(buf []byte, off int) float64 { return float64(int8(buf[off])) }, pixelBytes, nil
+		case g.Meta.SampleFormat == SampleFormatInt && bits == 16:
+			return func(buf []byte, off int) float64 { return float64(int16(order.Uint16(buf[off:]))) }, pixelBytes, nil
+		case g.Meta.SampleFormat == SampleFormatInt && bits == 32:
+			return func(buf []byte, off int) float64 { return float64(int32(order.Uint32(buf[off:]))) }, pixelBytes, nil
+		case g.Meta.SampleFormat == SampleFormatInt && bits == 64:
+			return func(buf []byte, off int) float64 { return float64(int64(order.Uint64(buf[off:]))) }, pixelBytes, nil
+		case g.Meta.SampleFormat == SampleFormatFloat && bits == 32:
+			return func(buf []byte, off int) float64 { return float64(math.Float32frombits(order.Uint32(buf[off:]))) }, pixelBytes, nil
+		case g.Meta.SampleFormat == SampleFormatFloat && bits == 64:
+			return func(buf []byte, off int) float64 { return math.Float64frombits(order.Uint64(buf[off:])) }, pixelBytes, nil
+		}
+	case mPaletted:
+		if bits == 8 {
+			return func(buf []byte, off int) float64 {
+				if int(buf[off]) >= len(g.Meta.palette) {
+					return 0
+				}
+				return float64(g.Meta.palette[buf[off]])
+			}, pixelBytes, nil
+		}
+	case mRGB, mRGBA, mNRGBA:
+		if bits == 8 || bits == 16 {
+			alpha := g.Meta.mode != mRGB
+			return func(buf []byte, off int) float64 {
+				red := channel(buf, off)
+				green := channel(buf, off+channelBytes)
+				blue := channel(buf, off+2*channelBytes)
+				a := uint32(255)
+				if alpha {
+					a = channel(buf, off+3*channelBytes)
+				}
+				return float64((a << 24) | (red << 16) | (green << 8) | blue)
+			}, pixelBytes, nil
+		}
+	}
+	return nil, 0, gEC(WithKind(ErrUnsupportedSampleFormat), WithErrorText(fmt.Sprintf("Unsupported data format, SampleFormat [%d] BitsPerSample [%d]", g.Meta.SampleFormat, bits)))
+}
+
+// undoHorizontalPredictor add every sample to the same sample of the pixel on the left,
+// the rows of the buffer are stride pixels long
+func undoHorizontalPredictor(g *GeoTif, buf []byte, stride, rows int) {
+	bytesPerSample := int(g.Meta.BitsPerSample[0]) / 8
+	if bytesPerSample != 1 && bytesPerSample != 2 && bytesPerSample != 4 && bytesPerSample != 8 {
+		return
+	}
+	spp := len(g.Meta.BitsPerSample)
+	rowBytes := stride * spp * bytesPerSample
+	for y := 0; y < rows && (y+1)*rowBytes <= len(buf); y++ {
+		row := buf[y*rowBytes : (y+1)*rowBytes]
+		for off := spp * bytesPerSample; off < len(row); off += bytesPerSample {
+			prev := off - spp*bytesPerSample
+			switch bytesPerSample {
+			case 1:
+				row[off] += row[prev]
+			case 2:
+				g.byteOrder.PutUint16(row[off:], g.byteOrder.Uint16(row[off:])+g.byteOrder.Uint16(row[prev:]))
+			case 4:
+				g.byteOrder.PutUint32(row[off:], g.byteOrder.Uint32(row[off:])+g.byteOrder.Uint32(row[prev:]))
+			case 8:
+				g.byteOrder.PutUint64(row[off:], g.byteOrder.Uint64(row[off:])+g.byteOrder.Uint64(row[prev:]))
+			}
+		}
+	}
+}
+
+// decodeBlock decode the k-th shown block from the file, or copy it from Data when the pixels are in memory
+func (g *GeoTif) decodeBlock(bl *blockLayout, k int) (Block, error) {
+	index := bl.storedIndex(k)
+	x0, y0, w, h := bl.window(index)
+	sx0, sy0, sw, sh := bl.orientation.shownWindow(x0, y0, w, h, bl.width, bl.height)
+	b := Block{Index: k, Col: sx0, Row: sy0, Width: sw, Height: sh}
+	if g.tFile == nil || len(g.Data.Data) == bl.width*bl.height {
+		width := int(g.Meta.Columns)
+		b.Data = make([]float64, sw*sh)
+		for r := 0; r < sh; r++ {
+			copy(b.Data[r*sw:(r+1)*sw], g.Data.Data[(sy0+r)*width+sx0:])
+		}
+		return b, nil
+	}
+	data, err := g.blockData(bl, index)
+	if err != nil {
+		return b, gEC(WithFunction("GeoTif.decodeBlock"), WithError(err))
+	}
+	if bl.orientation != orientTopLeft {
+		data = bl.orientation.shownBlock(data, x0, y0, w, sx0, sy0, sw, sh, bl.width, bl.height)
+	} else if g.blockCache != nil {
+		// the values are shared with the cache
+		data = append([]float64(nil), data...)
+	}
+	b.Data = data
+	return b, nil
+}
+
+// blockData return the values of the block through the block cache, they should not be modified
+func (g *GeoTif) blockData(bl *blockLayout, index int) ([]float64, error) {
+	if g.blockCache == nil {
+		return g.readBlock(bl, index)
+	}
+	key := blockKey{file: g.cacheFile, ifd: g.GeoTifHeader.offset, index: index}
+	if data, ok := g.blockCache.get(key); ok {
+		return data, nil
+	}
+	data, err := g.readBlock(bl, index)
+	if err != nil {
+		return nil, err
+	}
+	g.blockCache.put(key, data)
+	return data, nil
+}
+
+// readBlock read and decode the block from the file
+func (g *GeoTif) readBlock(bl *blockLayout, index int) ([]float64, error) {
+	var gEC = NewGeoErrorCreator("GeoTif.readBlock")
+	_, _, w, h := bl.window(index)
+	data := make([]float64, w*h)
+	offset, count := int64(bl.offsets[index]), int64(bl.counts[index])
+	if offset == 0 || count == 0 {
+		// GDAL writes no bytes for the empty blocks of a sparse file (SPARSE_OK), they are nodata or 0
+		if nodata, ok := g.Meta.Nodata(); ok && nodata != 0 {
+			for i := range data {
+				data[i] = nodata
+			}
+		}
+		return data, nil
+	}
+	decode, pixelBytes, err := g.pixelDecoder()
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	// the padded tiles are blockWidth wide, the strips are as wide as the image
+	stride := bl.blockWidth
+	if g.fileSize > 0 && offset+count > g.fileSize {
+		return nil, gEC(WithKind(ErrCorrupt), WithErrorText(fmt.Sprintf("block %d [%d, %d bytes] is out of the file of %d bytes", index, offset, count, g.fileSize)))
+	}
+	reader := geoDataReader{
+		tFile:           g.tFile,
+		byteOrder:       g.byteOrder,
+		compressionType: bl.compression,
+		limit:           int64(h) * int64(stride) * int64(pixelBytes),
+	}
+	buf, err := reader.read(offset, count)
+	if err != nil {
+		return nil, gEC(WithError(err), WithMsg(fmt.Sprintf("block %d", index)))
+	}
+	if bl.predictor == prHorizontal {
+		if bl.compression == cNone {
+			// the uncompressed buffer may be the memory of the file
+			buf = append([]byte(nil), buf...)
+		}
+		undoHorizontalPredictor(g, buf, stride, h)
+	}
+	if need := ((h-1)*stride + w) * pixelBytes; len(buf) < need {
+		return nil, gEC(WithKind(ErrCorrupt), WithErrorText(fmt.Sprintf("block %d has %d bytes, but %d bytes are required", index, len(buf), need)))
+	}
+	for r := 0; r < h; r++ {
+		off := r * stride * pixelBytes
+		for c := 0; c < w; c++ {
+			data[r*w+c] = decode(buf, off)
+			off += pixelBytes
+		}
+	}
+	return data, nil
+}
+
+// BlockIterator yield the blocks one by one
+//
+//	it := g.Blocks()
+//	for it.Next() {
+//		b := it.Block()
+//	}
+//	if err := it.Err(); err != nil {
+//	}
+type BlockIterator struct {
+	g      *GeoTif
+	layout *blockLayout
+	next   int
+	block  Block
+	err    error
+}
+
+// Blocks iterate the tiles or strips of the file, a raster which is not read from a file is split into
+// strips of 256 rows
+func (g *GeoTif) Blocks() *BlockIterator {
+	it := &BlockIterator{g: g}
+	if it.layout, it.err = g.blockLayout(); it.err != nil {
+		it.err = gEC(WithFunction("GeoTif.Blocks"), WithError(it.err))
+	}
+	return it
+}
+
+// Len is the number of the blocks
+func (it *BlockIterator) Len() int {
+	if it.layout == nil {
+		return 0
+	}
+	return it.layout.count()
+}
+
+// Next decode the next block, false when all blocks are read or there is an error
+func (it *BlockIterator) Next() bool {
+	if it.err != nil || it.next >= it.Len() {
+		return false
+	}
+	it.block, it.err = it.g.decodeBlock(it.layout, it.next)
+	if it.err != nil {
+		it.err = gEC(WithFunction("BlockIterator.Next"), WithError(it.err))
+		return false
+	}
+	it.next++
+	return true
+}
+
+func (it *BlockIterator) Block() Block {
+	return it.block
+}
+
+func (it *BlockIterator) Err() error {
+	return it.err
+}
+
+// BlockFunc process a block, the returned values (Width*Height, or nil) are written to the same window of the output
+// it is called by many goroutines at the same time
+type BlockFunc func(b Block) ([]float64, error)
+
+// BlockSink receive the processed blocks one by one in the order of the blocks
+type BlockSink interface {
+	WriteBlock(b Block) error
+}
+
+// WriteBlock copy the block into the same window of the raster
+func (g *GeoTif) WriteBlock(b Block) error {
+	width := int(g.Meta.Columns)
+	if b.Col < 0 || b.Row < 0 || b.Col+b.Width > width || b.Row+b.Height > int(g.Meta.Rows) || len(b.Data) != b.Width*b.Height {
+		return gEC(WithKind(ErrOutOfRange), WithFunction("GeoTif.WriteBlock"), WithErrorText(fmt.Sprintf("block %d [%d, %d, %dx%d] is out of the raster", b.Index, b.Col, b.Row, b.Width, b.Height)))
+	}
+	if len(g.Data.Data) != width*int(g.Meta.Rows) {
+		g.Data.Data = make([]float64, width*int(g.Meta.Rows))
+	}
+	copyBlock(g.Data.Data, width, b)
+	return nil
+}
+
+func copyBlock(data []float64, width int, b Block) {
+	for r := 0; r < b.Height; r++ {
+		copy(data[(b.Row+r)*width+b.Col:], b.Data[r*b.Width:(r+1)*b.Width])
+	}
+}
+
+// dataSink collect the blocks into the Data of a GeoTif which is being read
+type dataSink struct {
+	width int
+	data  []float64
+}
+
+func (ds *dataSink) WriteBlock(b Block) error {
+	copyBlock(ds.data, ds.width, b)
+	return nil
+}
+
+// ProcessBlocks decode the blocks and call fn with the workers (runtime.NumCPU() when workers <= 0),
+// the results are passed to sink (it can be nil) in the order of the blocks
+// at most 2*workers blocks are in memory at the same time
+func (g *GeoTif) ProcessBlocks(workers int, fn BlockFunc, sink BlockSink) error {
+	return g.ProcessBlocksContext(context.Background(), workers, fn, sink)
+}
+
+// ProcessBlocksContext is ProcessBlocks which stops at the next block when ctx is done
+func (g *GeoTif) ProcessBlocksContext(ctx context.Context, workers int, fn BlockFunc, sink BlockSink) error {
+	var gEC = NewGeoErrorCreator("GeoTif.ProcessBlocks")
+	layout, err := g.blockLayout()
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	if err = g.processBlocks(ctx, layout, workers, fn, sink); err != nil {
+		return gEC(WithError(err))
+	}
+	return nil
+}
+
+type blockResult struct {
+	block Block
+	err   error
+}
+
+// processBlocks call the progress set by WithProgress after every block is passed to sink
+func (g *GeoTif) processBlocks(ctx context.Context, layout *blockLayout, workers int, fn BlockFunc, sink BlockSink) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	jobs := make(chan int)
+	results := make(chan blockResult)
+	// tokens bound the blocks which are decoded but not written
+	tokens := make(chan struct{}, 2*workers)
+	stop := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		for i := 0; i < layout.count(); i++ {
+			select {
+			case tokens <- struct{}{}:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				b, err := g.decodeBlock(layout, i)
+				if err == nil && fn != nil {
+					var data []float64
+					if data, err = fn(b); err == nil {
+						if data != nil && len(data) != b.Width*b.Height {
+							err = gEC(WithFunction("GeoTif.processBlocks"), WithErrorText(fmt.Sprintf("block %d returns %d values, but it has %d pixels", i, len(data), b.Width*b.Height)))
+						}
+						b.Data = data
+					}
+				}
+				b.Index = i
+				results <- blockResult{block: b, err: err}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	var err error
+	pending := map[int]Block{}
+	next := 0
+	for r := range results {
+		if err != nil {
+			continue
+		}
+		if r.err != nil {
+			err = r.err
+			close(stop)
+			continue
+		}
+		pending[r.block.Index] = r.block
+		for b, ok := pending[next]; ok; b, ok = pending[next] {
+			delete(pending, next)
+			next++
+			<-tokens
+			if sink != nil && b.Data != nil {
+				if err = sink.WriteBlock(b); err != nil {
+					close(stop)
+					break
+				}
+			}
+			if g.progress != nil {
+				g.progress(next, layout.count())
+			}
+		}
+	}
+	if err == nil && next < layout.count() {
+		// the blocks are not all sent when ctx is done
+		err = gEC(WithFunction("GeoTif.processBlocks"), WithError(ctx.Err()), WithMsg(fmt.Sprintf("%d of %d blocks are processed", next, layout.count())))
+	}
+	return err
+}
@@ -0,0 +1,186 @@
+package GeoTiff
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+)
+
+type calcConfig struct {
+	nodata        float64
+	bitsPerSample uint
+	sampleFormat  uint
+	blockRows     int
+	writerOptions []WriterOptions
+}
+
+type CalcOptions func(cc *calcConfig)
+
+// WithCalcNodata set the nodata of the result, default is -9999
+func WithCalcNodata(nodata float64) CalcOptions {
+	return func(cc *calcConfig) {
+		cc.nodata = nodata
+	}
+}
+
+// WithCalcDataType set the data type of the result, default is float32
+func WithCalcDataType(bitsPerSample, sampleFormat uint) CalcOptions {
+	return func(cc *calcConfig) {
+		cc.bitsPerSample = bitsPerSample
+		cc.sampleFormat = sampleFormat
+	}
+}
+
+// WithCalcBlockRows set how many rows are evaluated at a time, default is 256
+func WithCalcBlockRows(rows int) CalcOptions {
+	return func(cc *calcConfig) {
+		cc.blockRows = rows
+	}
+}
+
+// WithCalcWriterOptions is passed to the Writer by CalcTo
+func WithCalcWriterOptions(opts ...WriterOptions) CalcOptions {
+	return func(cc *calcConfig) {
+		cc.writerOptions = append(cc.writerOptions, opts...)
+	}
+}
+
+// Calc evaluate the band-math expression pixel by pixel, the variables of the expression are the keys of inputs
+//
+//	ndvi, err := Calc("(B4-B3)/(B4+B3)", map[string]*GeoTif{"B3": red, "B4": nir})
+//
+// all the inputs should be on the same grid and CRS, the pixel is nodata when any input is nodata
+// or the result is NaN/Inf, the inputs opened by OpenGeoTifHeader are read block by block with the rows of the result
+func Calc(expression string, inputs map[string]*GeoTif, opts ...CalcOptions) (*GeoTif, error) {
+	var gEC = NewGeoErrorCreator("Calc")
+	var out *GeoTif
+	err := calc(expression, inputs, opts, func(template *GeoTif, cfg calcConfig) (func(rows []float64) error, error) {
+		out = newCalcOutput(template, cfg)
+		off := 0
+		return func(rows []float64) error {
+			off += copy(out.Data.Data[off:], rows)
+			return nil
+		}, nil
+	})
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	return out, nil
+}
+
+// CalcTo is the same as Calc, but the result is written to w block by block
+func CalcTo(w io.WriteSeeker, expression string, inputs map[string]*GeoTif, opts ...CalcOptions) error {
+	var gEC = NewGeoErrorCreator("CalcTo")
+	var gw *Writer
+	err := calc(expression, inputs, opts, func(template *GeoTif, cfg calcConfig) (func(rows []float64) error, error) {
+		out := newCalcOutput(template, cfg)
+		// the Data is not used by the writer
+		out.Data.Data = nil
+		var err error
+		if gw, err = NewWriter(w, out, cfg.writerOptions...); err != nil {
+			return nil, err
+		}
+		return gw.WriteRows, nil
+	})
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	if err = gw.Close(); err != nil {
+		return gEC(WithError(err))
+	}
+	return nil
+}
+
+func newCalcOutput(template *GeoTif, cfg calcConfig) *GeoTif {
+	out := NewGeoTifLike(template, cfg.bitsPerSample, cfg.sampleFormat)
+	out.Meta.NodataValue = strconv.FormatFloat(cfg.nodata, 'g', -1, 64)
+	return out
+}
+
+func calc(expression string, inputs map[string]*GeoTif, opts []CalcOptions,
+	open func(template *GeoTif, cfg calcConfig) (func(rows []float64) error, error)) error {
+	cfg := calcConfig{
+		nodata:        -9999,
+		bitsPerSample: 32,
+		sampleFormat:  SampleFormatFloat,
+		blockRows:     256,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.blockRows <= 0 {
+		cfg.blockRows = 256
+	}
+	expr, err := ParseExpression(expression)
+	if err != nil {
+		return gEC(WithFunction("calc"), WithError(err))
+	}
+	if len(inputs) == 0 {
+		return gEC(WithFunction("calc"), WithErrorText("there is no input"))
+	}
+	// the first input by name is the template of the result
+	names := make([]string, 0, len(inputs))
+	for name := range inputs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	template := inputs[names[0]]
+	for _, name := range names[1:] {
+		if err = template.CheckSameGrid(inputs[name]); err != nil {
+			return gEC(WithFunction("calc"), WithError(err), WithMsg(fmt.Sprintf("%s and %s", names[0], name)))
+		}
+	}
+	vars := make([]*GeoTif, len(expr.Variables))
+	readers := make([]func(y0, y1 int) ([]float64, error), len(expr.Variables))
+	for i, name := range expr.Variables {
+		var ok bool
+		if vars[i], ok = inputs[name]; !ok {
+			return gEC(WithFunction("calc"), WithErrorText(fmt.Sprintf("variable [%s] is not in the inputs", name)))
+		}
+		if readers[i], err = vars[i].rowReader(); err != nil {
+			return gEC(WithFunction("calc"), WithError(err), WithMsg(fmt.Sprintf("input [%s]", name)))
+		}
+	}
+	emit, err := open(template, cfg)
+	if err != nil {
+		return gEC(WithFunction("calc"), WithError(err))
+	}
+
+	width := int(template.Meta.Columns)
+	height := int(template.Meta.Rows)
+	values := make([]float64, len(vars))
+	rows := make([][]float64, len(vars))
+	block := make([]float64, 0, cfg.blockRows*width)
+	for row := 0; row < height; row += cfg.blockRows {
+		rowEnd := minInt(row+cfg.blockRows, height)
+		for k, read := range readers {
+			if rows[k], err = read(row, rowEnd); err != nil {
+				return gEC(WithFunction("calc"), WithError(err), WithMsg(fmt.Sprintf("input [%s]", expr.Variables[k])))
+			}
+		}
+		block = block[:0]
+		for i := 0; i < (rowEnd-row)*width; i++ {
+			isNodata := false
+			for k, v := range vars {
+				values[k] = rows[k][i]
+				if v.IsNodata(values[k]) {
+					isNodata = true
+					break
+				}
+			}
+			result := cfg.nodata
+			if !isNodata {
+				if r := expr.Eval(values); !math.IsNaN(r) && !math.IsInf(r, 0) {
+					result = r
+				}
+			}
+			block = append(block, result)
+		}
+		if err = emit(block); err != nil {
+			return gEC(WithFunction("calc"), WithError(err))
+		}
+	}
+	return nil
+}
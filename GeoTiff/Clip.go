@@ -0,0 +1,122 @@
+package GeoTiff
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// Window copy the pixels of [x0, x1) x [y0, y1) into a new raster, the transform is moved to the window
+func (g *GeoTif) Window(x0, y0, x1, y1 int) (*GeoTif, error) {
+	width := int(g.Meta.Columns)
+	height := int(g.Meta.Rows)
+	if err := g.pixels(); err != nil {
+		return nil, gEC(WithFunction("GeoTif.Window"), WithError(err))
+	}
+	if x0 < 0 || y0 < 0 || x1 > width || y1 > height || x0 >= x1 || y0 >= y1 {
+		return nil, gEC(WithFunction("GeoTif.Window"), WithErrorText(fmt.Sprintf("window [%d, %d, %d, %d] is out of the raster %dx%d", x0, y0, x1, y1, width, height)))
+	}
+	out := NewGeoTifLike(g, g.Meta.BitsPerSample[0], g.Meta.SampleFormat)
+	// RGB(A) and paletted pixels are packed, they keep the format of g
+	out.Meta.mode = g.Meta.mode
+	out.Meta.palette = g.Meta.palette
+	out.Meta.PhotometricInterp = g.Meta.PhotometricInterp
+	out.Meta.samplesPerPixel = g.Meta.samplesPerPixel
+	out.Meta.BitsPerSample = append([]uint(nil), g.Meta.BitsPerSample...)
+	out.Meta.Columns = uint(x1 - x0)
+	out.Meta.Rows = uint(y1 - y0)
+	out.Meta.NodataValue = g.Meta.NodataValue
+	out.Metadata = g.Metadata
+	out.Transform.Data[0], out.Transform.Data[3] = g.Transform.PixelToGeo(float64(x0), float64(y0))
+	out.Data.Data = make([]float64, (x1-x0)*(y1-y0))
+	for r := y0; r < y1; r++ {
+		copy(out.Data.Data[(r-y0)*(x1-x0):], g.Data.Data[r*width+x0:r*width+x1])
+	}
+	return out, nil
+}
+
+// boundsWindow return the pixel window which covers the bounds, clipped to the raster
+func (g *GeoTif) boundsWindow(b Bounds) (x0, y0, x1, y1 int) {
+	minCol, minRow := math.Inf(1), math.Inf(1)
+	maxCol, maxRow := math.Inf(-1), math.Inf(-1)
+	for _, corner := range [][2]float64{{b.MinX, b.MinY}, {b.MaxX, b.MinY}, {b.MinX, b.MaxY}, {b.MaxX, b.MaxY}} {
+		c, r := g.Transform.GeoToPixel(corner[0], corner[1])
+		minCol, maxCol = math.Min(minCol, c), math.Max(maxCol, c)
+		minRow, maxRow = math.Min(minRow, r), math.Max(maxRow, r)
+	}
+	// the pixels which are only touched within 1e-6 of a pixel are not taken
+	x0 = maxInt(int(math.Floor(minCol+1e-6)), 0)
+	y0 = maxInt(int(math.Floor(minRow+1e-6)), 0)
+	x1 = minInt(int(math.Ceil(maxCol-1e-6)), int(g.Meta.Columns))
+	y1 = minInt(int(math.Ceil(maxRow-1e-6)), int(g.Meta.Rows))
+	return
+}
+
+// Clip return the pixels which intersect the bounds
+func (g *GeoTif) Clip(bbox Bounds) (*GeoTif, error) {
+	x0, y0, x1, y1 := g.boundsWindow(bbox)
+	if x0 >= x1 || y0 >= y1 {
+		return nil, gEC(WithFunction("GeoTif.Clip"), WithErrorText(fmt.Sprintf("%+v does not intersect the raster", bbox)))
+	}
+	out, err := g.Window(x0, y0, x1, y1)
+	if err != nil {
+		return nil, gEC(WithFunction("GeoTif.Clip"), WithError(err))
+	}
+	return out, nil
+}
+
+// ClipByPolygon set the pixels whose center is outside the polygons to nodata (0 when there is no nodata),
+// with crop the result is cut to the extent of the polygons
+func (g *GeoTif) ClipByPolygon(geojson *GeoJSONFeatureCollection, crop bool) (*GeoTif, error) {
+	var gEC = NewGeoErrorCreator("GeoTif.ClipByPolygon")
+	var polygons [][][][2]float64
+	for i, feature := range geojson.Features {
+		if feature.Geometry == nil {
+			continue
+		}
+		ps, err := feature.Geometry.Polygons()
+		if err != nil {
+			return nil, gEC(WithError(err), WithMsg(fmt.Sprintf("feature %d", i)))
+		}
+		polygons = append(polygons, ps...)
+	}
+	if len(polygons) == 0 {
+		return nil, gEC(WithErrorText("there is no polygon"))
+	}
+	out := g
+	if crop {
+		b := Bounds{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+		for _, polygon := range polygons {
+			for _, p := range polygon[0] {
+				b.MinX, b.MaxX = math.Min(b.MinX, p[0]), math.Max(b.MaxX, p[0])
+				b.MinY, b.MaxY = math.Min(b.MinY, p[1]), math.Max(b.MaxY, p[1])
+			}
+		}
+		var err error
+		if out, err = g.Clip(b); err != nil {
+			return nil, gEC(WithError(err))
+		}
+	} else {
+		var err error
+		if out, err = g.Window(0, 0, int(g.Meta.Columns), int(g.Meta.Rows)); err != nil {
+			return nil, gEC(WithError(err))
+		}
+	}
+	nodata, ok := out.Meta.Nodata()
+	if !ok {
+		nodata = 0
+		out.Meta.NodataValue = strconv.Itoa(0)
+	}
+	width := int(out.Meta.Columns)
+	height := int(out.Meta.Rows)
+	inside := newPixelMask(0, 0, width, height)
+	for _, polygon := range polygons {
+		rasterizePolygon(out.toPixelRings(polygon), width, height, false).each(inside.set)
+	}
+	for i := range out.Data.Data {
+		if !inside.bits[i] {
+			out.Data.Data[i] = nodata
+		}
+	}
+	return out, nil
+}
@@ -132,7 +132,7 @@ var DataTypeLen = [...]uint32{
 }
 
 func (dt DataType) Bytes() uint32 {
-	if dt == 0 || int(dt) > len(DataTypeLen) {
+	if dt <= 0 || int(dt) >= len(DataTypeLen) {
 		return DataTypeLen[0]
 	}
 	return DataTypeLen[int(dt)]
@@ -185,3 +185,10 @@ const (
 	prNone       = 1
 	prHorizontal = 2
 )
+
+// Values for the SampleFormat tag
+const (
+	SampleFormatUint  uint = 1
+	SampleFormatInt   uint = 2
+	SampleFormatFloat uint = 3
+)
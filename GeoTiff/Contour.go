@@ -0,0 +1,302 @@
+package GeoTiff
+
+import (
+	"math"
+	"sort"
+)
+
+type contourConfig struct {
+	interval  float64
+	base      float64
+	levels    []float64
+	attribute string
+}
+
+type ContourOptions func(cc *contourConfig)
+
+// WithContourInterval create the levels base + k*interval in the range of the data
+func WithContourInterval(interval, base float64) ContourOptions {
+	return func(cc *contourConfig) {
+		cc.interval = interval
+		cc.base = base
+	}
+}
+
+// WithContourLevels use the given levels instead of the interval
+func WithContourLevels(levels ...float64) ContourOptions {
+	return func(cc *contourConfig) {
+		cc.levels = levels
+	}
+}
+
+// WithContourAttribute set the name of the elevation property, default is "elev"
+// the filled contours use <name>_min and <name>_max
+func WithContourAttribute(name string) ContourOptions {
+	return func(cc *contourConfig) {
+		cc.attribute = name
+	}
+}
+
+func (g *GeoTif) contourConfig(opts []ContourOptions) (contourConfig, float64, float64, error) {
+	cfg := contourConfig{attribute: "elev"}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := g.pixels(); err != nil {
+		return cfg, 0, 0, gEC(WithFunction("GeoTif.contourConfig"), WithError(err))
+	}
+	min, max := math.Inf(1), math.Inf(-1)
+	for _, v := range g.Data.Data {
+		if g.IsNodata(v) {
+			continue
+		}
+		min = math.Min(min, v)
+		max = math.Max(max, v)
+	}
+	if math.IsInf(min, 1) {
+		return cfg, 0, 0, gEC(WithFunction("contourConfig"), WithErrorText("all the pixels are nodata"))
+	}
+	if len(cfg.levels) == 0 {
+		if cfg.interval <= 0 {
+			return cfg, 0, 0, gEC(WithFunction("contourConfig"), WithErrorText("require the levels or an interval > 0"))
+		}
+		if (max-min)/cfg.interval > 1e5 {
+			return cfg, 0, 0, gEC(WithFunction("contourConfig"), WithErrorText("too many levels, the interval is too small"))
+		}
+		// a level at the minimum or the maximum only touches the pixels,
+		// every level is computed from base so that the error of the interval is not accumulated
+		for k := int(math.Floor((min-cfg.base)/cfg.interval)) + 1; ; k++ {
+			l := cfg.base + float64(k)*cfg.interval
+			if l >= max {
+				break
+			}
+			cfg.levels = append(cfg.levels, l)
+		}
+	}
+	levels := append([]float64{}, cfg.levels...)
+	sort.Float64s(levels)
+	cfg.levels = levels
+	return cfg, min, max, nil
+}
+
+// contourCell is the 2x2 pixel centers of a cell, corners are in the order top left, top right, bottom right, bottom left
+type contourCell struct {
+	points [4][2]float64
+	values [4]float64
+}
+
+// eachCell call fn for the cells whose corners are all valid, the corner is at the center of the pixel
+func (g *GeoTif) eachContourCell(fn func(cell contourCell)) {
+	width := int(g.Meta.Columns)
+	height := int(g.Meta.Rows)
+	offsets := [4][2]int{{0, 0}, {1, 0}, {1, 1}, {0, 1}}
+	for row := 0; row+1 < height; row++ {
+	cells:
+		for col := 0; col+1 < width; col++ {
+			var cell contourCell
+			for k, o := range offsets {
+				v := g.Data.Data[(row+o[1])*width+col+o[0]]
+				if g.IsNodata(v) {
+					continue cells
+				}
+				cell.values[k] = v
+				cell.points[k] = [2]float64{float64(col+o[0]) + 0.5, float64(row+o[1]) + 0.5}
+			}
+			fn(cell)
+		}
+	}
+}
+
+// interpolate the point of level on the edge ab
+// a and b are ordered first so that the point is the same for the two cells sharing the edge
+func interpolate(a, b [2]float64, va, vb, level float64) [2]float64 {
+	if b[1] < a[1] || (b[1] == a[1] && b[0] < a[0]) {
+		a, b = b, a
+		va, vb = vb, va
+	}
+	t := (level - va) / (vb - va)
+	return [2]float64{a[0] + t*(b[0]-a[0]), a[1] + t*(b[1]-a[1])}
+}
+
+// Contour trace the contour lines by marching squares on the pixel centers
+// every level is a MultiLineString feature with the property elev (see WithContourAttribute),
+// the lines stop at nodata pixels
+func (g *GeoTif) Contour(opts ...ContourOptions) (*GeoJSONFeatureCollection, error) {
+	cfg, _, _, err := g.contourConfig(opts)
+	if err != nil {
+		return nil, gEC(WithFunction("GeoTif.Contour"), WithError(err))
+	}
+	segments := make([][][2][2]float64, len(cfg.levels))
+	g.eachContourCell(func(cell contourCell) {
+		for li, level := range cfg.levels {
+			var crosses [][2]float64
+			for k := 0; k < 4; k++ {
+				a, b := k, (k+1)%4
+				if (cell.values[a] >= level) != (cell.values[b] >= level) {
+					crosses = append(crosses, interpolate(cell.points[a], cell.points[b], cell.values[a], cell.values[b], level))
+				}
+			}
+			switch len(crosses) {
+			case 2:
+				if keyOf(crosses[0]) != keyOf(crosses[1]) {
+					segments[li] = append(segments[li], [2][2]float64{crosses[0], crosses[1]})
+				}
+			case 4:
+				// saddle, the crosses are on the edges 0-1, 1-2, 2-3, 3-0
+				center := (cell.values[0] + cell.values[1] + cell.values[2] + cell.values[3]) / 4
+				if (center >= level) == (cell.values[0] >= level) {
+					// corner 0 and 2 are connected, cut off corner 1 and 3
+					segments[li] = append(segments[li], [2][2]float64{crosses[0], crosses[1]}, [2][2]float64{crosses[2], crosses[3]})
+				} else {
+					segments[li] = append(segments[li], [2][2]float64{crosses[3], crosses[0]}, [2][2]float64{crosses[1], crosses[2]})
+				}
+			}
+		}
+	})
+	fc := NewFeatureCollection()
+	for li, level := range cfg.levels {
+		lines := joinSegments(segments[li])
+		if len(lines) == 0 {
+			continue
+		}
+		fc.Features = append(fc.Features, NewFeature(NewMultiLineString(g.pixelToGeoRings(lines)), map[string]interface{}{
+			cfg.attribute: level,
+		}))
+	}
+	return fc, nil
+}
+
+// joinSegments chain the segments into lines, a closed line ends with its first point
+func joinSegments(segments [][2][2]float64) [][][2]float64 {
+	ends := map[pointKey][]int{}
+	for i, s := range segments {
+		for _, p := range s {
+			k := keyOf(p)
+			ends[k] = append(ends[k], i)
+		}
+	}
+	used := make([]bool, len(segments))
+	// walk from p along the unused segments
+	walk := func(p [2]float64) [][2]float64 {
+		line := [][2]float64{p}
+		for {
+			next := -1
+			for _, i := range ends[keyOf(p)] {
+				if !used[i] {
+					next = i
+					break
+				}
+			}
+			if next == -1 {
+				return line
+			}
+			used[next] = true
+			if keyOf(segments[next][0]) == keyOf(p) {
+				p = segments[next][1]
+			} else {
+				p = segments[next][0]
+			}
+			line = append(line, p)
+		}
+	}
+	var lines [][][2]float64
+	// open lines start at an end which has only one segment
+	for i, s := range segments {
+		if used[i] {
+			continue
+		}
+		for _, p := range s {
+			if len(ends[keyOf(p)]) == 1 {
+				lines = append(lines, walk(p))
+				break
+			}
+		}
+	}
+	// the rest are closed
+	for i, s := range segments {
+		if !used[i] {
+			lines = append(lines, walk(s[0]))
+		}
+	}
+	return lines
+}
+
+// clipCell is the Sutherland–Hodgman clip of the polygon by the half plane value >= level (or value < level when below)
+// the value is linear along the edges
+func clipCell(points [][2]float64, values []float64, level float64, below bool) ([][2]float64, []float64) {
+	inside := func(v float64) bool {
+		if below {
+			return v < level
+		}
+		return v >= level
+	}
+	var outPoints [][2]float64
+	var outValues []float64
+	n := len(points)
+	for i := 0; i < n; i++ {
+		a, b := i, (i+1)%n
+		if inside(values[a]) {
+			outPoints = append(outPoints, points[a])
+			outValues = append(outValues, values[a])
+		}
+		if inside(values[a]) != inside(values[b]) {
+			outPoints = append(outPoints, interpolate(points[a], points[b], values[a], values[b], level))
+			outValues = append(outValues, level)
+		}
+	}
+	return outPoints, outValues
+}
+
+// ContourPolygons create the filled contours, the bands are split by the levels
+// every band is a MultiPolygon feature with the properties elev_min and elev_max (see WithContourAttribute),
+// the first and the last band use the minimum and maximum of the data
+func (g *GeoTif) ContourPolygons(opts ...ContourOptions) (*GeoJSONFeatureCollection, error) {
+	cfg, min, max, err := g.contourConfig(opts)
+	if err != nil {
+		return nil, gEC(WithFunction("GeoTif.ContourPolygons"), WithError(err))
+	}
+	bounds := []float64{math.Inf(-1)}
+	for _, l := range cfg.levels {
+		if l > min && l < max {
+			bounds = append(bounds, l)
+		}
+	}
+	bounds = append(bounds, math.Inf(1))
+	bands := make([]*edgeSet, len(bounds)-1)
+	for i := range bands {
+		bands[i] = newEdgeSet()
+	}
+	g.eachContourCell(func(cell contourCell) {
+		for i := range bands {
+			points, values := cell.points[:], cell.values[:]
+			if !math.IsInf(bounds[i], -1) {
+				points, values = clipCell(points, values, bounds[i], false)
+			}
+			if !math.IsInf(bounds[i+1], 1) && len(points) > 0 {
+				points, values = clipCell(points, values, bounds[i+1], true)
+			}
+			if len(points) >= 3 {
+				bands[i].addRing(points)
+			}
+		}
+	})
+	fc := NewFeatureCollection()
+	for i, band := range bands {
+		rings := band.rings()
+		if len(rings) == 0 {
+			continue
+		}
+		low, high := bounds[i], bounds[i+1]
+		if math.IsInf(low, -1) {
+			low = min
+		}
+		if math.IsInf(high, 1) {
+			high = max
+		}
+		fc.Features = append(fc.Features, NewFeature(NewMultiPolygon(ringsToPolygons(g.pixelToGeoRings(rings))), map[string]interface{}{
+			cfg.attribute + "_min": low,
+			cfg.attribute + "_max": high,
+		}))
+	}
+	return fc, nil
+}
@@ -0,0 +1,372 @@
+package GeoTiff
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+)
+
+// TagEditor change the tags and the GeoKeys of the first IFD of a tif in place, the pixels are not touched
+//
+//	e, err := OpenTagEditor("ndvi.tif")
+//	e.SetEPSG(32645)
+//	e.SetNodata("-9999")
+//	err = e.Save()
+//	e.Close()
+//
+// Save rewrite the entries of the IFD where they are, or append them at the end of the file when there are more entries
+// and patch the offset in the header, a value longer than 4 bytes is written over the old one when it is not longer,
+// otherwise it is appended too
+type TagEditor struct {
+	f *os.File
+	g GeoTif
+	// count is the number of the entries of the IFD in the file, next is the offset of the next IFD
+	count int
+	next  uint32
+	// old is the attributes in the file
+	old        map[AttributeTag]geoAttribute
+	attributes GeoAttributes
+	geoKeys    GeoAttributes
+	changed    map[AttributeTag]bool
+	// geoKeysChanged rebuild GeoKeyDirectoryTag, GeoDoubleParamsTag and GeoAsciiParamsTag on Save
+	geoKeysChanged bool
+}
+
+// layoutTags locate the pixels, they can not be changed by the TagEditor,
+// the GeoKey directory is changed by the GeoKey methods
+var layoutTags = map[AttributeTag]bool{
+	StripOffsets:       true,
+	StripByteCounts:    true,
+	TileOffsets:        true,
+	TileByteCounts:     true,
+	GeoKeyDirectoryTag: true,
+	GeoDoubleParamsTag: true,
+	GeoAsciiParamsTag:  true,
+}
+
+// OpenTagEditor open the tif to edit its tags, it should be closed
+func OpenTagEditor(FilePath string) (*TagEditor, error) {
+	var gEC = NewGeoErrorCreator("OpenTagEditor")
+	f, err := os.OpenFile(FilePath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, gEC(WithError(err))
+	}
+	e := &TagEditor{
+		f: f,
+		g: GeoTif{
+			FilePath: FilePath,
+			tFile:    f,
+			fileSize: info.Size(),
+		},
+	}
+	if err = e.load(); err != nil {
+		f.Close()
+		return nil, gEC(WithError(err), WithMsg(FilePath))
+	}
+	return e, nil
+}
+
+// load read the first IFD and the GeoKeys
+func (e *TagEditor) load() error {
+	g := &e.g
+	if err := g.checkBigOrLittle(); err != nil {
+		return gEC(WithFunction("TagEditor.load"), WithError(err))
+	}
+	data, err := g.readFile(4, 4)
+	if err != nil {
+		return gEC(WithFunction("TagEditor.load"), WithError(err))
+	}
+	g.GeoTifHeader.offset = int64(g.byteOrder.Uint32(data))
+	if g.GeoTifHeader.offset == 0 {
+		return gEC(WithKind(ErrCorrupt), WithFunction("TagEditor.load"), WithErrorText("the tif has no IFD"))
+	}
+	if data, err = g.readFile(g.GeoTifHeader.offset, 2); err != nil {
+		return gEC(WithFunction("TagEditor.load"), WithError(err))
+	}
+	e.count = int(g.byteOrder.Uint16(data))
+	g.GeoKeys = nil
+	var next int64
+	if g.GeoTifHeader.Attribute, next, err = g.readIFD(g.GeoTifHeader.offset); err != nil {
+		return gEC(WithFunction("TagEditor.load"), WithError(err))
+	}
+	e.next = uint32(next)
+	if len(g.GeoTifHeader.Attribute) != e.count {
+		// readIFD skip the types it does not know, they would be lost when the IFD is rewritten
+		return gEC(WithFunction("TagEditor.load"), WithErrorText(fmt.Sprintf("%d of %d tags have types which can not be rewritten", e.count-len(g.GeoTifHeader.Attribute), e.count)))
+	}
+	if _, err = g.GeoTifHeader.Attribute.getAttributeByTag(GeoKeyDirectoryTag); err == nil {
+		if err = g.parseGeoKeys(); err != nil {
+			return gEC(WithFunction("TagEditor.load"), WithError(err))
+		}
+	}
+	e.old = map[AttributeTag]geoAttribute{}
+	for _, attribute := range g.GeoTifHeader.Attribute {
+		e.old[attribute.Tag] = attribute
+	}
+	e.attributes = append(GeoAttributes{}, g.GeoTifHeader.Attribute...)
+	e.geoKeys = append(GeoAttributes{}, g.GeoKeys...)
+	e.changed = map[AttributeTag]bool{}
+	e.geoKeysChanged = false
+	return nil
+}
+
+// Close close the file, the changes which are not saved are dropped
+func (e *TagEditor) Close() error {
+	if e.f == nil {
+		return nil
+	}
+	err := e.f.Close()
+	e.f = nil
+	e.g.tFile = closedFile{}
+	if err != nil {
+		return gEC(WithFunction("TagEditor.Close"), WithError(err))
+	}
+	return nil
+}
+
+// Attributes return the tags of the IFD with the changes which are not saved
+func (e *TagEditor) Attributes() GeoAttributes {
+	return e.attributes
+}
+
+// GeoKeys return the GeoKeys with the changes which are not saved
+func (e *TagEditor) GeoKeys() GeoAttributes {
+	return e.geoKeys
+}
+
+func (e *TagEditor) set(attribute geoAttribute) error {
+	if layoutTags[attribute.Tag] {
+		return gEC(WithFunction("TagEditor.set"), WithErrorText(fmt.Sprintf("tag [%d] can not be changed", attribute.Tag)))
+	}
+	e.changed[attribute.Tag] = true
+	for i := range e.attributes {
+		if e.attributes[i].Tag == attribute.Tag {
+			e.attributes[i] = attribute
+			return nil
+		}
+	}
+	e.attributes = append(e.attributes, attribute)
+	return nil
+}
+
+// SetShort add or replace the tag by SHORT values
+func (e *TagEditor) SetShort(tag AttributeTag, values ...uint16) error {
+	return e.set(newShortAttribute(e.g.byteOrder, tag, values...))
+}
+
+// SetLong add or replace the tag by LONG values
+func (e *TagEditor) SetLong(tag AttributeTag, values ...uint32) error {
+	return e.set(newLongAttribute(e.g.byteOrder, tag, values...))
+}
+
+// SetDouble add or replace the tag by DOUBLE values, e.g. ModelPixelScaleTag
+func (e *TagEditor) SetDouble(tag AttributeTag, values ...float64) error {
+	return e.set(newDoubleAttribute(e.g.byteOrder, tag, values...))
+}
+
+// SetASCII add or replace the tag by a string
+func (e *TagEditor) SetASCII(tag AttributeTag, value string) error {
+	return e.set(newASCIIAttribute(tag, value))
+}
+
+// DeleteTag remove the tag, it is fine when the tag is not there
+func (e *TagEditor) DeleteTag(tag AttributeTag) error {
+	if layoutTags[tag] {
+		return gEC(WithFunction("TagEditor.DeleteTag"), WithErrorText(fmt.Sprintf("tag [%d] can not be changed", tag)))
+	}
+	for i := range e.attributes {
+		if e.attributes[i].Tag == tag {
+			e.attributes = append(e.attributes[:i], e.attributes[i+1:]...)
+			e.changed[tag] = true
+			return nil
+		}
+	}
+	return nil
+}
+
+// SetNodata set GDAL_NODATA, an empty nodata remove it
+func (e *TagEditor) SetNodata(nodata string) error {
+	if nodata == "" {
+		return e.DeleteTag(GDAL_NODATA)
+	}
+	return e.SetASCII(GDAL_NODATA, nodata)
+}
+
+// SetMetadata replace GDAL_METADATA, an empty metadata remove it
+func (e *TagEditor) SetMetadata(metadata GDALMetadata) error {
+	if metadata.IsEmpty() {
+		return e.DeleteTag(GDAL_METADATA)
+	}
+	return e.SetASCII(GDAL_METADATA, metadata.String())
+}
+
+func (e *TagEditor) setGeoKey(key geoAttribute) {
+	e.geoKeysChanged = true
+	for i := range e.geoKeys {
+		if e.geoKeys[i].Tag == key.Tag {
+			e.geoKeys[i] = key
+			return
+		}
+	}
+	e.geoKeys = append(e.geoKeys, key)
+}
+
+// SetGeoKeyShort add or replace the GeoKey by a SHORT, e.g. ProjectedCSTypeGeoKey
+func (e *TagEditor) SetGeoKeyShort(key AttributeTag, value uint16) {
+	e.setGeoKey(newShortAttribute(e.g.byteOrder, key, value))
+}
+
+// SetGeoKeyDouble add or replace the GeoKey by DOUBLE values, they are put in GeoDoubleParamsTag
+func (e *TagEditor) SetGeoKeyDouble(key AttributeTag, values ...float64) {
+	e.setGeoKey(newDoubleAttribute(e.g.byteOrder, key, values...))
+}
+
+// SetGeoKeyASCII add or replace the GeoKey by a string, it is put in GeoAsciiParamsTag
+func (e *TagEditor) SetGeoKeyASCII(key AttributeTag, value string) {
+	attribute := geoAttribute{Tag: key, Type: ASCII, Len: uint32(len(value)), SourceValue: []byte(value)}
+	_ = attribute.parseValue(e.g.byteOrder)
+	e.setGeoKey(attribute)
+}
+
+// DeleteGeoKey remove the GeoKey, it is fine when the key is not there
+func (e *TagEditor) DeleteGeoKey(key AttributeTag) {
+	for i := range e.geoKeys {
+		if e.geoKeys[i].Tag == key {
+			e.geoKeys = append(e.geoKeys[:i], e.geoKeys[i+1:]...)
+			e.geoKeysChanged = true
+			return
+		}
+	}
+}
+
+// SetEPSG replace the model type and the geographic or projected CRS code, the other GeoKeys are kept
+// except those whose values are in other tags than GeoDoubleParamsTag and GeoAsciiParamsTag (see encodeGeoKeys)
+// codes of 4000~4999 are taken as geographic CRS like NewWriter does
+func (e *TagEditor) SetEPSG(epsg uint) error {
+	if epsg == 0 || epsg > math.MaxUint16 {
+		return gEC(WithKind(ErrUnsupportedCRS), WithFunction("TagEditor.SetEPSG"), WithErrorText(fmt.Sprintf("EPSG %d can not be put in a GeoKey", epsg)))
+	}
+	e.DeleteGeoKey(GeographicTypeGeoKey)
+	e.DeleteGeoKey(ProjectedCSTypeGeoKey)
+	_, rasterTypeErr := e.geoKeys.getAttributeByTag(GTRasterTypeGeoKey)
+	for _, key := range geoKeysFromEPSG(e.g.byteOrder, epsg) {
+		if key.Tag == GTRasterTypeGeoKey && rasterTypeErr == nil {
+			// PixelIsPoint is kept
+			continue
+		}
+		e.setGeoKey(key)
+	}
+	return nil
+}
+
+// Save write the changes to the file, the values which fit in the old place are written over it and the others
+// are appended, the IFD is written over the old one unless it has more tags, then it is appended and
+// the header points to it at last, the file is synced once at the end
+func (e *TagEditor) Save() error {
+	var gEC = NewGeoErrorCreator("TagEditor.Save")
+	if e.f == nil {
+		return gEC(WithError(os.ErrClosed))
+	}
+	order := e.g.byteOrder
+	attributes := make(GeoAttributes, 0, len(e.attributes)+3)
+	changed := map[AttributeTag]bool{}
+	for tag := range e.changed {
+		changed[tag] = true
+	}
+	if e.geoKeysChanged {
+		for _, attribute := range e.attributes {
+			if attribute.Tag != GeoKeyDirectoryTag && attribute.Tag != GeoDoubleParamsTag && attribute.Tag != GeoAsciiParamsTag {
+				attributes = append(attributes, attribute)
+			}
+		}
+		if len(e.geoKeys) > 0 {
+			geoKeyAttributes, err := encodeGeoKeys(order, e.geoKeys)
+			if err != nil {
+				return gEC(WithError(err))
+			}
+			attributes = append(attributes, geoKeyAttributes...)
+		}
+		changed[GeoKeyDirectoryTag], changed[GeoDoubleParamsTag], changed[GeoAsciiParamsTag] = true, true, true
+	} else {
+		attributes = append(attributes, e.attributes...)
+	}
+	if len(attributes) > math.MaxUint16 {
+		return gEC(WithKind(ErrTooLarge), WithErrorText(fmt.Sprintf("%d tags can not be put in an IFD", len(attributes))))
+	}
+	sort.SliceStable(attributes, func(i, j int) bool {
+		return attributes[i].Tag < attributes[j].Tag
+	})
+
+	info, err := e.f.Stat()
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	end := info.Size()
+	// appendAt return the word aligned offset at the end of the file for n bytes
+	appendAt := func(n int64) (uint32, error) {
+		offset := end + end%2
+		if offset+n > math.MaxUint32 {
+			return 0, gEC(WithKind(ErrTooLarge), WithErrorText("file is larger than 4GB"))
+		}
+		end = offset + n
+		return uint32(offset), nil
+	}
+	for i := range attributes {
+		attribute := &attributes[i]
+		size := attribute.Bytes()
+		if !changed[attribute.Tag] || size <= 4 {
+			// the values of the tags which are not changed stay where they are
+			continue
+		}
+		if old, ok := e.old[attribute.Tag]; ok && old.Bytes() > 4 && old.Bytes() >= size {
+			attribute.Offset = old.Offset
+		} else if attribute.Offset, err = appendAt(int64(size)); err != nil {
+			return err
+		}
+		if _, err = e.f.WriteAt(attribute.SourceValue[:size], int64(attribute.Offset)); err != nil {
+			return gEC(WithError(err))
+		}
+	}
+
+	entries := make([]byte, 2, 2+int64(len(attributes))*geoFileAttributeSize+4)
+	order.PutUint16(entries, uint16(len(attributes)))
+	for _, attribute := range attributes {
+		entries = append(entries, attribute.toBytes(order)...)
+	}
+	next := make([]byte, 4)
+	order.PutUint32(next, e.next)
+	entries = append(entries, next...)
+	ifdOffset := e.g.GeoTifHeader.offset
+	if len(attributes) > e.count {
+		offset, err := appendAt(int64(len(entries)))
+		if err != nil {
+			return err
+		}
+		ifdOffset = int64(offset)
+	}
+	if _, err = e.f.WriteAt(entries, ifdOffset); err != nil {
+		return gEC(WithError(err))
+	}
+	if ifdOffset != e.g.GeoTifHeader.offset {
+		header := make([]byte, 4)
+		order.PutUint32(header, uint32(ifdOffset))
+		if _, err = e.f.WriteAt(header, 4); err != nil {
+			return gEC(WithError(err))
+		}
+	}
+	if err = e.f.Sync(); err != nil {
+		return gEC(WithError(err))
+	}
+	// read back what is written
+	e.g.fileSize = end
+	if err = e.load(); err != nil {
+		return gEC(WithError(err))
+	}
+	return nil
+}
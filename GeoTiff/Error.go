@@ -3,16 +3,60 @@ package GeoTiff
 import (
 	"errors"
 	"fmt"
+	"strings"
+)
+
+// the kinds of the errors, test them by errors.Is(err, ErrCorrupt)
+var (
+	// ErrUnsupportedCompression is the compression which can not be read or written
+	ErrUnsupportedCompression = errors.New("unsupported compression")
+	// ErrUnsupportedSampleFormat is the SampleFormat, BitsPerSample or color mode which can not be decoded or encoded
+	ErrUnsupportedSampleFormat = errors.New("unsupported sample format")
+	// ErrUnsupportedCRS is the CRS which can not be projected
+	ErrUnsupportedCRS = errors.New("unsupported CRS")
+	// ErrMissingTag is the tag or GeoKey which is required but not found
+	ErrMissingTag = errors.New("missing tag")
+	// ErrCorrupt is the file which is truncated or whose tags are not consistent
+	ErrCorrupt = errors.New("corrupt tif")
+	// ErrTooLarge is the image or the attribute which is larger than the limits (see WithMaxPixels)
+	ErrTooLarge = errors.New("too large")
+	// ErrOutOfRange is the pixel, block or window which is out of the raster
+	ErrOutOfRange = errors.New("out of range")
 )
 
 type GeoError struct {
 	Err      error
 	Function string
 	Msg      string
+	// Kind is one of the Err* above, it is matched by errors.Is
+	Kind error
 }
 
+// Error is "[Function] Msg: Err", the errors which are wrapped are joined by ": "
 func (ge GeoError) Error() string {
-	return fmt.Sprintf("[%s]: %v\n", ge.Function, ge.Err)
+	var parts []string
+	if ge.Msg != "" {
+		parts = append(parts, ge.Msg)
+	}
+	if ge.Err != nil {
+		parts = append(parts, ge.Err.Error())
+	} else if ge.Kind != nil {
+		parts = append(parts, ge.Kind.Error())
+	}
+	s := strings.Join(parts, ": ")
+	if ge.Function != "" {
+		s = fmt.Sprintf("[%s] %s", ge.Function, s)
+	}
+	return s
+}
+
+func (ge GeoError) Unwrap() error {
+	return ge.Err
+}
+
+// Is match the Kind of the error, the wrapped errors are matched by errors.Is through Unwrap
+func (ge GeoError) Is(target error) bool {
+	return ge.Kind != nil && ge.Kind == target
 }
 
 type GeoErrorOptions func(ge *GeoError)
@@ -27,17 +71,36 @@ func WithMsg(Msg string) GeoErrorOptions {
 		ge.Msg = Msg
 	}
 }
+
+// WithError wrap err, the text set by WithErrorText before is kept in front of it
 func WithError(err error) GeoErrorOptions {
 	return func(ge *GeoError) {
+		if ge.Err != nil && err != nil {
+			ge.Err = fmt.Errorf("%s: %w", ge.Err.Error(), err)
+			return
+		}
 		ge.Err = err
 	}
 }
+
+// WithErrorText describe the error, the error set by WithError before is wrapped instead of replaced
 func WithErrorText(text string) GeoErrorOptions {
 	return func(ge *GeoError) {
+		if ge.Err != nil {
+			ge.Err = fmt.Errorf("%s: %w", text, ge.Err)
+			return
+		}
 		ge.Err = errors.New(text)
 	}
 }
 
+// WithKind set the Kind of the error, one of the Err* of the package
+func WithKind(kind error) GeoErrorOptions {
+	return func(ge *GeoError) {
+		ge.Kind = kind
+	}
+}
+
 func NewGeoErrorCreator(Function string) func(opts ...GeoErrorOptions) GeoError {
 	return func(opts ...GeoErrorOptions) GeoError {
 		ge := GeoError{
@@ -0,0 +1,408 @@
+package GeoTiff
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expression is a compiled band-math expression, e.g.
+//
+//	(B4-B3)/(B4+B3)
+//	B1 > 0.5 ? 1 : 0
+//	where(B1 > 0 && B2 > 0, sqrt(B1*B2), -1)
+//
+// operators (from low to high precedence): ?:  ||  &&  == != < <= > >=  + -  * / %  ^  unary - !
+// functions: abs sqrt exp log log10 sin cos tan asin acos atan atan2 floor ceil round min max pow where isnan
+// constants: pi e nan, they are matched by the case, E, PI or NaN are variables
+// the result of the comparison and logical operators is 1 or 0
+type Expression struct {
+	Source    string
+	Variables []string
+	eval      func(vars []float64) float64
+}
+
+// ParseExpression compile the expression, all the names which are not function or constant are variables
+func ParseExpression(source string) (*Expression, error) {
+	tokens, err := tokenize(source)
+	if err != nil {
+		return nil, gEC(WithFunction("ParseExpression"), WithError(err))
+	}
+	p := &exprParser{tokens: tokens, vars: map[string]int{}}
+	node, err := p.parseConditional()
+	if err != nil {
+		return nil, gEC(WithFunction("ParseExpression"), WithError(err))
+	}
+	if p.pos != len(p.tokens) {
+		return nil, gEC(WithFunction("ParseExpression"), WithErrorText(fmt.Sprintf("unexpected [%s] at %d", p.tokens[p.pos].text, p.tokens[p.pos].at)))
+	}
+	return &Expression{
+		Source:    source,
+		Variables: p.names,
+		eval:      node,
+	}, nil
+}
+
+// Eval evaluate the expression, vars is in the order of Variables
+func (e *Expression) Eval(vars []float64) float64 {
+	return e.eval(vars)
+}
+
+type exprTokenKind int
+
+const (
+	tkNumber exprTokenKind = iota
+	tkName
+	tkOperator
+)
+
+type exprToken struct {
+	kind  exprTokenKind
+	text  string
+	value float64
+	at    int
+}
+
+var exprOperators = []string{"&&", "||", "==", "!=", "<=", ">=", "+", "-", "*", "/", "%", "^", "<", ">", "!", "?", ":", "(", ")", ","}
+
+func tokenize(source string) ([]exprToken, error) {
+	var tokens []exprToken
+	runes := []rune(source)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// exponent, e.g. 1e-5
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					for j < len(runes) && unicode.IsDigit(runes[j]) {
+						j++
+					}
+					i = j
+				}
+			}
+			text := string(runes[start:i])
+			v, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, gEC(WithFunction("tokenize"), WithErrorText(fmt.Sprintf("wrong number [%s] at %d", text, start)))
+			}
+			tokens = append(tokens, exprToken{kind: tkNumber, text: text, value: v, at: start})
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			tokens = append(tokens, exprToken{kind: tkName, text: string(runes[start:i]), at: start})
+		default:
+			found := false
+			for _, op := range exprOperators {
+				if strings.HasPrefix(string(runes[i:minInt(i+2, len(runes))]), op) {
+					tokens = append(tokens, exprToken{kind: tkOperator, text: op, at: i})
+					i += len([]rune(op))
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, gEC(WithFunction("tokenize"), WithErrorText(fmt.Sprintf("unexpected [%c] at %d", r, i)))
+			}
+		}
+	}
+	return tokens, nil
+}
+
+type exprNode = func(vars []float64) float64
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+	vars   map[string]int
+	names  []string
+}
+
+func (p *exprParser) peek(op string) bool {
+	return p.pos < len(p.tokens) && p.tokens[p.pos].kind == tkOperator && p.tokens[p.pos].text == op
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.peek(op) {
+		if p.pos < len(p.tokens) {
+			return gEC(WithFunction("exprParser.expect"), WithErrorText(fmt.Sprintf("require [%s], but got [%s] at %d", op, p.tokens[p.pos].text, p.tokens[p.pos].at)))
+		}
+		return gEC(WithFunction("exprParser.expect"), WithErrorText(fmt.Sprintf("require [%s], but the expression ends", op)))
+	}
+	p.pos++
+	return nil
+}
+
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (p *exprParser) parseConditional() (exprNode, error) {
+	cond, err := p.parseBinary(0)
+	if err != nil {
+		return nil, err
+	}
+	if !p.peek("?") {
+		return cond, nil
+	}
+	p.pos++
+	a, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	if err = p.expect(":"); err != nil {
+		return nil, err
+	}
+	b, err := p.parseConditional()
+	if err != nil {
+		return nil, err
+	}
+	return func(vars []float64) float64 {
+		if cond(vars) != 0 {
+			return a(vars)
+		}
+		return b(vars)
+	}, nil
+}
+
+// binary operators by precedence
+var exprBinaryLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"==", "!=", "<", "<=", ">", ">="},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+func (p *exprParser) parseBinary(level int) (exprNode, error) {
+	if level == len(exprBinaryLevels) {
+		return p.parsePower()
+	}
+	left, err := p.parseBinary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op := ""
+		for _, o := range exprBinaryLevels[level] {
+			if p.peek(o) {
+				op = o
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		p.pos++
+		right, err := p.parseBinary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = binaryNode(op, left, right)
+	}
+}
+
+func binaryNode(op string, a, b exprNode) exprNode {
+	switch op {
+	case "||":
+		return func(v []float64) float64 { return boolToFloat(a(v) != 0 || b(v) != 0) }
+	case "&&":
+		return func(v []float64) float64 { return boolToFloat(a(v) != 0 && b(v) != 0) }
+	case "==":
+		return func(v []float64) float64 { return boolToFloat(a(v) == b(v)) }
+	case "!=":
+		return func(v []float64) float64 { return boolToFloat(a(v) != b(v)) }
+	case "<":
+		return func(v []float64) float64 { return boolToFloat(a(v) < b(v)) }
+	case "<=":
+		return func(v []float64) float64 { return boolToFloat(a(v) <= b(v)) }
+	case ">":
+		return func(v []float64) float64 { return boolToFloat(a(v) > b(v)) }
+	case ">=":
+		return func(v []float64) float64 { return boolToFloat(a(v) >= b(v)) }
+	case "+":
+		return func(v []float64) float64 { return a(v) + b(v) }
+	case "-":
+		return func(v []float64) float64 { return a(v) - b(v) }
+	case "*":
+		return func(v []float64) float64 { return a(v) * b(v) }
+	case "/":
+		return func(v []float64) float64 { return a(v) / b(v) }
+	case "%":
+		return func(v []float64) float64 { return math.Mod(a(v), b(v)) }
+	case "^":
+		return func(v []float64) float64 { return math.Pow(a(v), b(v)) }
+	}
+	return nil
+}
+
+// ^ is right associative and binds tighter than unary minus: -2^2 = -4
+func (p *exprParser) parsePower() (exprNode, error) {
+	if p.peek("-") || p.peek("+") || p.peek("!") {
+		op := p.tokens[p.pos].text
+		p.pos++
+		a, err := p.parsePower()
+		if err != nil {
+			return nil, err
+		}
+		switch op {
+		case "-":
+			return func(v []float64) float64 { return -a(v) }, nil
+		case "!":
+			return func(v []float64) float64 { return boolToFloat(a(v) == 0) }, nil
+		}
+		return a, nil
+	}
+	base, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek("^") {
+		p.pos++
+		exp, err := p.parsePower()
+		if err != nil {
+			return nil, err
+		}
+		return binaryNode("^", base, exp), nil
+	}
+	return base, nil
+}
+
+var exprConstants = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"nan": math.NaN(),
+}
+
+var exprFunctions1 = map[string]func(float64) float64{
+	"abs":   math.Abs,
+	"sqrt":  math.Sqrt,
+	"exp":   math.Exp,
+	"log":   math.Log,
+	"log10": math.Log10,
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"asin":  math.Asin,
+	"acos":  math.Acos,
+	"atan":  math.Atan,
+	"floor": math.Floor,
+	"ceil":  math.Ceil,
+	"round": math.Round,
+	"isnan": func(x float64) float64 { return boolToFloat(math.IsNaN(x)) },
+}
+
+var exprFunctions2 = map[string]func(float64, float64) float64{
+	"atan2": math.Atan2,
+	"pow":   math.Pow,
+	"min":   math.Min,
+	"max":   math.Max,
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, gEC(WithFunction("exprParser.parsePrimary"), WithErrorText("the expression ends unexpectedly"))
+	}
+	tk := p.tokens[p.pos]
+	p.pos++
+	switch tk.kind {
+	case tkNumber:
+		v := tk.value
+		return func([]float64) float64 { return v }, nil
+	case tkName:
+		if p.peek("(") {
+			return p.parseCall(tk)
+		}
+		// the variables are named as the inputs of Calc, e.g. a band "E", so the case is kept
+		if v, ok := exprConstants[tk.text]; ok {
+			return func([]float64) float64 { return v }, nil
+		}
+		index, ok := p.vars[tk.text]
+		if !ok {
+			index = len(p.names)
+			p.vars[tk.text] = index
+			p.names = append(p.names, tk.text)
+		}
+		return func(v []float64) float64 { return v[index] }, nil
+	default:
+		if tk.text == "(" {
+			node, err := p.parseConditional()
+			if err != nil {
+				return nil, err
+			}
+			if err = p.expect(")"); err != nil {
+				return nil, err
+			}
+			return node, nil
+		}
+	}
+	return nil, gEC(WithFunction("exprParser.parsePrimary"), WithErrorText(fmt.Sprintf("unexpected [%s] at %d", tk.text, tk.at)))
+}
+
+func (p *exprParser) parseCall(name exprToken) (exprNode, error) {
+	// skip (
+	p.pos++
+	var args []exprNode
+	for !p.peek(")") {
+		arg, err := p.parseConditional()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.peek(",") {
+			break
+		}
+		p.pos++
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	fn := strings.ToLower(name.text)
+	argError := func(require int) error {
+		return gEC(WithFunction("exprParser.parseCall"), WithErrorText(fmt.Sprintf("%s require %d arguments, but got %d", name.text, require, len(args))))
+	}
+	if f, ok := exprFunctions1[fn]; ok {
+		if len(args) != 1 {
+			return nil, argError(1)
+		}
+		a := args[0]
+		return func(v []float64) float64 { return f(a(v)) }, nil
+	}
+	if f, ok := exprFunctions2[fn]; ok {
+		if len(args) != 2 {
+			return nil, argError(2)
+		}
+		a, b := args[0], args[1]
+		return func(v []float64) float64 { return f(a(v), b(v)) }, nil
+	}
+	if fn == "where" || fn == "if" {
+		if len(args) != 3 {
+			return nil, argError(3)
+		}
+		c, a, b := args[0], args[1], args[2]
+		return func(v []float64) float64 {
+			if c(v) != 0 {
+				return a(v)
+			}
+			return b(v)
+		}, nil
+	}
+	return nil, gEC(WithFunction("exprParser.parseCall"), WithErrorText(fmt.Sprintf("unknown function [%s] at %d", name.text, name.at)))
+}
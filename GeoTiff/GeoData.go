@@ -18,6 +18,8 @@ type geoDataReader struct {
 	tFile           io.ReaderAt
 	byteOrder       binary.ByteOrder
 	compressionType CompressionType
+	// limit is the size of the decoded block, the compressed data can not be decoded to more bytes
+	limit int64
 }
 
 func (gdr geoDataReader) read(offset, size int64) ([]byte, error) {
@@ -25,11 +27,15 @@ func (gdr geoDataReader) read(offset, size int64) ([]byte, error) {
 	var err error
 	switch gdr.compressionType {
 	case cNone:
+		// the bytes after the block are not read
+		if size > gdr.limit {
+			size = gdr.limit
+		}
 		buf, err = readCNone(gdr.tFile, offset, size)
 	case cLZW:
 		r := lzw.NewReader(io.NewSectionReader(gdr.tFile, offset, size), lzw.MSB, 8)
 		defer r.Close()
-		buf, err = io.ReadAll(r)
+		buf, err = io.ReadAll(io.LimitReader(r, gdr.limit))
 		if err = r.Close(); err != nil {
 			return nil, gEC(WithFunction("geoDataReader.read"), WithError(err))
 		}
@@ -38,24 +44,31 @@ func (gdr geoDataReader) read(offset, size int64) ([]byte, error) {
 		if err != nil {
 			return nil, gEC(WithFunction("geoDataReader.read"), WithError(err))
 		}
-		buf, err = io.ReadAll(r)
+		buf, err = io.ReadAll(io.LimitReader(r, gdr.limit))
 		if err = r.Close(); err != nil {
 			return nil, gEC(WithFunction("geoDataReader.read"), WithError(err))
 		}
 	case cPackBits:
-		return readCPackBits(gdr.tFile, offset, size)
+		return readCPackBits(gdr.tFile, offset, size, gdr.limit)
 	default:
-		return nil, gEC(WithFunction("geoDataReader.read"), WithErrorText(fmt.Sprintf("Unsupported compression value %d", gdr.compressionType)))
+		return nil, gEC(WithKind(ErrUnsupportedCompression), WithFunction("geoDataReader.read"), WithErrorText(fmt.Sprintf("Unsupported compression value %d", gdr.compressionType)))
 	}
 	if err != nil {
 		return nil, gEC(WithFunction("geoDataReader.read"), WithError(err))
 	}
 	return buf, nil
 }
+
+// slicer is the io.ReaderAt which can return its memory without copy, as buffer and mmapFile
+type slicer interface {
+	Slice(off, n int) ([]byte, error)
+}
+
+// readCNone returns the memory of a slicer without copy, it should not be modified
 func readCNone(tFile io.ReaderAt, offset, size int64) ([]byte, error) {
 	var buf []byte
 	var err error
-	if b, ok := tFile.(*buffer); ok {
+	if b, ok := tFile.(slicer); ok {
 		buf, err = b.Slice(int(offset), int(size))
 	} else {
 		buf = make([]byte, size)
@@ -66,31 +79,37 @@ func readCNone(tFile io.ReaderAt, offset, size int64) ([]byte, error) {
 
 // https://github.com/grumets/MiraMonMapBrowser/blob/b997173bc0ee2ebd1d61567a0d4e33d1c44004a4/src/geotiff/compression/packbits.js#L18
 // notice: L18 is (j = 0; j <= header;j++) <=== j <= header, so read (header + 1) data
-func readCPackBits(tFile io.ReaderAt, offset, size int64) ([]byte, error) {
+// the runs which are cut by the end of the data are an error, the output stops at limit bytes
+func readCPackBits(tFile io.ReaderAt, offset, size, limit int64) ([]byte, error) {
 	srcBuf, err := readCNone(tFile, offset, size)
-	if err == nil {
-		buf := make([]byte, 0, len(srcBuf))
-		pos := 0
-		for pos < len(srcBuf) {
-			headerByte := int(srcBuf[pos])
-			if headerByte > 127 {
-				var copyCount int
-				copyCount = 256 - headerByte
-				copyByte := srcBuf[pos+1]
-				for i := 0; i <= copyCount; i++ {
-					buf = append(buf, copyByte)
-				}
-				pos += 2
-			} else {
-				headerByte++
-				for i := 0; i < headerByte; i++ {
-					pos++
-					buf = append(buf, srcBuf[pos])
-				}
-				pos++
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 0, minInt(len(srcBuf), int(limit)))
+	pos := 0
+	for pos < len(srcBuf) && int64(len(buf)) < limit {
+		headerByte := int(srcBuf[pos])
+		if headerByte == 128 {
+			// no operation
+			pos++
+		} else if headerByte > 128 {
+			if pos+1 >= len(srcBuf) {
+				return nil, gEC(WithKind(ErrCorrupt), WithFunction("readCPackBits"), WithErrorText(fmt.Sprintf("the run at %d is cut", pos)))
+			}
+			copyCount := 256 - headerByte
+			copyByte := srcBuf[pos+1]
+			for i := 0; i <= copyCount; i++ {
+				buf = append(buf, copyByte)
 			}
+			pos += 2
+		} else {
+			headerByte++
+			if pos+1+headerByte > len(srcBuf) {
+				return nil, gEC(WithKind(ErrCorrupt), WithFunction("readCPackBits"), WithErrorText(fmt.Sprintf("the literal at %d is cut", pos)))
+			}
+			buf = append(buf, srcBuf[pos+1:pos+1+headerByte]...)
+			pos += 1 + headerByte
 		}
-		return buf, nil
 	}
-	return nil, err
+	return buf, nil
 }
@@ -0,0 +1,234 @@
+package GeoTiff
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// https://datatracker.ietf.org/doc/html/rfc7946
+// the coordinates are taken as in the CRS of the raster, there is no reprojection
+
+type GeoJSONGeometry struct {
+	Type        string            `json:"type"`
+	Coordinates json.RawMessage   `json:"coordinates,omitempty"`
+	Geometries  []GeoJSONGeometry `json:"geometries,omitempty"`
+}
+
+type GeoJSONFeature struct {
+	Type       string                 `json:"type"`
+	ID         interface{}            `json:"id,omitempty"`
+	Geometry   *GeoJSONGeometry       `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type GeoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []GeoJSONFeature `json:"features"`
+}
+
+// ParseGeoJSON accept a FeatureCollection, a Feature or a bare geometry
+// and always return a FeatureCollection
+func ParseGeoJSON(data []byte) (*GeoJSONFeatureCollection, error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return nil, gEC(WithFunction("ParseGeoJSON"), WithError(err))
+	}
+	switch head.Type {
+	case "FeatureCollection":
+		var fc GeoJSONFeatureCollection
+		if err := json.Unmarshal(data, &fc); err != nil {
+			return nil, gEC(WithFunction("ParseGeoJSON"), WithError(err))
+		}
+		return &fc, nil
+	case "Feature":
+		var f GeoJSONFeature
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, gEC(WithFunction("ParseGeoJSON"), WithError(err))
+		}
+		return NewFeatureCollection(f), nil
+	case "Point", "MultiPoint", "LineString", "MultiLineString", "Polygon", "MultiPolygon", "GeometryCollection":
+		var g GeoJSONGeometry
+		if err := json.Unmarshal(data, &g); err != nil {
+			return nil, gEC(WithFunction("ParseGeoJSON"), WithError(err))
+		}
+		return NewFeatureCollection(NewFeature(g, nil)), nil
+	}
+	return nil, gEC(WithFunction("ParseGeoJSON"), WithErrorText(fmt.Sprintf("unknown GeoJSON type [%s]", head.Type)))
+}
+
+func NewFeatureCollection(features ...GeoJSONFeature) *GeoJSONFeatureCollection {
+	return &GeoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: features,
+	}
+}
+
+func NewFeature(geometry GeoJSONGeometry, properties map[string]interface{}) GeoJSONFeature {
+	if properties == nil {
+		properties = map[string]interface{}{}
+	}
+	return GeoJSONFeature{
+		Type:       "Feature",
+		Geometry:   &geometry,
+		Properties: properties,
+	}
+}
+
+func newGeometry(geometryType string, coordinates interface{}) GeoJSONGeometry {
+	raw, _ := json.Marshal(coordinates)
+	return GeoJSONGeometry{
+		Type:        geometryType,
+		Coordinates: raw,
+	}
+}
+
+func NewPoint(point [2]float64) GeoJSONGeometry {
+	return newGeometry("Point", point)
+}
+func NewLineString(line [][2]float64) GeoJSONGeometry {
+	return newGeometry("LineString", line)
+}
+func NewMultiLineString(lines [][][2]float64) GeoJSONGeometry {
+	return newGeometry("MultiLineString", lines)
+}
+
+// NewPolygon the first ring is the exterior ring, the others are holes
+func NewPolygon(rings [][][2]float64) GeoJSONGeometry {
+	return newGeometry("Polygon", rings)
+}
+func NewMultiPolygon(polygons [][][][2]float64) GeoJSONGeometry {
+	return newGeometry("MultiPolygon", polygons)
+}
+
+// the position may have z, only x and y are kept
+func toXY(positions [][]float64) ([][2]float64, error) {
+	ret := make([][2]float64, len(positions))
+	for i, p := range positions {
+		if len(p) < 2 {
+			return nil, gEC(WithFunction("toXY"), WithErrorText(fmt.Sprintf("position %v require at least 2 numbers", p)))
+		}
+		ret[i] = [2]float64{p[0], p[1]}
+	}
+	return ret, nil
+}
+
+// Points return the positions of Point and MultiPoint
+func (g GeoJSONGeometry) Points() ([][2]float64, error) {
+	switch g.Type {
+	case "Point":
+		var p []float64
+		if err := json.Unmarshal(g.Coordinates, &p); err != nil {
+			return nil, gEC(WithFunction("GeoJSONGeometry.Points"), WithError(err))
+		}
+		return toXY([][]float64{p})
+	case "MultiPoint":
+		var ps [][]float64
+		if err := json.Unmarshal(g.Coordinates, &ps); err != nil {
+			return nil, gEC(WithFunction("GeoJSONGeometry.Points"), WithError(err))
+		}
+		return toXY(ps)
+	case "GeometryCollection":
+		var ret [][2]float64
+		for _, sub := range g.Geometries {
+			ps, err := sub.Points()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, ps...)
+		}
+		return ret, nil
+	}
+	return nil, nil
+}
+
+// Lines return the lines of LineString and MultiLineString
+func (g GeoJSONGeometry) Lines() ([][][2]float64, error) {
+	switch g.Type {
+	case "LineString":
+		var l [][]float64
+		if err := json.Unmarshal(g.Coordinates, &l); err != nil {
+			return nil, gEC(WithFunction("GeoJSONGeometry.Lines"), WithError(err))
+		}
+		line, err := toXY(l)
+		if err != nil {
+			return nil, err
+		}
+		return [][][2]float64{line}, nil
+	case "MultiLineString":
+		var ls [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &ls); err != nil {
+			return nil, gEC(WithFunction("GeoJSONGeometry.Lines"), WithError(err))
+		}
+		ret := make([][][2]float64, len(ls))
+		for i, l := range ls {
+			var err error
+			if ret[i], err = toXY(l); err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	case "GeometryCollection":
+		var ret [][][2]float64
+		for _, sub := range g.Geometries {
+			ls, err := sub.Lines()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, ls...)
+		}
+		return ret, nil
+	}
+	return nil, nil
+}
+
+// Polygons return the polygons of Polygon and MultiPolygon, every polygon is a list of rings
+func (g GeoJSONGeometry) Polygons() ([][][][2]float64, error) {
+	toRings := func(rs [][][]float64) ([][][2]float64, error) {
+		rings := make([][][2]float64, len(rs))
+		for i, r := range rs {
+			var err error
+			if rings[i], err = toXY(r); err != nil {
+				return nil, err
+			}
+		}
+		return rings, nil
+	}
+	switch g.Type {
+	case "Polygon":
+		var rs [][][]float64
+		if err := json.Unmarshal(g.Coordinates, &rs); err != nil {
+			return nil, gEC(WithFunction("GeoJSONGeometry.Polygons"), WithError(err))
+		}
+		rings, err := toRings(rs)
+		if err != nil {
+			return nil, err
+		}
+		return [][][][2]float64{rings}, nil
+	case "MultiPolygon":
+		var ps [][][][]float64
+		if err := json.Unmarshal(g.Coordinates, &ps); err != nil {
+			return nil, gEC(WithFunction("GeoJSONGeometry.Polygons"), WithError(err))
+		}
+		ret := make([][][][2]float64, len(ps))
+		for i, rs := range ps {
+			var err error
+			if ret[i], err = toRings(rs); err != nil {
+				return nil, err
+			}
+		}
+		return ret, nil
+	case "GeometryCollection":
+		var ret [][][][2]float64
+		for _, sub := range g.Geometries {
+			ps, err := sub.Polygons()
+			if err != nil {
+				return nil, err
+			}
+			ret = append(ret, ps...)
+		}
+		return ret, nil
+	}
+	return nil, nil
+}
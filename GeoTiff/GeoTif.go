@@ -28,6 +28,9 @@ func (gAttribute geoAttribute) Bytes() uint32 {
 
 var geoFileAttributeSize = int64(12)
 
+// maxAttributeBytes is the largest value of an attribute which is read
+const maxAttributeBytes = 256 << 20
+
 type geoAttributeValue struct {
 	rValue interface{}
 	BYTE   []uint8
@@ -57,6 +60,8 @@ type Meta struct {
 	NodataValue       string
 	RasterPixelIsArea bool
 	EPSGCode          uint
+	// orientation is how the pixels of the file are shown, Columns and Rows are swapped for 5~8
+	orientation orientation
 }
 type GeoTif struct {
 	// 文件路径
@@ -68,6 +73,17 @@ type GeoTif struct {
 	Meta         Meta
 	Data         GeoData
 	Transform    transform
+	Metadata     GDALMetadata
+
+	blockCache *BlockCache
+	// cacheFile is the name of the file in the keys of blockCache, see fileCacheKey
+	cacheFile string
+	// fileSize is the size of tFile, 0 when it is unknown
+	fileSize int64
+	// maxPixels is set by WithMaxPixels, 0 is no limit
+	maxPixels int64
+	// progress is set by WithProgress
+	progress func(done, total int)
 }
 
 func (g GeoTif) String() string {
@@ -118,10 +134,16 @@ func (gAttributes GeoAttributes) toString(indent int) string {
 var gEC = NewGeoErrorCreator("")
 
 func (geoTif GeoTif) readFile(offset FileOffset, dataLen int) ([]byte, error) {
+	if offset < 0 || dataLen < 0 || geoTif.fileSize > 0 && offset+int64(dataLen) > geoTif.fileSize {
+		return nil, gEC(WithKind(ErrCorrupt), WithFunction("readFile"), WithErrorText(fmt.Sprintf("read [%d] bytes at [%d], but the file has [%d] bytes", dataLen, offset, geoTif.fileSize)))
+	}
 	data := make([]byte, dataLen, dataLen)
 	n, err := geoTif.tFile.ReadAt(data, offset)
+	if err != nil && err != io.EOF {
+		return data, gEC(WithFunction("readFile"), WithError(err))
+	}
 	if n != dataLen {
-		return data, gEC(WithFunction("readFile"), WithErrorText(fmt.Sprintf("read [%d] bytes, but only get [%d] bytes", dataLen, n)))
+		return data, gEC(WithKind(ErrCorrupt), WithFunction("readFile"), WithErrorText(fmt.Sprintf("read [%d] bytes, but only get [%d] bytes", dataLen, n)))
 	}
 	if err != nil {
 		return data, gEC(WithFunction("readFile"), WithError(err))
@@ -134,12 +156,12 @@ func (attributes GeoAttributes) getAttributeByTag(tag AttributeTag) (geoAttribut
 			return attributes[i], nil
 		}
 	}
-	return geoAttribute{}, gEC(WithFunction("getAttributeByTag"), WithMsg(fmt.Sprintf("can not found attribute [%v]", tag)))
+	return geoAttribute{}, gEC(WithKind(ErrMissingTag), WithFunction("getAttributeByTag"), WithMsg(fmt.Sprintf("can not found attribute [%v]", tag)))
 }
 
 func newGeoAttribute(data []byte, order binary.ByteOrder) (*geoAttribute, error) {
 	if len(data) != 12 {
-		return nil, gEC(WithFunction("readFile"), WithErrorText(fmt.Sprintf("require data len is 12, but data len is %d", len(data))))
+		return nil, gEC(WithKind(ErrCorrupt), WithFunction("readFile"), WithErrorText(fmt.Sprintf("require data len is 12, but data len is %d", len(data))))
 	}
 	gAttribute := geoAttribute{}
 	gAttribute.Tag = AttributeTag(order.Uint16(data[0:2]))
@@ -160,22 +182,29 @@ func (gAttribute *geoAttribute) parseValue(order binary.ByteOrder) error {
 		DOUBLE: nil,
 		uint:   []uint{0},
 	}
+	size := gAttribute.Type.Bytes()
+	if size == 0 {
+		return gEC(WithKind(ErrCorrupt), WithFunction("parseValue"), WithError(errors.New(fmt.Sprintf("unknow datatype [%v]", gAttribute.Type))), WithMsg("to default"))
+	}
+	if uint64(len(gAttribute.SourceValue)) < uint64(gAttribute.Len)*uint64(size) {
+		return gEC(WithKind(ErrCorrupt), WithFunction("parseValue"), WithErrorText(fmt.Sprintf("attribute [%d] has %d values of %d bytes, but only %d bytes", gAttribute.Tag, gAttribute.Len, size, len(gAttribute.SourceValue))))
+	}
 	switch gAttribute.Type {
-	case BYTE:
-		gAttribute.GeoAttributeValue.BYTE = gAttribute.SourceValue
-		gAttribute.GeoAttributeValue.rValue = gAttribute.SourceValue
+	case BYTE, SBYTE, UNDEFINED:
+		gAttribute.GeoAttributeValue.BYTE = gAttribute.SourceValue[:gAttribute.Len]
+		gAttribute.GeoAttributeValue.rValue = gAttribute.GeoAttributeValue.BYTE
 		gAttribute.GeoAttributeValue.uint = make([]uint, gAttribute.Len)
 		for i := 0; i < int(gAttribute.Len); i++ {
 			gAttribute.GeoAttributeValue.uint[i] = uint(gAttribute.SourceValue[i])
 		}
 	case ASCII:
-		gAttribute.GeoAttributeValue.ASCII = string(gAttribute.SourceValue)
-		gAttribute.GeoAttributeValue.rValue = string(gAttribute.SourceValue)
+		gAttribute.GeoAttributeValue.ASCII = string(gAttribute.SourceValue[:gAttribute.Len])
+		gAttribute.GeoAttributeValue.rValue = gAttribute.GeoAttributeValue.ASCII
 		gAttribute.GeoAttributeValue.uint = make([]uint, gAttribute.Len)
 		for i := 0; i < int(gAttribute.Len); i++ {
 			gAttribute.GeoAttributeValue.uint[i] = uint(gAttribute.SourceValue[i])
 		}
-	case SHORT:
+	case SHORT, SSHORT:
 		gAttribute.GeoAttributeValue.SHORT = make([]uint16, gAttribute.Len)
 		gAttribute.GeoAttributeValue.uint = make([]uint, gAttribute.Len)
 		for i := 0; i < int(gAttribute.Len); i++ {
@@ -184,7 +213,7 @@ func (gAttribute *geoAttribute) parseValue(order binary.ByteOrder) error {
 			gAttribute.GeoAttributeValue.uint[i] = uint(v)
 		}
 		gAttribute.GeoAttributeValue.rValue = gAttribute.GeoAttributeValue.SHORT
-	case LONG:
+	case LONG, SLONG:
 		gAttribute.GeoAttributeValue.LONG = make([]uint32, gAttribute.Len)
 		gAttribute.GeoAttributeValue.uint = make([]uint, gAttribute.Len)
 		for i := 0; i < int(gAttribute.Len); i++ {
@@ -193,6 +222,19 @@ func (gAttribute *geoAttribute) parseValue(order binary.ByteOrder) error {
 			gAttribute.GeoAttributeValue.uint[i] = uint(v)
 		}
 		gAttribute.GeoAttributeValue.rValue = gAttribute.GeoAttributeValue.LONG
+	case RATIONAL, SRATIONAL:
+		// the fractions are kept as DOUBLE
+		gAttribute.GeoAttributeValue.DOUBLE = make([]float64, gAttribute.Len)
+		for i := 0; i < int(gAttribute.Len); i++ {
+			num := order.Uint32(gAttribute.SourceValue[i*8 : i*8+4])
+			den := order.Uint32(gAttribute.SourceValue[i*8+4 : i*8+8])
+			if gAttribute.Type == SRATIONAL {
+				gAttribute.GeoAttributeValue.DOUBLE[i] = float64(int32(num)) / float64(int32(den))
+			} else {
+				gAttribute.GeoAttributeValue.DOUBLE[i] = float64(num) / float64(den)
+			}
+		}
+		gAttribute.GeoAttributeValue.rValue = gAttribute.GeoAttributeValue.DOUBLE
 	case FLOAT:
 		gAttribute.GeoAttributeValue.FLOAT = make([]float32, gAttribute.Len)
 		for i := 0; i < int(gAttribute.Len); i++ {
@@ -206,33 +248,78 @@ func (gAttribute *geoAttribute) parseValue(order binary.ByteOrder) error {
 		}
 		gAttribute.GeoAttributeValue.rValue = gAttribute.GeoAttributeValue.DOUBLE
 	default:
-		return gEC(WithFunction("parseValue"), WithError(errors.New(fmt.Sprintf("unknow datatype [%v]", gAttribute.Type))), WithMsg("to default"))
+		return gEC(WithKind(ErrCorrupt), WithFunction("parseValue"), WithError(errors.New(fmt.Sprintf("unknow datatype [%v]", gAttribute.Type))), WithMsg("to default"))
 	}
 	return nil
 }
 func (gAttribute geoAttribute) toFloat64() []float64 {
-	if gAttribute.Type == DOUBLE {
+	if gAttribute.Type == DOUBLE || gAttribute.Type == RATIONAL || gAttribute.Type == SRATIONAL {
 		return gAttribute.GeoAttributeValue.DOUBLE
 	} else if gAttribute.Type == FLOAT {
-		var ret = make([]float64, gAttribute.Len)
-		for i := 0; i < int(gAttribute.Len); i++ {
-			ret[i] = float64(gAttribute.GeoAttributeValue.FLOAT[i])
+		var ret = make([]float64, len(gAttribute.GeoAttributeValue.FLOAT))
+		for i, v := range gAttribute.GeoAttributeValue.FLOAT {
+			ret[i] = float64(v)
 		}
 		return ret
 	} else {
-		var ret = make([]float64, gAttribute.Len)
-		for i := 0; i < int(gAttribute.Len); i++ {
-			ret = append(ret, float64(gAttribute.GeoAttributeValue.uint[i]))
+		var ret = make([]float64, len(gAttribute.GeoAttributeValue.uint))
+		for i, v := range gAttribute.GeoAttributeValue.uint {
+			ret[i] = float64(v)
 		}
 		return ret
 	}
 }
 
-// todo: for save to file
-func (gAttribute geoAttribute) toBytes() {
-
+// toBytes encode the attribute as the 12 bytes IFD entry
+// the value is put in the entry when it fits in 4 bytes, otherwise gAttribute.Offset is written
+func (gAttribute geoAttribute) toBytes(order binary.ByteOrder) []byte {
+	data := make([]byte, 12)
+	order.PutUint16(data[0:2], uint16(gAttribute.Tag))
+	order.PutUint16(data[2:4], uint16(gAttribute.Type))
+	order.PutUint32(data[4:8], gAttribute.Len)
+	if gAttribute.Bytes() > 4 {
+		order.PutUint32(data[8:12], gAttribute.Offset)
+	} else {
+		copy(data[8:12], gAttribute.SourceValue)
+	}
+	return data
 }
 
 func (gAttribute geoAttribute) getValue() interface{} {
 	return gAttribute.GeoAttributeValue.rValue
 }
+
+// NewGeoTif create a gray GeoTif in memory, the Data is filled with 0
+func NewGeoTif(columns, rows, bitsPerSample, sampleFormat uint) *GeoTif {
+	return &GeoTif{
+		byteOrder: binary.LittleEndian,
+		Meta: Meta{
+			Columns:           columns,
+			Rows:              rows,
+			BitsPerSample:     []uint{bitsPerSample},
+			samplesPerPixel:   1,
+			SampleFormat:      sampleFormat,
+			PhotometricInterp: PI_BlackIsZero,
+			mode:              mGray,
+			RasterPixelIsArea: true,
+		},
+		Data: GeoData{
+			Data: make([]float64, columns*rows),
+		},
+		Transform: transform{
+			Data: [6]float64{0, 1, 0, 0, 0, 1},
+		},
+		Metadata: NewGDALMetadata(),
+	}
+}
+
+// NewGeoTifLike create a GeoTif in memory on the same grid and CRS of the template
+func NewGeoTifLike(template *GeoTif, bitsPerSample, sampleFormat uint) *GeoTif {
+	g := NewGeoTif(template.Meta.Columns, template.Meta.Rows, bitsPerSample, sampleFormat)
+	g.Transform = template.Transform
+	g.GeoKeys = make(GeoAttributes, len(template.GeoKeys))
+	copy(g.GeoKeys, template.GeoKeys)
+	g.Meta.EPSGCode = template.Meta.EPSGCode
+	g.Meta.RasterPixelIsArea = template.Meta.RasterPixelIsArea
+	return g
+}
@@ -0,0 +1,138 @@
+package GeoTiff
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// PixelToGeo convert the pixel/line (col, row) to the georeferenced coordinate
+// (0, 0) is the top left corner of the top left pixel, (0.5, 0.5) is its center
+func (t transform) PixelToGeo(col, row float64) (x, y float64) {
+	x = t.Data[0] + col*t.Data[1] + row*t.Data[2]
+	y = t.Data[3] + col*t.Data[4] + row*t.Data[5]
+	return
+}
+
+// GeoToPixel is the inverse of PixelToGeo
+func (t transform) GeoToPixel(x, y float64) (col, row float64) {
+	det := t.Data[1]*t.Data[5] - t.Data[2]*t.Data[4]
+	if det == 0 {
+		return math.NaN(), math.NaN()
+	}
+	dx := x - t.Data[0]
+	dy := y - t.Data[3]
+	col = (dx*t.Data[5] - dy*t.Data[2]) / det
+	row = (dy*t.Data[1] - dx*t.Data[4]) / det
+	return
+}
+
+// Bounds is the extent of the raster in its CRS
+type Bounds struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+func (b Bounds) Width() float64 {
+	return b.MaxX - b.MinX
+}
+func (b Bounds) Height() float64 {
+	return b.MaxY - b.MinY
+}
+
+// Intersects report whether the two bounds overlap
+func (b Bounds) Intersects(o Bounds) bool {
+	return b.MinX < o.MaxX && o.MinX < b.MaxX && b.MinY < o.MaxY && o.MinY < b.MaxY
+}
+
+// Union return the bounds which contain both
+func (b Bounds) Union(o Bounds) Bounds {
+	return Bounds{
+		MinX: math.Min(b.MinX, o.MinX),
+		MinY: math.Min(b.MinY, o.MinY),
+		MaxX: math.Max(b.MaxX, o.MaxX),
+		MaxY: math.Max(b.MaxY, o.MaxY),
+	}
+}
+
+// Bounds return the extent of the four corners of the raster
+func (g *GeoTif) Bounds() Bounds {
+	w := float64(g.Meta.Columns)
+	h := float64(g.Meta.Rows)
+	b := Bounds{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+	for _, corner := range [][2]float64{{0, 0}, {w, 0}, {0, h}, {w, h}} {
+		x, y := g.Transform.PixelToGeo(corner[0], corner[1])
+		b.MinX = math.Min(b.MinX, x)
+		b.MinY = math.Min(b.MinY, y)
+		b.MaxX = math.Max(b.MaxX, x)
+		b.MaxY = math.Max(b.MaxY, y)
+	}
+	return b
+}
+
+// PixelSize return the size of a pixel along x and y, both are positive
+func (g *GeoTif) PixelSize() (float64, float64) {
+	return math.Hypot(g.Transform.Data[1], g.Transform.Data[4]), math.Hypot(g.Transform.Data[2], g.Transform.Data[5])
+}
+
+// At return the value of (col, row), ok is false when it is out of the raster or the pixels are not read (see Sample)
+func (g *GeoTif) At(col, row int) (float64, bool) {
+	if col < 0 || row < 0 || col >= int(g.Meta.Columns) || row >= int(g.Meta.Rows) ||
+		len(g.Data.Data) != int(g.Meta.Columns)*int(g.Meta.Rows) {
+		return 0, false
+	}
+	return g.Data.Data[row*int(g.Meta.Columns)+col], true
+}
+
+// pixels make sure Data has the Columns*Rows values before the methods which work on the pixels in memory,
+// the tif opened by OpenGeoTifHeader is read here, the raster which is not read from a file should be filled
+func (g *GeoTif) pixels() error {
+	if len(g.Data.Data) == int(g.Meta.Columns)*int(g.Meta.Rows) {
+		return nil
+	}
+	if g.tFile == nil {
+		return gEC(WithKind(ErrOutOfRange), WithFunction("GeoTif.pixels"), WithErrorText(fmt.Sprintf("Data has %d values, but the raster is %dx%d", len(g.Data.Data), g.Meta.Columns, g.Meta.Rows)))
+	}
+	if err := g.readData(context.Background()); err != nil {
+		return gEC(WithFunction("GeoTif.pixels"), WithError(err), WithMsg("the pixels are not read"))
+	}
+	return nil
+}
+
+// IsNodata report whether v is nodata of the raster, NaN is always nodata
+func (g *GeoTif) IsNodata(v float64) bool {
+	if math.IsNaN(v) {
+		return true
+	}
+	nodata, ok := g.Meta.Nodata()
+	return ok && v == nodata
+}
+
+// CheckSameGrid return an error when the two rasters are not on the same grid or CRS
+func (g *GeoTif) CheckSameGrid(o *GeoTif) error {
+	if g.Meta.Columns != o.Meta.Columns || g.Meta.Rows != o.Meta.Rows {
+		return gEC(WithFunction("CheckSameGrid"), WithErrorText(fmt.Sprintf("size %dx%d is not the same as %dx%d", g.Meta.Columns, g.Meta.Rows, o.Meta.Columns, o.Meta.Rows)))
+	}
+	if g.Meta.EPSGCode != o.Meta.EPSGCode {
+		return gEC(WithFunction("CheckSameGrid"), WithErrorText(fmt.Sprintf("EPSG %d is not the same as %d", g.Meta.EPSGCode, o.Meta.EPSGCode)))
+	}
+	if err := g.CheckSameResolution(o); err != nil {
+		return gEC(WithFunction("CheckSameGrid"), WithError(err))
+	}
+	// the origin may differ at most 1% of a pixel
+	px, py := g.PixelSize()
+	if math.Abs(g.Transform.Data[0]-o.Transform.Data[0]) > px*0.01 || math.Abs(g.Transform.Data[3]-o.Transform.Data[3]) > py*0.01 {
+		return gEC(WithFunction("CheckSameGrid"), WithErrorText(fmt.Sprintf("origin (%v, %v) is not the same as (%v, %v)", g.Transform.Data[0], g.Transform.Data[3], o.Transform.Data[0], o.Transform.Data[3])))
+	}
+	return nil
+}
+
+// CheckSameResolution return an error when the two rasters have different pixel size or rotation
+func (g *GeoTif) CheckSameResolution(o *GeoTif) error {
+	for _, i := range []int{1, 2, 4, 5} {
+		a, b := g.Transform.Data[i], o.Transform.Data[i]
+		if math.Abs(a-b) > 1e-9*math.Max(math.Abs(a), math.Abs(b)) {
+			return gEC(WithFunction("CheckSameResolution"), WithErrorText(fmt.Sprintf("transform %v is not the same as %v", g.Transform.Data, o.Transform.Data)))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,186 @@
+package GeoTiff
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// the adapters below make a GeoTif usable by image/png, image/draw and so on
+// they read g.Data.Data when At is called, nothing is copied,
+// the tif opened by OpenGeoTifHeader is read when the adapter is created
+
+// BandImage is the gray view of a band, the values in [Min, Max] are mapped linearly to 0~65535
+// nodata is black
+type BandImage struct {
+	g        *GeoTif
+	Min, Max float64
+}
+
+// BandImage return the gray view, Min and Max are the range which is stretched
+func (g *GeoTif) BandImage(min, max float64) (*BandImage, error) {
+	if err := g.pixels(); err != nil {
+		return nil, gEC(WithFunction("GeoTif.BandImage"), WithError(err))
+	}
+	return &BandImage{g: g, Min: min, Max: max}, nil
+}
+
+func (bi *BandImage) ColorModel() color.Model {
+	return color.Gray16Model
+}
+func (bi *BandImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, int(bi.g.Meta.Columns), int(bi.g.Meta.Rows))
+}
+func (bi *BandImage) Gray16At(x, y int) color.Gray16 {
+	v, ok := bi.g.At(x, y)
+	if !ok || bi.g.IsNodata(v) {
+		return color.Gray16{}
+	}
+	return color.Gray16{Y: stretch16(v, bi.Min, bi.Max)}
+}
+func (bi *BandImage) At(x, y int) color.Color {
+	return bi.Gray16At(x, y)
+}
+
+// stretch16 map v in [min, max] to 0~65535, min > max inverts the gray
+func stretch16(v, min, max float64) uint16 {
+	if max == min {
+		if v >= max {
+			return math.MaxUint16
+		}
+		return 0
+	}
+	t := (v - min) / (max - min)
+	return uint16(math.Round(math.Max(0, math.Min(1, t)) * math.MaxUint16))
+}
+
+// RGBImage is the composite of three bands, every band is stretched by its own range
+// the pixel is transparent when any band is nodata
+type RGBImage struct {
+	Bands [3]*GeoTif
+	Min   [3]float64
+	Max   [3]float64
+}
+
+// NewRGBImage compose the bands on the same grid, Min and Max are the stretch range of every band
+func NewRGBImage(r, g, b *GeoTif, min, max [3]float64) (*RGBImage, error) {
+	var gEC = NewGeoErrorCreator("NewRGBImage")
+	if err := r.CheckSameGrid(g); err != nil {
+		return nil, gEC(WithError(err))
+	}
+	if err := r.CheckSameGrid(b); err != nil {
+		return nil, gEC(WithError(err))
+	}
+	for _, band := range []*GeoTif{r, g, b} {
+		if err := band.pixels(); err != nil {
+			return nil, gEC(WithError(err))
+		}
+	}
+	return &RGBImage{Bands: [3]*GeoTif{r, g, b}, Min: min, Max: max}, nil
+}
+
+func (ri *RGBImage) ColorModel() color.Model {
+	return color.RGBA64Model
+}
+func (ri *RGBImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, int(ri.Bands[0].Meta.Columns), int(ri.Bands[0].Meta.Rows))
+}
+func (ri *RGBImage) RGBA64At(x, y int) color.RGBA64 {
+	var c [3]uint16
+	for k, band := range ri.Bands {
+		v, ok := band.At(x, y)
+		if !ok || band.IsNodata(v) {
+			return color.RGBA64{}
+		}
+		c[k] = stretch16(v, ri.Min[k], ri.Max[k])
+	}
+	return color.RGBA64{R: c[0], G: c[1], B: c[2], A: math.MaxUint16}
+}
+func (ri *RGBImage) At(x, y int) color.Color {
+	return ri.RGBA64At(x, y)
+}
+
+// packedImage is the view of RGB, RGBA and paletted tifs, whose Data is the packed 0xAARRGGBB
+type packedImage struct {
+	g *GeoTif
+}
+
+func unpackARGB(v float64) color.NRGBA {
+	p := uint32(v)
+	return color.NRGBA{R: uint8(p >> 16), G: uint8(p >> 8), B: uint8(p), A: uint8(p >> 24)}
+}
+
+func (pi packedImage) ColorModel() color.Model {
+	return color.NRGBAModel
+}
+func (pi packedImage) Bounds() image.Rectangle {
+	return image.Rect(0, 0, int(pi.g.Meta.Columns), int(pi.g.Meta.Rows))
+}
+func (pi packedImage) At(x, y int) color.Color {
+	v, ok := pi.g.At(x, y)
+	if !ok {
+		return color.NRGBA{}
+	}
+	return unpackARGB(v)
+}
+
+// Paletted convert the paletted tif to *image.Paletted with Meta.palette
+func (g *GeoTif) Paletted() (*image.Paletted, error) {
+	if g.Meta.mode != mPaletted {
+		return nil, gEC(WithFunction("GeoTif.Paletted"), WithErrorText("the image is not paletted"))
+	}
+	if err := g.pixels(); err != nil {
+		return nil, gEC(WithFunction("GeoTif.Paletted"), WithError(err))
+	}
+	palette := make(color.Palette, len(g.Meta.palette))
+	index := make(map[uint32]uint8, len(g.Meta.palette))
+	for i, p := range g.Meta.palette {
+		palette[i] = unpackARGB(float64(p))
+		if _, ok := index[p]; !ok {
+			index[p] = uint8(i)
+		}
+	}
+	img := image.NewPaletted(image.Rect(0, 0, int(g.Meta.Columns), int(g.Meta.Rows)), palette)
+	for i, v := range g.Data.Data {
+		img.Pix[i] = index[uint32(v)]
+	}
+	return img, nil
+}
+
+// Image return the natural view of the tif
+// RGB(A) is NRGBA, paletted is *image.Paletted, gray is BandImage stretched by the range of the data type
+// (8 and 16 bits integer) or the range of the valid data
+func (g *GeoTif) Image() (image.Image, error) {
+	if err := g.pixels(); err != nil {
+		return nil, gEC(WithFunction("GeoTif.Image"), WithError(err))
+	}
+	switch g.Meta.mode {
+	case mRGB, mRGBA, mNRGBA:
+		return packedImage{g: g}, nil
+	case mPaletted:
+		return g.Paletted()
+	}
+	bits := uint(0)
+	if len(g.Meta.BitsPerSample) > 0 {
+		bits = g.Meta.BitsPerSample[0]
+	}
+	bi := &BandImage{g: g}
+	switch {
+	case g.Meta.SampleFormat == SampleFormatUint && bits == 8:
+		bi.Max = math.MaxUint8
+	case g.Meta.SampleFormat == SampleFormatUint && bits == 16:
+		bi.Max = math.MaxUint16
+	default:
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, v := range g.Data.Data {
+			if !g.IsNodata(v) {
+				min, max = math.Min(min, v), math.Max(max, v)
+			}
+		}
+		bi.Min, bi.Max = min, max
+	}
+	if g.Meta.mode == mGrayInvert {
+		bi.Min, bi.Max = bi.Max, bi.Min
+	}
+	return bi, nil
+}
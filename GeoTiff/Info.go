@@ -0,0 +1,361 @@
+package GeoTiff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Info is the report of a tif like gdalinfo, it can be encoded by encoding/json or printed by String
+type Info struct {
+	File        string     `json:"file,omitempty"`
+	Columns     uint       `json:"columns"`
+	Rows        uint       `json:"rows"`
+	ByteOrder   string     `json:"byteOrder"`
+	Compression string     `json:"compression"`
+	Predictor   uint       `json:"predictor,omitempty"`
+	Tiled       bool       `json:"tiled"`
+	BlockWidth  int        `json:"blockWidth"`
+	BlockHeight int        `json:"blockHeight"`
+	Blocks      int        `json:"blocks"`
+	EPSG        uint       `json:"epsg"`
+	Geographic  bool       `json:"geographic"`
+	Transform   [6]float64 `json:"transform"`
+	PixelSize   [2]float64 `json:"pixelSize"`
+	Bounds      [4]float64 `json:"bounds"`
+	// LonLatBounds is nil when the CRS can not be projected to longitude/latitude
+	LonLatBounds *[4]float64       `json:"lonLatBounds,omitempty"`
+	Nodata       *float64          `json:"nodata"`
+	Bands        []BandInfo        `json:"bands"`
+	Overviews    []OverviewInfo    `json:"overviews,omitempty"`
+	Metadata     map[string]string `json:"metadata,omitempty"`
+	// Domains is the metadata of the named domains, e.g. IMAGE_STRUCTURE
+	Domains map[string]map[string]string `json:"domains,omitempty"`
+}
+
+// BandInfo is a sample of the pixels, a gray tif has one band and a RGB tif has three or four
+type BandInfo struct {
+	Index       int     `json:"index"`
+	Type        string  `json:"type"`
+	ColorInterp string  `json:"colorInterp"`
+	Description string  `json:"description,omitempty"`
+	Scale       float64 `json:"scale"`
+	Offset      float64 `json:"offset"`
+	Unit        string  `json:"unit,omitempty"`
+	// Stats is only computed by WithInfoStats for the gray tifs
+	Stats *BandStats `json:"stats,omitempty"`
+}
+
+// BandStats is the statistics of the valid (not nodata) pixels, Min, Max, Mean and StdDev are NaN when Count is 0
+type BandStats struct {
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"stdDev"`
+	Count  int     `json:"count"`
+	// NodataCount is the number of the nodata pixels
+	NodataCount int `json:"nodataCount"`
+}
+
+// OverviewInfo is the size of an overview, the reduced resolution IFD after the first one
+type OverviewInfo struct {
+	Columns uint `json:"columns"`
+	Rows    uint `json:"rows"`
+}
+
+type infoConfig struct {
+	stats bool
+}
+
+type InfoOptions func(ic *infoConfig)
+
+// WithInfoStats compute the statistics of the band, the pixels are read block by block when they are not in memory
+func WithInfoStats(stats bool) InfoOptions {
+	return func(ic *infoConfig) {
+		ic.stats = stats
+	}
+}
+
+// maxOverviews is the most IFD which are followed, it stops the loop of a corrupt file
+const maxOverviews = 64
+
+var compressionNames = map[CompressionType]string{
+	cNone:       "NONE",
+	cCCITT:      "CCITT",
+	cG3:         "CCITTFAX3",
+	cG4:         "CCITTFAX4",
+	cLZW:        "LZW",
+	cJPEGOld:    "OJPEG",
+	cJPEG:       "JPEG",
+	cDeflate:    "DEFLATE",
+	cPackBits:   "PACKBITS",
+	cDeflateOld: "DEFLATE",
+}
+
+// dataTypeName is the name of the sample type in GDAL, e.g. Float32
+func dataTypeName(sampleFormat, bits uint) string {
+	switch sampleFormat {
+	case SampleFormatUint:
+		if bits == 8 {
+			return "Byte"
+		}
+		return fmt.Sprintf("UInt%d", bits)
+	case SampleFormatInt:
+		return fmt.Sprintf("Int%d", bits)
+	case SampleFormatFloat:
+		return fmt.Sprintf("Float%d", bits)
+	}
+	return fmt.Sprintf("Unknown%d", bits)
+}
+
+func (m Meta) colorInterps() []string {
+	switch m.mode {
+	case mPaletted:
+		return []string{"Palette"}
+	case mRGB:
+		return []string{"Red", "Green", "Blue"}
+	case mRGBA, mNRGBA:
+		return []string{"Red", "Green", "Blue", "Alpha"}
+	}
+	return []string{"Gray"}
+}
+
+// Info report the size, bands, layout, CRS, extent, nodata, overviews and metadata of the tif
+func (g *GeoTif) Info(opts ...InfoOptions) (*Info, error) {
+	return g.InfoContext(context.Background(), opts...)
+}
+
+// InfoContext is Info which stops the statistics of WithInfoStats at the next block when ctx is done
+func (g *GeoTif) InfoContext(ctx context.Context, opts ...InfoOptions) (*Info, error) {
+	var gEC = NewGeoErrorCreator("GeoTif.Info")
+	cfg := infoConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	layout, err := g.blockLayout()
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	info := &Info{
+		File:        g.FilePath,
+		Columns:     g.Meta.Columns,
+		Rows:        g.Meta.Rows,
+		ByteOrder:   "little endian",
+		Compression: compressionNames[layout.compression],
+		Predictor:   layout.predictor,
+		Tiled:       layout.tiled,
+		BlockWidth:  layout.blockWidth,
+		BlockHeight: layout.blockHeight,
+		Blocks:      layout.count(),
+		EPSG:        g.Meta.EPSGCode,
+		Geographic:  g.IsGeographic(),
+		Transform:   g.Transform.Data,
+		Metadata:    g.Metadata.Items,
+		Domains:     g.Metadata.Domains,
+	}
+	if g.byteOrder != nil && g.byteOrder.String() == "BigEndian" {
+		info.ByteOrder = "big endian"
+	}
+	if info.Compression == "" {
+		info.Compression = fmt.Sprintf("%d", layout.compression)
+	}
+	info.PixelSize[0], info.PixelSize[1] = g.PixelSize()
+	b := g.Bounds()
+	info.Bounds = [4]float64{b.MinX, b.MinY, b.MaxX, b.MaxY}
+	if proj, err := g.projection(); err == nil {
+		lb := Bounds{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+		for _, corner := range [][2]float64{{b.MinX, b.MinY}, {b.MaxX, b.MinY}, {b.MinX, b.MaxY}, {b.MaxX, b.MaxY}} {
+			lon, lat := proj.toLonLat(corner[0], corner[1])
+			lb = lb.Union(Bounds{MinX: lon, MinY: lat, MaxX: lon, MaxY: lat})
+		}
+		info.LonLatBounds = &[4]float64{lb.MinX, lb.MinY, lb.MaxX, lb.MaxY}
+	}
+	if nodata, ok := g.Meta.Nodata(); ok {
+		info.Nodata = &nodata
+	}
+	bits := uint(0)
+	if len(g.Meta.BitsPerSample) > 0 {
+		bits = g.Meta.BitsPerSample[0]
+	}
+	for i, interp := range g.Meta.colorInterps() {
+		band := BandInfo{Index: i + 1, Type: dataTypeName(g.Meta.SampleFormat, bits), ColorInterp: interp, Scale: 1}
+		if bm, ok := g.Metadata.Bands[i]; ok {
+			band.Description = bm.Description
+			band.Scale = bm.Scale
+			band.Offset = bm.Offset
+			band.Unit = bm.Unit
+		}
+		info.Bands = append(info.Bands, band)
+	}
+	if cfg.stats && len(info.Bands) == 1 && info.Bands[0].ColorInterp == "Gray" {
+		if info.Bands[0].Stats, err = g.bandStats(ctx, layout); err != nil {
+			return nil, gEC(WithError(err))
+		}
+	}
+	if g.tFile != nil && g.GeoTifHeader.offset != 0 {
+		info.Overviews = g.overviews()
+	}
+	return info, nil
+}
+
+// statsSink accumulate the statistics of the blocks
+type statsSink struct {
+	g          *GeoTif
+	stats      BandStats
+	sum, sumSq float64
+}
+
+func (ss *statsSink) WriteBlock(b Block) error {
+	for _, v := range b.Data {
+		if ss.g.IsNodata(v) {
+			ss.stats.NodataCount++
+			continue
+		}
+		ss.stats.Min = math.Min(ss.stats.Min, v)
+		ss.stats.Max = math.Max(ss.stats.Max, v)
+		ss.sum += v
+		ss.sumSq += v * v
+		ss.stats.Count++
+	}
+	return nil
+}
+
+func (g *GeoTif) bandStats(ctx context.Context, layout *blockLayout) (*BandStats, error) {
+	ss := &statsSink{g: g, stats: BandStats{Min: math.Inf(1), Max: math.Inf(-1)}}
+	if err := g.processBlocks(ctx, layout, 0, nil, ss); err != nil {
+		return nil, gEC(WithFunction("GeoTif.bandStats"), WithError(err))
+	}
+	if ss.stats.Count == 0 {
+		ss.stats.Min, ss.stats.Max, ss.stats.Mean, ss.stats.StdDev = math.NaN(), math.NaN(), math.NaN(), math.NaN()
+		return &ss.stats, nil
+	}
+	n := float64(ss.stats.Count)
+	ss.stats.Mean = ss.sum / n
+	ss.stats.StdDev = math.Sqrt(math.Max(0, ss.sumSq/n-ss.stats.Mean*ss.stats.Mean))
+	return &ss.stats, nil
+}
+
+// overviews follow the IFD after the first one, the reduced resolution images (NewSubfileType bit 0) are
+// the overviews and the masks are skipped, the chain stops at the first IFD which can not be read
+func (g *GeoTif) overviews() []OverviewInfo {
+	var ret []OverviewInfo
+	_, next, err := g.readIFD(g.GeoTifHeader.offset)
+	seen := map[int64]bool{g.GeoTifHeader.offset: true}
+	for i := 0; err == nil && next != 0 && !seen[next] && i < maxOverviews; i++ {
+		seen[next] = true
+		var attributes GeoAttributes
+		if attributes, next, err = g.readIFD(next); err != nil {
+			break
+		}
+		first := func(tag AttributeTag) uint {
+			if atr, err := attributes.getAttributeByTag(tag); err == nil && len(atr.GeoAttributeValue.uint) > 0 {
+				return atr.GeoAttributeValue.uint[0]
+			}
+			return 0
+		}
+		if subfile := first(NewSubfileType); subfile&1 == 0 || subfile&4 != 0 {
+			continue
+		}
+		ret = append(ret, OverviewInfo{Columns: first(ImageWidth), Rows: first(ImageLength)})
+	}
+	return ret
+}
+
+// JSON encode the report with indent
+func (info *Info) JSON() ([]byte, error) {
+	// NaN can not be encoded by encoding/json
+	if len(info.Bands) > 0 && info.Bands[0].Stats != nil && info.Bands[0].Stats.Count == 0 {
+		copied := *info
+		copied.Bands = append([]BandInfo(nil), info.Bands...)
+		copied.Bands[0].Stats = &BandStats{NodataCount: info.Bands[0].Stats.NodataCount}
+		info = &copied
+	}
+	b, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return nil, gEC(WithFunction("Info.JSON"), WithError(err))
+	}
+	return b, nil
+}
+
+// String format the report like gdalinfo
+func (info *Info) String() string {
+	var sb strings.Builder
+	line := func(format string, a ...interface{}) {
+		sb.WriteString(fmt.Sprintf(format, a...))
+		sb.WriteString("\n")
+	}
+	line("Driver: GTiff/GeoTIFF")
+	if info.File != "" {
+		line("Files: %s", info.File)
+	}
+	line("Size is %d, %d", info.Columns, info.Rows)
+	if info.EPSG != 0 {
+		line("Coordinate System is EPSG:%d", info.EPSG)
+	} else {
+		line("Coordinate System is unknown")
+	}
+	t := info.Transform
+	if t[2] == 0 && t[4] == 0 {
+		line("Origin = (%.15f,%.15f)", t[0], t[3])
+		line("Pixel Size = (%.15f,%.15f)", t[1], t[5])
+	} else {
+		line("GeoTransform =")
+		line("  %.15g, %.15g, %.15g", t[0], t[1], t[2])
+		line("  %.15g, %.15g, %.15g", t[3], t[4], t[5])
+	}
+	if len(info.Metadata) > 0 {
+		line("Metadata:")
+		for _, k := range sortedKeys(info.Metadata) {
+			line("  %s=%s", k, info.Metadata[k])
+		}
+	}
+	line("Image Structure Metadata:")
+	line("  BYTE_ORDER=%s", info.ByteOrder)
+	line("  COMPRESSION=%s", info.Compression)
+	if info.Predictor > 1 {
+		line("  PREDICTOR=%d", info.Predictor)
+	}
+	line("Corner Coordinates:")
+	b := info.Bounds
+	corner := func(name string, x, y float64) {
+		line("%-12s(%15.7f,%15.7f)", name, x, y)
+	}
+	corner("Upper Left", b[0], b[3])
+	corner("Lower Left", b[0], b[1])
+	corner("Upper Right", b[2], b[3])
+	corner("Lower Right", b[2], b[1])
+	corner("Center", (b[0]+b[2])/2, (b[1]+b[3])/2)
+	if info.LonLatBounds != nil {
+		lb := info.LonLatBounds
+		line("Longitude/Latitude Extent: (%.7f, %.7f) - (%.7f, %.7f)", lb[0], lb[1], lb[2], lb[3])
+	}
+	for _, band := range info.Bands {
+		line("Band %d Block=%dx%d Type=%s, ColorInterp=%s", band.Index, info.BlockWidth, info.BlockHeight, band.Type, band.ColorInterp)
+		if band.Description != "" {
+			line("  Description = %s", band.Description)
+		}
+		if s := band.Stats; s != nil {
+			line("  Minimum=%.3f, Maximum=%.3f, Mean=%.3f, StdDev=%.3f", s.Min, s.Max, s.Mean, s.StdDev)
+			line("  Valid Pixels=%d, Nodata Pixels=%d", s.Count, s.NodataCount)
+		}
+		if info.Nodata != nil {
+			line("  NoData Value=%v", *info.Nodata)
+		}
+		if len(info.Overviews) > 0 {
+			sizes := make([]string, len(info.Overviews))
+			for i, o := range info.Overviews {
+				sizes[i] = fmt.Sprintf("%dx%d", o.Columns, o.Rows)
+			}
+			line("  Overviews: %s", strings.Join(sizes, ", "))
+		}
+		if band.Scale != 1 || band.Offset != 0 {
+			line("  Offset: %v,   Scale:%v", band.Offset, band.Scale)
+		}
+		if band.Unit != "" {
+			line("  Unit Type: %s", band.Unit)
+		}
+	}
+	return sb.String()
+}
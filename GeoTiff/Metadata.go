@@ -0,0 +1,224 @@
+package GeoTiff
+
+import (
+	"encoding/xml"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// GDAL writes dataset and band metadata as xml into the GDAL_METADATA (42112) tag
+//
+//	<GDALMetadata>
+//	  <Item name="AREA">Xinjiang</Item>
+//	  <Item name="DESCRIPTION" sample="0" role="description">NDVI</Item>
+//	  <Item name="SCALE" sample="0" role="scale">0.0001</Item>
+//	</GDALMetadata>
+//
+// https://github.com/OSGeo/gdal/blob/master/frmts/gtiff/geotiff.cpp (WriteMetadata)
+type gdalMetadataXML struct {
+	XMLName xml.Name              `xml:"GDALMetadata"`
+	Items   []gdalMetadataXMLItem `xml:"Item"`
+}
+type gdalMetadataXMLItem struct {
+	Name   string `xml:"name,attr"`
+	Domain string `xml:"domain,attr,omitempty"`
+	Sample string `xml:"sample,attr,omitempty"`
+	Role   string `xml:"role,attr,omitempty"`
+	Value  string `xml:",chardata"`
+}
+
+// BandMetadata is the band level part of GDAL_METADATA
+// physical value = raw value * Scale + Offset
+type BandMetadata struct {
+	Description string
+	Scale       float64
+	Offset      float64
+	Unit        string
+	// Items is the default domain key/value of the band
+	Items map[string]string
+}
+
+// GDALMetadata is the decoded GDAL_METADATA tag
+type GDALMetadata struct {
+	// Items is the default domain key/value of the dataset
+	Items map[string]string
+	// Domains holds the items of the named domains, e.g. IMAGE_STRUCTURE
+	Domains map[string]map[string]string
+	// Bands is keyed by the sample index (0 is the first band)
+	Bands map[int]*BandMetadata
+}
+
+func NewGDALMetadata() GDALMetadata {
+	return GDALMetadata{
+		Items:   map[string]string{},
+		Domains: map[string]map[string]string{},
+		Bands:   map[int]*BandMetadata{},
+	}
+}
+
+func newBandMetadata() *BandMetadata {
+	return &BandMetadata{
+		Scale: 1,
+		Items: map[string]string{},
+	}
+}
+
+// Band returns the metadata of the band, and create it when it is not exist
+func (gm *GDALMetadata) Band(band int) *BandMetadata {
+	if gm.Bands == nil {
+		gm.Bands = map[int]*BandMetadata{}
+	}
+	if bm, ok := gm.Bands[band]; ok {
+		return bm
+	}
+	bm := newBandMetadata()
+	gm.Bands[band] = bm
+	return bm
+}
+
+// IsEmpty report whether there is nothing to write
+func (gm GDALMetadata) IsEmpty() bool {
+	return len(gm.Items) == 0 && len(gm.Domains) == 0 && len(gm.Bands) == 0
+}
+
+func parseGDALMetadata(text string) (GDALMetadata, error) {
+	gm := NewGDALMetadata()
+	// the ASCII value ends with NUL
+	text = strings.TrimRight(text, "\x00")
+	if strings.TrimSpace(text) == "" {
+		return gm, nil
+	}
+	var mx gdalMetadataXML
+	if err := xml.Unmarshal([]byte(text), &mx); err != nil {
+		return gm, gEC(WithFunction("parseGDALMetadata"), WithError(err))
+	}
+	for _, item := range mx.Items {
+		if item.Sample == "" {
+			if item.Domain == "" {
+				gm.Items[item.Name] = item.Value
+			} else {
+				if gm.Domains[item.Domain] == nil {
+					gm.Domains[item.Domain] = map[string]string{}
+				}
+				gm.Domains[item.Domain][item.Name] = item.Value
+			}
+			continue
+		}
+		sample, err := strconv.Atoi(item.Sample)
+		if err != nil || sample < 0 {
+			return gm, gEC(WithFunction("parseGDALMetadata"), WithErrorText(fmt.Sprintf("wrong sample [%s] of item [%s]", item.Sample, item.Name)))
+		}
+		bm := gm.Band(sample)
+		switch strings.ToLower(item.Role) {
+		case "description":
+			bm.Description = item.Value
+		case "scale":
+			if bm.Scale, err = strconv.ParseFloat(strings.TrimSpace(item.Value), 64); err != nil {
+				return gm, gEC(WithFunction("parseGDALMetadata"), WithError(err), WithMsg("parse scale"))
+			}
+		case "offset":
+			if bm.Offset, err = strconv.ParseFloat(strings.TrimSpace(item.Value), 64); err != nil {
+				return gm, gEC(WithFunction("parseGDALMetadata"), WithError(err), WithMsg("parse offset"))
+			}
+		case "unittype":
+			bm.Unit = item.Value
+		default:
+			bm.Items[item.Name] = item.Value
+		}
+	}
+	return gm, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// String encode the metadata as the xml of GDAL_METADATA
+func (gm GDALMetadata) String() string {
+	mx := gdalMetadataXML{}
+	for _, k := range sortedKeys(gm.Items) {
+		mx.Items = append(mx.Items, gdalMetadataXMLItem{Name: k, Value: gm.Items[k]})
+	}
+	domains := make([]string, 0, len(gm.Domains))
+	for d := range gm.Domains {
+		domains = append(domains, d)
+	}
+	sort.Strings(domains)
+	for _, d := range domains {
+		for _, k := range sortedKeys(gm.Domains[d]) {
+			mx.Items = append(mx.Items, gdalMetadataXMLItem{Name: k, Domain: d, Value: gm.Domains[d][k]})
+		}
+	}
+	bands := make([]int, 0, len(gm.Bands))
+	for b := range gm.Bands {
+		bands = append(bands, b)
+	}
+	sort.Ints(bands)
+	for _, b := range bands {
+		bm := gm.Bands[b]
+		sample := strconv.Itoa(b)
+		for _, k := range sortedKeys(bm.Items) {
+			mx.Items = append(mx.Items, gdalMetadataXMLItem{Name: k, Sample: sample, Value: bm.Items[k]})
+		}
+		if bm.Description != "" {
+			mx.Items = append(mx.Items, gdalMetadataXMLItem{Name: "DESCRIPTION", Sample: sample, Role: "description", Value: bm.Description})
+		}
+		if bm.Offset != 0 || bm.Scale != 1 {
+			mx.Items = append(mx.Items,
+				gdalMetadataXMLItem{Name: "OFFSET", Sample: sample, Role: "offset", Value: strconv.FormatFloat(bm.Offset, 'g', -1, 64)},
+				gdalMetadataXMLItem{Name: "SCALE", Sample: sample, Role: "scale", Value: strconv.FormatFloat(bm.Scale, 'g', -1, 64)},
+			)
+		}
+		if bm.Unit != "" {
+			mx.Items = append(mx.Items, gdalMetadataXMLItem{Name: "UNITTYPE", Sample: sample, Role: "unittype", Value: bm.Unit})
+		}
+	}
+	b, err := xml.MarshalIndent(mx, "", "  ")
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// Nodata parse Meta.NodataValue (GDAL_NODATA is ASCII and ends with NUL)
+func (m Meta) Nodata() (float64, bool) {
+	s := strings.TrimSpace(strings.TrimRight(m.NodataValue, "\x00"))
+	if s == "" {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, false
+	}
+	return v, true
+}
+
+// PhysicalData apply the scale/offset of the band to the raw values
+// nodata is kept as it is, it is empty when the pixels of the tif opened by OpenGeoTifHeader can not be read
+func (g *GeoTif) PhysicalData(band int) []float64 {
+	if err := g.pixels(); err != nil {
+		return nil
+	}
+	ret := make([]float64, len(g.Data.Data))
+	bm, ok := g.Metadata.Bands[band]
+	if !ok {
+		copy(ret, g.Data.Data)
+		return ret
+	}
+	nodata, hasNodata := g.Meta.Nodata()
+	for i, v := range g.Data.Data {
+		if hasNodata && v == nodata {
+			ret[i] = v
+			continue
+		}
+		ret[i] = v*bm.Scale + bm.Offset
+	}
+	return ret
+}
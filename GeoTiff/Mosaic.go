@@ -0,0 +1,240 @@
+package GeoTiff
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// MosaicRule is how the overlapping valid pixels are merged
+type MosaicRule int
+
+const (
+	MosaicFirst MosaicRule = iota
+	MosaicLast
+	MosaicMin
+	MosaicMax
+	MosaicMean
+)
+
+type mosaicConfig struct {
+	rule          MosaicRule
+	nodata        *float64
+	blockRows     int
+	writerOptions []WriterOptions
+}
+
+type MosaicOptions func(mc *mosaicConfig)
+
+// WithMosaicRule default is MosaicFirst, the first input in the list wins
+func WithMosaicRule(rule MosaicRule) MosaicOptions {
+	return func(mc *mosaicConfig) {
+		mc.rule = rule
+	}
+}
+
+// WithMosaicNodata set the nodata of the result, default is the nodata of the first input, or 0
+func WithMosaicNodata(nodata float64) MosaicOptions {
+	return func(mc *mosaicConfig) {
+		mc.nodata = &nodata
+	}
+}
+
+// WithMosaicBlockRows set how many rows are merged at a time, default is 256
+func WithMosaicBlockRows(rows int) MosaicOptions {
+	return func(mc *mosaicConfig) {
+		mc.blockRows = rows
+	}
+}
+
+// WithMosaicWriterOptions is passed to the Writer by MosaicTo
+func WithMosaicWriterOptions(opts ...WriterOptions) MosaicOptions {
+	return func(mc *mosaicConfig) {
+		mc.writerOptions = append(mc.writerOptions, opts...)
+	}
+}
+
+// Mosaic merge the rasters into one which covers the union extent
+// the inputs should be in the same CRS with the same pixel size (north up) and aligned to the same grid,
+// the result has the data type of the first input, the inputs opened by OpenGeoTifHeader are read block by block
+// with the rows of the result
+func Mosaic(inputs []*GeoTif, opts ...MosaicOptions) (*GeoTif, error) {
+	var gEC = NewGeoErrorCreator("Mosaic")
+	var out *GeoTif
+	err := mosaic(inputs, opts, func(template *GeoTif, cfg mosaicConfig) (func(rows []float64) error, error) {
+		out = template
+		out.Data.Data = make([]float64, out.Meta.Columns*out.Meta.Rows)
+		off := 0
+		return func(rows []float64) error {
+			off += copy(out.Data.Data[off:], rows)
+			return nil
+		}, nil
+	})
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	return out, nil
+}
+
+// MosaicTo is the same as Mosaic, but the result is written to w block by block
+func MosaicTo(w io.WriteSeeker, inputs []*GeoTif, opts ...MosaicOptions) error {
+	var gEC = NewGeoErrorCreator("MosaicTo")
+	var gw *Writer
+	err := mosaic(inputs, opts, func(template *GeoTif, cfg mosaicConfig) (func(rows []float64) error, error) {
+		var err error
+		if gw, err = NewWriter(w, template, cfg.writerOptions...); err != nil {
+			return nil, err
+		}
+		return gw.WriteRows, nil
+	})
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	if err = gw.Close(); err != nil {
+		return gEC(WithError(err))
+	}
+	return nil
+}
+
+func mosaic(inputs []*GeoTif, opts []MosaicOptions,
+	open func(template *GeoTif, cfg mosaicConfig) (func(rows []float64) error, error)) error {
+	cfg := mosaicConfig{
+		rule:      MosaicFirst,
+		blockRows: 256,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.blockRows <= 0 {
+		cfg.blockRows = 256
+	}
+	if len(inputs) == 0 {
+		return gEC(WithFunction("mosaic"), WithErrorText("there is no input"))
+	}
+	first := inputs[0]
+	if first.Transform.Data[2] != 0 || first.Transform.Data[4] != 0 {
+		return gEC(WithFunction("mosaic"), WithErrorText("the rotated raster is not supported"))
+	}
+	bounds := first.Bounds()
+	for i, in := range inputs[1:] {
+		if in.Meta.EPSGCode != first.Meta.EPSGCode {
+			return gEC(WithFunction("mosaic"), WithErrorText(fmt.Sprintf("EPSG of input %d is %d, but the first is %d", i+1, in.Meta.EPSGCode, first.Meta.EPSGCode)))
+		}
+		if err := first.CheckSameResolution(in); err != nil {
+			return gEC(WithFunction("mosaic"), WithError(err), WithMsg(fmt.Sprintf("input %d", i+1)))
+		}
+		bounds = bounds.Union(in.Bounds())
+	}
+
+	dx, dy := first.Transform.Data[1], first.Transform.Data[5]
+	template := NewGeoTifLike(first, first.Meta.BitsPerSample[0], first.Meta.SampleFormat)
+	// the top left corner of the union, dy < 0 means north up
+	originX, originY := bounds.MinX, bounds.MaxY
+	if dx < 0 {
+		originX = bounds.MaxX
+	}
+	if dy > 0 {
+		originY = bounds.MinY
+	}
+	template.Transform.Data[0], template.Transform.Data[3] = originX, originY
+	template.Meta.Columns = uint(math.Round(bounds.Width() / math.Abs(dx)))
+	template.Meta.Rows = uint(math.Round(bounds.Height() / math.Abs(dy)))
+	template.Data.Data = nil
+	template.Metadata = first.Metadata
+
+	nodata, hasNodata := first.Meta.Nodata()
+	if cfg.nodata != nil {
+		nodata, hasNodata = *cfg.nodata, true
+	}
+	if !hasNodata {
+		nodata = 0
+	}
+	template.Meta.NodataValue = strconv.FormatFloat(nodata, 'g', -1, 64)
+
+	// the offset of every input in the result
+	offsets := make([][2]int, len(inputs))
+	for i, in := range inputs {
+		col, row := template.Transform.GeoToPixel(in.Transform.Data[0], in.Transform.Data[3])
+		offsets[i] = [2]int{int(math.Round(col)), int(math.Round(row))}
+		if math.Abs(col-math.Round(col)) > 0.01 || math.Abs(row-math.Round(row)) > 0.01 {
+			return gEC(WithFunction("mosaic"), WithErrorText(fmt.Sprintf("input %d is not aligned to the grid of the first input", i)))
+		}
+	}
+
+	readers := make([]func(y0, y1 int) ([]float64, error), len(inputs))
+	for i, in := range inputs {
+		var err error
+		if readers[i], err = in.rowReader(); err != nil {
+			return gEC(WithFunction("mosaic"), WithError(err), WithMsg(fmt.Sprintf("input %d", i)))
+		}
+	}
+	emit, err := open(template, cfg)
+	if err != nil {
+		return gEC(WithFunction("mosaic"), WithError(err))
+	}
+	width := int(template.Meta.Columns)
+	height := int(template.Meta.Rows)
+	block := make([]float64, cfg.blockRows*width)
+	counts := make([]int, cfg.blockRows*width)
+	for row := 0; row < height; row += cfg.blockRows {
+		rowEnd := minInt(row+cfg.blockRows, height)
+		n := (rowEnd - row) * width
+		block, counts := block[:n], counts[:n]
+		for i := range block {
+			block[i] = nodata
+			counts[i] = 0
+		}
+		for k, in := range inputs {
+			inWidth := int(in.Meta.Columns)
+			// the rows of the input under the rows of the result
+			inRow0 := maxInt(row-offsets[k][1], 0)
+			inRow1 := minInt(rowEnd-offsets[k][1], int(in.Meta.Rows))
+			if inRow0 >= inRow1 {
+				continue
+			}
+			rows, err := readers[k](inRow0, inRow1)
+			if err != nil {
+				return gEC(WithFunction("mosaic"), WithError(err), WithMsg(fmt.Sprintf("input %d", k)))
+			}
+			for inRow := inRow0; inRow < inRow1; inRow++ {
+				r := inRow + offsets[k][1]
+				from := maxInt(offsets[k][0], 0)
+				to := minInt(offsets[k][0]+inWidth, width)
+				for c := from; c < to; c++ {
+					v := rows[(inRow-inRow0)*inWidth+c-offsets[k][0]]
+					if in.IsNodata(v) {
+						continue
+					}
+					i := (r-row)*width + c
+					if counts[i] == 0 {
+						block[i] = v
+					} else {
+						switch cfg.rule {
+						case MosaicLast:
+							block[i] = v
+						case MosaicMin:
+							block[i] = math.Min(block[i], v)
+						case MosaicMax:
+							block[i] = math.Max(block[i], v)
+						case MosaicMean:
+							block[i] += v
+						}
+					}
+					counts[i]++
+				}
+			}
+		}
+		if cfg.rule == MosaicMean {
+			for i := range block {
+				if counts[i] > 1 {
+					block[i] /= float64(counts[i])
+				}
+			}
+		}
+		if err = emit(block); err != nil {
+			return gEC(WithFunction("mosaic"), WithError(err))
+		}
+	}
+	return nil
+}
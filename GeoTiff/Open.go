@@ -1,30 +1,174 @@
 package GeoTiff
 
 import (
+	"context"
 	"encoding/binary"
 	"errors"
 	"fmt"
-	"math"
+	"io"
 	"os"
+	"path/filepath"
 )
 
-func OpenGeoTif(FilePath string) (*GeoTif, error) {
+// defaultMaxPixels is the largest image (or block) which is decoded by default, 2^28 pixels are 2 GB of float64
+const defaultMaxPixels = 1 << 28
+
+type openConfig struct {
+	mmap       bool
+	blockCache *BlockCache
+	maxPixels  int64
+	progress   func(done, total int)
+}
+
+type OpenOptions func(oc *openConfig)
+
+// WithMmap read the file through a read only memory map (linux only, os.Open is used on the other systems),
+// the uncompressed blocks are decoded from the mapped memory without copy
+func WithMmap(mmap bool) OpenOptions {
+	return func(oc *openConfig) {
+		oc.mmap = mmap
+	}
+}
+
+// WithBlockCache keep the decoded blocks in the cache, which can be shared by many GeoTif,
+// it is used by Blocks, ProcessBlocks, Sample and ReadData
+func WithBlockCache(cache *BlockCache) OpenOptions {
+	return func(oc *openConfig) {
+		oc.blockCache = cache
+	}
+}
+
+// WithMaxPixels set the largest number of pixels of the image and of a block, default is 2^28,
+// the larger files are refused instead of allocating the memory the tags ask for
+func WithMaxPixels(maxPixels int64) OpenOptions {
+	return func(oc *openConfig) {
+		oc.maxPixels = maxPixels
+	}
+}
+
+// WithProgress call progress after every block is read by OpenGeoTif, ReadData, ProcessBlocks or the stats of Info,
+// done blocks of total are read, it is called by one goroutine at a time
+func WithProgress(progress func(done, total int)) OpenOptions {
+	return func(oc *openConfig) {
+		oc.progress = progress
+	}
+}
+
+func newOpenConfig(opts []OpenOptions) openConfig {
+	cfg := openConfig{
+		maxPixels: defaultMaxPixels,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// OpenGeoTif read the tags and the pixels of the tif, the file is kept open for Blocks and ProcessBlocks
+// until Close is called
+func OpenGeoTif(FilePath string, opts ...OpenOptions) (*GeoTif, error) {
+	return OpenGeoTifContext(context.Background(), FilePath, opts...)
+}
+
+// OpenGeoTifContext is OpenGeoTif which stops reading the pixels when ctx is done
+func OpenGeoTifContext(ctx context.Context, FilePath string, opts ...OpenOptions) (*GeoTif, error) {
 	var gEC = NewGeoErrorCreator("OpenGeoTif")
+	if err := ctx.Err(); err != nil {
+		return nil, gEC(WithError(err))
+	}
+	geoTif := GeoTif{
+		FilePath:     FilePath,
+		GeoTifHeader: geoTifHeader{},
+	}
+	if err := geoTif.open(newOpenConfig(opts)); err != nil {
+		return nil, gEC(WithError(err))
+	}
+	if err := geoTif.readData(ctx); err != nil {
+		geoTif.Close()
+		return nil, gEC(WithError(err))
+	}
+	return &geoTif, nil
+}
+
+// OpenGeoTifHeader read the tags of the tif without the pixels,
+// the pixels can be read later by ReadData, or block by block by Blocks and ProcessBlocks
+func OpenGeoTifHeader(FilePath string, opts ...OpenOptions) (*GeoTif, error) {
+	var gEC = NewGeoErrorCreator("OpenGeoTifHeader")
 	geoTif := GeoTif{
 		FilePath:     FilePath,
 		GeoTifHeader: geoTifHeader{},
 	}
-	if err := geoTif.open(); err != nil {
+	if err := geoTif.open(newOpenConfig(opts)); err != nil {
 		return nil, gEC(WithError(err))
 	}
 	return &geoTif, nil
 }
-func (g *GeoTif) open() error {
+func (g *GeoTif) open(cfg openConfig) error {
 	//var gEC = NewGeoErrorCreator("open")
+	if zipFilePath, name, ok := splitVSIZip(g.FilePath); ok {
+		return g.openZip(zipFilePath, name, cfg)
+	}
 	var err error
-	g.tFile, err = os.Open(g.FilePath)
+	cacheFile := ""
+	if cfg.blockCache != nil {
+		if cacheFile, err = fileCacheKey(g.FilePath); err != nil {
+			return gEC(WithError(err))
+		}
+	}
+	var tFile io.ReaderAt
+	var size int64
+	if cfg.mmap {
+		// fall back to os.Open when the file can not be mapped
+		if m, err := openMmap(g.FilePath); err == nil {
+			tFile = m
+			size = int64(len(m.data))
+		}
+	}
+	if tFile == nil {
+		f, err := os.Open(g.FilePath)
+		if err != nil {
+			return gEC(WithError(err))
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return gEC(WithError(err))
+		}
+		tFile = f
+		size = info.Size()
+	}
+	if err = g.openReader(tFile, size, cacheFile, cfg); err != nil {
+		g.Close()
+		return gEC(WithFunction("open"), WithError(err))
+	}
+	return nil
+}
+
+// fileCacheKey is the name of the file in the keys of the block cache, the size and the modification time
+// are in it so the blocks of a file which is rewritten at the same path are not used
+func fileCacheKey(FilePath string) (string, error) {
+	abs, err := filepath.Abs(FilePath)
 	if err != nil {
-		return gEC(WithError(err))
+		return "", err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s#%d:%d", abs, info.Size(), info.ModTime().UnixNano()), nil
+}
+
+// openReader parse the tags of the tif in tFile of size bytes (0 when it is unknown),
+// cacheFile is the name of tFile in the keys of the block cache
+func (g *GeoTif) openReader(tFile io.ReaderAt, size int64, cacheFile string, cfg openConfig) error {
+	var err error
+	g.tFile = tFile
+	g.fileSize = size
+	g.maxPixels = cfg.maxPixels
+	g.progress = cfg.progress
+	if cfg.blockCache != nil {
+		g.blockCache = cfg.blockCache
+		g.cacheFile = cacheFile
 	}
 	if err := g.checkBigOrLittle(); err != nil {
 		return gEC(WithError(err))
@@ -35,21 +179,28 @@ func (g *GeoTif) open() error {
 	//	return gEC(WithError(err))
 	//}
 	if err = g.readAttribute(); err != nil {
-		return gEC(WithFunction("open"), WithError(err))
+		return gEC(WithFunction("openReader"), WithError(err))
 	}
 	if err = g.parseGeoKeys(); err != nil {
-		return gEC(WithFunction("open"), WithError(err))
+		return gEC(WithFunction("openReader"), WithError(err))
 	}
 	if err = g.initMeta(); err != nil {
-		return gEC(WithFunction("open"), WithError(err))
+		return gEC(WithFunction("openReader"), WithError(err))
 	}
 	g.Transform = transform{}
 	attrs := append(g.GeoKeys, g.GeoTifHeader.Attribute...)
 	if err = g.Transform.Init(attrs...); err != nil {
-		return gEC(WithFunction("open"), WithError(err))
+		return gEC(WithFunction("openReader"), WithError(err))
 	}
-	if err = g.readData(); err != nil {
-		return gEC(WithFunction("open"), WithError(err))
+	if o := g.Meta.orientation; o != orientTopLeft {
+		// the tie points and the matrix are of the pixels of the file
+		width, height := int(g.Meta.Columns), int(g.Meta.Rows)
+		if o.transposed() {
+			width, height = height, width
+		}
+		g.Transform.Data = o.shownTransform(g.Transform.Data, width, height)
+		g.Transform.Resolution[0] = g.Transform.Data[1]
+		g.Transform.Resolution[1] = g.Transform.Data[5]
 	}
 	return nil
 }
@@ -68,7 +219,7 @@ func (g *GeoTif) checkBigOrLittle() error {
 	case bigEndian:
 		g.byteOrder = binary.BigEndian
 	default:
-		return gEC(WithFunction("checkBigOrLittle"), WithError(errors.New(fmt.Sprintf("undefined byte order [% x]", byteOrder))))
+		return gEC(WithKind(ErrCorrupt), WithFunction("checkBigOrLittle"), WithError(errors.New(fmt.Sprintf("undefined byte order [% x]", byteOrder))))
 	}
 	return nil
 }
@@ -82,43 +233,63 @@ func (g *GeoTif) readAttribute() error {
 	g.GeoTifHeader.offset = int64(g.byteOrder.Uint32(data))
 
 	if g.GeoTifHeader.offset != 0 {
-		// get the number of attribute
-		var numAttribute int64
-		data, err := g.readFile(g.GeoTifHeader.offset, 2)
-		if err != nil {
+		if g.GeoTifHeader.Attribute, _, err = g.readIFD(g.GeoTifHeader.offset); err != nil {
 			return gEC(WithFunction("readAttribute"), WithError(err))
 		}
-		numAttribute = int64(g.byteOrder.Uint16(data))
+	}
+	return nil
+}
 
-		g.GeoTifHeader.Attribute = make([]geoAttribute, numAttribute, numAttribute)
-		// read all attribute to []byte
-		attributeBytes, err := g.readFile(g.GeoTifHeader.offset+2, int(geoFileAttributeSize*numAttribute))
+// readIFD read the attributes of the IFD at offset and the offset of the next IFD (0 when it is the last one)
+func (g *GeoTif) readIFD(offset int64) (GeoAttributes, int64, error) {
+	// get the number of attribute
+	var numAttribute int64
+	data, err := g.readFile(offset, 2)
+	if err != nil {
+		return nil, 0, gEC(WithFunction("readIFD"), WithError(err))
+	}
+	numAttribute = int64(g.byteOrder.Uint16(data))
+
+	attributes := make(GeoAttributes, 0, numAttribute)
+	// read all attribute to []byte
+	attributeBytes, err := g.readFile(offset+2, int(geoFileAttributeSize*numAttribute))
+	if err != nil {
+		return nil, 0, gEC(WithFunction("readIFD"), WithError(err))
+	}
+	// to parse attribute
+	for i := 0; i < int(numAttribute); i++ {
+		gAttribute, err := newGeoAttribute(attributeBytes[i*12:i*12+12], g.byteOrder)
 		if err != nil {
-			return gEC(WithFunction("readAttribute"), WithError(err))
+			return nil, 0, gEC(WithFunction("readIFD"), WithError(err), WithMsg(fmt.Sprintf("for[%d]", i)))
 		}
-		// to parse attribute
-		for i := 0; i < int(numAttribute); i++ {
-			gAttribute, err := newGeoAttribute(attributeBytes[i*12:i*12+12], g.byteOrder)
-			if err != nil {
-				return gEC(WithFunction("readAttribute"), WithError(err), WithErrorText(fmt.Sprintf("for[%d]", i)))
-			}
-			totalBytes := gAttribute.Bytes()
-			if totalBytes > 4 {
-				gAttribute.Offset = g.byteOrder.Uint32(gAttribute.SourceValue)
-				realSourceData, err := g.readFile(FileOffset(gAttribute.Offset), int(totalBytes))
-				if err != nil {
-					return gEC(WithFunction("readAttribute"), WithError(err), WithErrorText(fmt.Sprintf("for[%d] read realSourceData", i)))
-				}
-				gAttribute.SourceValue = realSourceData
+		if gAttribute.Type.Bytes() == 0 {
+			// the types added after TIFF 6.0 (IFD, LONG8...) are not used, skip them
+			continue
+		}
+		// in uint64, Len * size can overflow the uint32 of Bytes()
+		totalBytes := uint64(gAttribute.Len) * uint64(gAttribute.Type.Bytes())
+		if totalBytes > 4 {
+			if totalBytes > uint64(maxAttributeBytes) {
+				return nil, 0, gEC(WithKind(ErrTooLarge), WithFunction("readIFD"), WithErrorText(fmt.Sprintf("attribute [%d] has %d bytes, more than %d bytes", gAttribute.Tag, totalBytes, maxAttributeBytes)))
 			}
-			if err := gAttribute.parseValue(g.byteOrder); err != nil {
-				return gEC(WithFunction("readAttribute"), WithError(err), WithErrorText(fmt.Sprintf("for[%d] parse value", i)))
+			gAttribute.Offset = g.byteOrder.Uint32(gAttribute.SourceValue)
+			realSourceData, err := g.readFile(FileOffset(gAttribute.Offset), int(totalBytes))
+			if err != nil {
+				return nil, 0, gEC(WithFunction("readIFD"), WithError(err), WithMsg(fmt.Sprintf("for[%d] read realSourceData", i)))
 			}
-			g.GeoTifHeader.Attribute[i] = *gAttribute
+			gAttribute.SourceValue = realSourceData
 		}
-
+		if err := gAttribute.parseValue(g.byteOrder); err != nil {
+			return nil, 0, gEC(WithFunction("readIFD"), WithError(err), WithMsg(fmt.Sprintf("for[%d] parse value", i)))
+		}
+		attributes = append(attributes, *gAttribute)
 	}
-	return nil
+	// the offset of the next IFD is not required by the first IFD, a file cut after the entries is still read
+	var next int64
+	if data, err = g.readFile(offset+2+geoFileAttributeSize*numAttribute, 4); err == nil {
+		next = int64(g.byteOrder.Uint32(data))
+	}
+	return attributes, next, nil
 }
 
 func (g *GeoTif) parseGeoKeys() error {
@@ -129,12 +300,19 @@ func (g *GeoTif) parseGeoKeys() error {
 		return gEC(WithFunction("parseGeoKeys"), WithError(err))
 	} else {
 		geoKeyDirectoryValue := geoKeyDirectoryAtr.GeoAttributeValue.SHORT
+		if len(geoKeyDirectoryValue) < 4 {
+			return gEC(WithKind(ErrCorrupt), WithFunction("parseGeoKeys"), WithErrorText(fmt.Sprintf("the GeoKeyDirectory requires 4 SHORT in the header, but get %d", len(geoKeyDirectoryValue))))
+		}
 		if geoKeyDirectoryValue[3] > 0 {
 			geoKeyLen := int(geoKeyDirectoryValue[3])
+			if len(geoKeyDirectoryValue) < 4+4*geoKeyLen {
+				return gEC(WithKind(ErrCorrupt), WithFunction("parseGeoKeys"), WithErrorText(fmt.Sprintf("the GeoKeyDirectory has %d keys, but only %d SHORT", geoKeyLen, len(geoKeyDirectoryValue))))
+			}
 			g.GeoKeys = make([]geoAttribute, geoKeyLen, geoKeyLen)
 			for i := 0; i < geoKeyLen; i++ {
 				fromIndex := 4*i + 4
 				gAttribute := geoAttribute{
+					Tag:    AttributeTag(geoKeyDirectoryValue[fromIndex]),
 					Len:    uint32(geoKeyDirectoryValue[2+fromIndex]),
 					Offset: 0,
 				}
@@ -143,6 +321,8 @@ func (g *GeoTif) parseGeoKeys() error {
 					g.byteOrder.PutUint16(b, uint16(geoKeyDirectoryValue[fromIndex+3]))
 					gAttribute.SourceValue = b
 					gAttribute.Type = SHORT
+					// the value in the directory is always one SHORT
+					gAttribute.Len = 1
 					gAttribute.parseValue(g.byteOrder)
 				} else if geoKeyDirectoryValue[fromIndex+1] == uint16(GeoDoubleParamsTag) {
 					if geoDoubleDirectoryAtr.Tag == 0 {
@@ -152,7 +332,10 @@ func (g *GeoTif) parseGeoKeys() error {
 						}
 					}
 					gAttribute.Offset = uint32(geoKeyDirectoryValue[3+fromIndex])
-					gAttribute.SourceValue = geoDoubleDirectoryAtr.SourceValue[gAttribute.Offset*8 : gAttribute.Offset*8+gAttribute.Len]
+					if end := (gAttribute.Offset + gAttribute.Len) * 8; int(end) > len(geoDoubleDirectoryAtr.SourceValue) {
+						return gEC(WithKind(ErrCorrupt), WithFunction("parseGeoKeys"), WithErrorText(fmt.Sprintf("geokey [%d] is out of the GeoDoubleParams", gAttribute.Tag)))
+					}
+					gAttribute.SourceValue = geoDoubleDirectoryAtr.SourceValue[gAttribute.Offset*8 : gAttribute.Offset*8+gAttribute.Len*8]
 					gAttribute.Type = DOUBLE
 					gAttribute.parseValue(g.byteOrder)
 				} else if geoKeyDirectoryValue[fromIndex+1] == uint16(GeoAsciiParamsTag) {
@@ -163,6 +346,9 @@ func (g *GeoTif) parseGeoKeys() error {
 						}
 					}
 					gAttribute.Offset = uint32(geoKeyDirectoryValue[3+fromIndex])
+					if end := gAttribute.Offset + gAttribute.Len; int(end) > len(geoASCIIDirectoryAtr.SourceValue) {
+						return gEC(WithKind(ErrCorrupt), WithFunction("parseGeoKeys"), WithErrorText(fmt.Sprintf("geokey [%d] is out of the GeoAsciiParams", gAttribute.Tag)))
+					}
 					gAttribute.SourceValue = geoASCIIDirectoryAtr.SourceValue[gAttribute.Offset : gAttribute.Offset+gAttribute.Len]
 					gAttribute.Type = ASCII
 					gAttribute.parseValue(g.byteOrder)
@@ -187,23 +373,31 @@ func checkAllIsFirst(arr []uint) (uint, bool) {
 }
 func (g *GeoTif) initMeta() error {
 	var errs []error = []error{}
-	var getValue = func(at AttributeTag) []uint {
+	// getValue return the first value of the tag, or def when the tag is optional (def >= 0)
+	var getValue = func(at AttributeTag, def int) uint {
 		atr, err := g.GeoTifHeader.Attribute.getAttributeByTag(at)
-		if err != nil {
-			errs = append(errs, gEC(WithError(err), WithFunction("initMeta")))
+		if err == nil && len(atr.GeoAttributeValue.uint) > 0 {
+			return atr.GeoAttributeValue.uint[0]
+		}
+		if def >= 0 {
+			return uint(def)
+		}
+		if err == nil {
+			err = gEC(WithKind(ErrMissingTag), WithErrorText(fmt.Sprintf("attribute [%d] has no value", at)))
 		}
-		return atr.GeoAttributeValue.uint
+		errs = append(errs, gEC(WithError(err), WithFunction("initMeta")))
+		return 0
 	}
 
 	var err error
 	var atr geoAttribute
 
 	g.Meta = Meta{
-		Columns:           getValue(ImageWidth)[0],
-		Rows:              getValue(ImageLength)[0],
-		PhotometricInterp: getValue(PhotometricInterpretation)[0],
-		samplesPerPixel:   getValue(SamplesPerPixel)[0],
-		SampleFormat:      getValue(SampleFormat)[0],
+		Columns:           getValue(ImageWidth, -1),
+		Rows:              getValue(ImageLength, -1),
+		PhotometricInterp: getValue(PhotometricInterpretation, -1),
+		samplesPerPixel:   getValue(SamplesPerPixel, 1),
+		SampleFormat:      getValue(SampleFormat, int(SampleFormatUint)),
 
 		BitsPerSample:     nil,
 		RasterPixelIsArea: false,
@@ -216,62 +410,83 @@ func (g *GeoTif) initMeta() error {
 	if len(errs) > 0 {
 		return gEC(WithFunction("initMeta"), WithError(errs[0]))
 	}
-	if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(BitsPerSample); err == nil {
+	if g.Meta.Columns == 0 || g.Meta.Rows == 0 {
+		return gEC(WithKind(ErrCorrupt), WithFunction("initMeta"), WithErrorText(fmt.Sprintf("the image is empty, %dx%d", g.Meta.Columns, g.Meta.Rows)))
+	}
+	// the unknown orientation is taken as the default like libtiff
+	if o := orientation(getValue(Orientation, int(orientTopLeft))); o >= orientTopLeft && o <= orientLeftBot {
+		g.Meta.orientation = o
+	} else {
+		g.Meta.orientation = orientTopLeft
+	}
+	if g.Meta.orientation.transposed() {
+		g.Meta.Columns, g.Meta.Rows = g.Meta.Rows, g.Meta.Columns
+	}
+	if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(BitsPerSample); err == nil && len(atr.GeoAttributeValue.uint) > 0 {
 		g.Meta.BitsPerSample = atr.GeoAttributeValue.uint
+	} else {
+		return gEC(WithKind(ErrMissingTag), WithFunction("initMeta"), WithErrorText("can not found BitsPerSample"))
 	}
 	// See if geokeys has GTRasterTypeGeoKey
-	if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(GTRasterTypeGeoKey); err == nil {
+	if atr, err = g.GeoKeys.getAttributeByTag(GTRasterTypeGeoKey); err == nil {
 		v := atr.GeoAttributeValue.uint
-		if v[0] == 1 {
+		if len(v) > 0 && v[0] == 1 {
 			g.Meta.RasterPixelIsArea = true
 		} else {
 			g.Meta.RasterPixelIsArea = false
 		}
 	}
 	// EPSG code
-	if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(ProjectionGeoKey); err == nil {
+	if atr, err = g.GeoKeys.getAttributeByTag(ProjectedCSTypeGeoKey); err == nil && len(atr.GeoAttributeValue.uint) > 0 {
 		g.Meta.EPSGCode = atr.GeoAttributeValue.uint[0]
-	} else if atr, err := g.GeoTifHeader.Attribute.getAttributeByTag(GeographicTypeGeoKey); err == nil {
+	} else if atr, err := g.GeoKeys.getAttributeByTag(GeographicTypeGeoKey); err == nil && len(atr.GeoAttributeValue.uint) > 0 {
 		g.Meta.EPSGCode = atr.GeoAttributeValue.uint[0]
 	}
 	// nodata
 	if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(GDAL_NODATA); err == nil {
 		g.Meta.NodataValue = atr.GeoAttributeValue.ASCII
 	}
+	// GDAL_METADATA
+	g.Metadata = NewGDALMetadata()
+	if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(GDAL_METADATA); err == nil {
+		if g.Metadata, err = parseGDALMetadata(atr.GeoAttributeValue.ASCII); err != nil {
+			return gEC(WithFunction("initMeta"), WithError(err))
+		}
+	}
 
 	var ok bool
 	switch g.Meta.PhotometricInterp {
 	case PI_RGB:
 		if g.Meta.BitsPerSample[0], ok = checkAllIsFirst(g.Meta.BitsPerSample); !ok {
-			return gEC(WithFunction("initMeta"), WithError(err))
+			return gEC(WithKind(ErrUnsupportedSampleFormat), WithFunction("initMeta"), WithErrorText(fmt.Sprintf("the samples of RGB have different bits %v", g.Meta.BitsPerSample)))
 		}
 		l := len(g.Meta.BitsPerSample)
 		if l == 3 {
 			g.Meta.mode = mRGB
 		} else if l == 4 {
-			if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(ExtraSamples); err == nil {
+			if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(ExtraSamples); err == nil && len(atr.GeoAttributeValue.uint) > 0 {
 				v := atr.GeoAttributeValue.uint[0]
 				if v == 1 {
 					g.Meta.mode = mRGBA
 				} else if v == 2 {
 					g.Meta.mode = mNRGBA
 				} else {
-					return gEC(WithFunction("initMeta"), WithErrorText(fmt.Sprintf("wrong number of samples for RGB")))
+					return gEC(WithKind(ErrUnsupportedSampleFormat), WithFunction("initMeta"), WithErrorText(fmt.Sprintf("wrong number of samples for RGB")))
 				}
 			} else {
-				return gEC(WithFunction("initMeta"), WithErrorText(fmt.Sprintf("wrong number of samples for RGB")))
+				return gEC(WithKind(ErrUnsupportedSampleFormat), WithFunction("initMeta"), WithErrorText(fmt.Sprintf("wrong number of samples for RGB")))
 			}
 		} else {
-			return gEC(WithFunction("initMeta"), WithErrorText(fmt.Sprintf("wrong number of samples for RGB,require 3 or 4,but get %d", l)))
+			return gEC(WithKind(ErrUnsupportedSampleFormat), WithFunction("initMeta"), WithErrorText(fmt.Sprintf("wrong number of samples for RGB,require 3 or 4,but get %d", l)))
 		}
 	case PI_Paletted:
 		g.Meta.mode = mPaletted
 		if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(ColorMap); err == nil {
 			numColors := len(atr.GeoAttributeValue.uint) / 3
 			vals := atr.GeoAttributeValue.uint
-			// the color number should be in 0~256 and the number of all value should be the integer of the 3-time
-			if numColors <= 0 || numColors >= 256 || len(vals)%3 != 0 {
-				return gEC(WithFunction("initMeta"), WithErrorText(fmt.Sprintf("require 0 < numColors < 256, but is %d and len(colors)%%3 should be 0, but %d", numColors, len(atr.GeoAttributeValue.uint)%3)))
+			// the color number should be in 1~256 and the number of all value should be the integer of the 3-time
+			if numColors <= 0 || numColors > 256 || len(vals)%3 != 0 {
+				return gEC(WithKind(ErrCorrupt), WithFunction("initMeta"), WithErrorText(fmt.Sprintf("require 0 < numColors <= 256, but is %d and len(colors)%%3 should be 0, but %d", numColors, len(atr.GeoAttributeValue.uint)%3)))
 			}
 			g.Meta.palette = make([]uint32, numColors)
 			for i := 0; i < numColors; i++ {
@@ -283,14 +498,14 @@ func (g *GeoTif) initMeta() error {
 				g.Meta.palette[i] = val
 			}
 		} else {
-			return gEC(WithFunction("initMeta"), WithErrorText(fmt.Sprintf("could not found the colormap")))
+			return gEC(WithKind(ErrMissingTag), WithFunction("initMeta"), WithErrorText(fmt.Sprintf("could not found the colormap")))
 		}
 	case PI_WhiteIsZero:
 		g.Meta.mode = mGrayInvert
 	case PI_BlackIsZero:
 		g.Meta.mode = mGray
 	default:
-		return gEC(WithFunction("initMeta"), WithErrorText(fmt.Sprintf("unkonw image format:[%d]", g.Meta.PhotometricInterp)))
+		return gEC(WithKind(ErrUnsupportedSampleFormat), WithFunction("initMeta"), WithErrorText(fmt.Sprintf("unkonw image format:[%d]", g.Meta.PhotometricInterp)))
 	}
 	return nil
 }
@@ -301,385 +516,73 @@ func minInt(a, b int) int {
 	}
 	return b
 }
-func (g *GeoTif) readData() error {
-	var err error
-	var atr geoAttribute
-	var compressionType uint
-	atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(Compression)
-	if err == nil {
-		compressionType = atr.GeoAttributeValue.uint[0]
-	} else {
-		compressionType = 0
-	}
-	width := int(g.Meta.Columns)
-	height := int(g.Meta.Rows)
-	gData := GeoData{
-		buf:  []byte{},
-		off:  0,
-		Data: make([]float64, width*height),
-	}
-	blockPadding := false
-	blockWidth := width
-	blockHeight := height
-	blocksAcross := 1
-	blocksDown := 1
-	var blockOffsets, blockCounts []uint
-
-	atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(TileWidth)
-	if atr.GeoAttributeValue.uint[0] != 0 {
-		blockPadding = true
-		blockWidth = int(atr.GeoAttributeValue.uint[0])
-		if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(TileLength); err == nil {
-			blockHeight = int(atr.GeoAttributeValue.uint[0])
-		} else {
-			return gEC(WithFunction("readData"), WithErrorText("can not found TileLength"))
-		}
 
-		blocksAcross = (width + blockWidth - 1) / blockWidth
-		blocksDown = (height + blockHeight - 1) / blockHeight
-
-		if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(TileOffsets); err == nil {
-			blockOffsets = atr.GeoAttributeValue.uint
-		}
-		if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(TileByteCounts); err == nil {
-			blockCounts = atr.GeoAttributeValue.uint
-		}
-	} else {
-		if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(RowsPerStrip); err != nil {
-			v := int(atr.GeoAttributeValue.uint[0])
-			blockHeight = v
-		}
-		blocksDown = (height + blockHeight - 1) / blockHeight
-		if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(TileOffsets); err != nil {
-			blockOffsets = atr.GeoAttributeValue.uint
-		}
-		if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(TileByteCounts); err != nil {
-			blockCounts = atr.GeoAttributeValue.uint
-		}
+// checkPixels refuse the image or the block which is larger than WithMaxPixels
+func (g *GeoTif) checkPixels(width, height int) error {
+	if g.maxPixels > 0 && int64(width)*int64(height) > g.maxPixels {
+		return gEC(WithKind(ErrTooLarge), WithFunction("checkPixels"), WithErrorText(fmt.Sprintf("%dx%d pixels are more than the limit %d", width, height, g.maxPixels)))
 	}
+	return nil
+}
 
-	tPredictor := uint(0)
-	if atr, err = g.GeoTifHeader.Attribute.getAttributeByTag(Predictor); err == nil {
-		tPredictor = atr.GeoAttributeValue.uint[0]
+func (g *GeoTif) readData(ctx context.Context) error {
+	layout, err := g.blockLayout()
+	if err != nil {
+		return gEC(WithFunction("readData"), WithError(err))
+	}
+	if err = g.checkPixels(layout.width, layout.height); err != nil {
+		return gEC(WithFunction("readData"), WithError(err))
 	}
+	// the blocks are shown as Columns x Rows, see orientation
+	sink := &dataSink{width: int(g.Meta.Columns), data: make([]float64, layout.width*layout.height)}
+	if err = g.processBlocks(ctx, layout, 0, nil, sink); err != nil {
+		return gEC(WithFunction("readData"), WithError(err))
+	}
+	g.Data = GeoData{
+		buf:  []byte{},
+		off:  0,
+		Data: sink.data,
+	}
+	return nil
+}
 
-	//bConverter := bufConverter{
-	//	order: g.byteOrder,
-	//}
-	gDataReader := geoDataReader{
-		tFile:           g.tFile,
-		byteOrder:       g.byteOrder,
-		compressionType: compressionType,
-	}
-	for i := 0; i < blocksAcross; i++ {
-		blkW := blockWidth
-		if !blockPadding && i == blocksAcross-1 && width%blockWidth != 0 {
-			blkW = width % blockWidth
-		}
-		for j := 0; j < blocksDown; j++ {
-			blkH := blockHeight
-			if !blockPadding && j == blocksDown-1 && height%blockHeight != 0 {
-				blkH = height % blockHeight
-			}
-			offset := int64(blockOffsets[j*blocksAcross+i])
-			n := int64(blockCounts[j*blocksAcross+i])
-			//switch compressionType {
-			//case cNone:
-			//	if b, ok := g.tFile.(*buffer); ok {
-			//		gData.buf, err = b.Slice(int(offset), int(n))
-			//	} else {
-			//		gData.buf = make([]byte, n)
-			//		_, err = g.tFile.ReadAt(gData.buf, offset)
-			//	}
-			//case cLZW:
-			//	r := lzw.NewReader(io.NewSectionReader(g.tFile, offset, n), lzw.MSB, 8)
-			//	defer r.Close()
-			//	gData.buf, err = ioutil.ReadAll(r)
-			//	if err != nil {
-			//		println(err)
-			//		//println("Block X: ", i, "Block Y: ", j, "Offset: ", offset, "n: ", n, "buf len: ", len(g.buf))
-			//		//	panic(err)
-			//	}
-			//case cDeflate, cDeflateOld:
-			//	r, err := zlib.NewReader(io.NewSectionReader(g.tFile, offset, n))
-			//	if err != nil {
-			//		return gEC(WithFunction("readData"), WithError(err))
-			//	}
-			//	gData.buf, err = ioutil.ReadAll(r)
-			//	r.Close()
-			//case cPackBits:
-			//
-			//default:
-			//	return gEC(WithErrorText(fmt.Sprintf("Unsupported compression value %d", compressionType)))
-			//
-			//}
-			xmin := i * blockWidth
-			ymin := j * blockHeight
-			xmax := xmin + blkW
-			ymax := ymin + blkH
-
-			xmax = minInt(xmax, width)
-			ymax = minInt(ymax, height)
-			gData.buf, err = gDataReader.read(offset, n)
-			if err != nil {
-				return gEC(WithFunction("readData"), WithError(err))
-			}
+// ReadData read the pixels of the tif opened by OpenGeoTifHeader
+func (g *GeoTif) ReadData() error {
+	return g.ReadDataContext(context.Background())
+}
 
-			gData.off = 0
-
-			if tPredictor == prHorizontal {
-				// does it make sense to extend this to 32 and 64 bits?
-				if g.Meta.BitsPerSample[0] == 16 {
-					var off int
-					spp := len(g.Meta.BitsPerSample) // samples per pixel
-					bpp := spp * 2                   // bytes per pixel
-					for y := ymin; y < ymax; y++ {
-						off += spp * 2
-						for x := 0; x < (xmax-xmin-1)*bpp; x += 2 {
-							v0 := g.byteOrder.Uint16(gData.buf[off-bpp : off-bpp+2])
-							v1 := g.byteOrder.Uint16(gData.buf[off : off+2])
-							g.byteOrder.PutUint16(gData.buf[off:off+2], v1+v0)
-							off += 2
-						}
-					}
-				} else if g.Meta.BitsPerSample[0] == 8 {
-					var off int
-					spp := len(g.Meta.BitsPerSample) // samples per pixel
-					for y := ymin; y < ymax; y++ {
-						off += spp
-						for x := 0; x < (xmax-xmin-1)*spp; x++ {
-							gData.buf[off] += gData.buf[off-spp]
-							off++
-						}
-					}
-				}
-			}
+// ReadDataContext is ReadData which stops at the next block when ctx is done, Data is not changed then
+func (g *GeoTif) ReadDataContext(ctx context.Context) error {
+	if err := g.readData(ctx); err != nil {
+		return gEC(WithFunction("GeoTif.ReadData"), WithError(err))
+	}
+	return nil
+}
 
-			switch g.Meta.mode {
-			case mGray, mGrayInvert:
-				switch g.Meta.SampleFormat {
-				case 1: // Unsigned integer data
-					switch g.Meta.BitsPerSample[0] {
-					case 8:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								i := y*width + x
-								gData.Data[i] = float64(gData.buf[gData.off])
-								gData.off++
-							}
-						}
-					case 16:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								value := g.byteOrder.Uint16(gData.buf[gData.off : gData.off+2])
-								i := y*width + x
-								gData.Data[i] = float64(value)
-								gData.off += 2
-							}
-						}
-					case 32:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								value := g.byteOrder.Uint32(gData.buf[gData.off : gData.off+4])
-								i := y*width + x
-								gData.Data[i] = float64(value)
-								gData.off += 4
-							}
-						}
-					case 64:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								value := g.byteOrder.Uint64(gData.buf[gData.off : gData.off+8])
-								i := y*width + x
-								gData.Data[i] = float64(value)
-								gData.off += 8
-							}
-						}
-					default:
-						return gEC(WithFunction("readData"), WithErrorText("Unsupported data format"))
-					}
-				case 2: // Signed integer data
-					switch g.Meta.BitsPerSample[0] {
-					case 8:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								i := y*width + x
-								gData.Data[i] = float64(int8(gData.buf[gData.off]))
-								gData.off++
-							}
-						}
-					case 16:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								value := int16(g.byteOrder.Uint16(gData.buf[gData.off : gData.off+2]))
-								i := y*width + x
-								gData.Data[i] = float64(value)
-								gData.off += 2
-							}
-						}
-					case 32:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								value := int32(g.byteOrder.Uint32(gData.buf[gData.off : gData.off+4]))
-								i := y*width + x
-								gData.Data[i] = float64(value)
-								gData.off += 4
-							}
-						}
-					case 64:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								value := int64(g.byteOrder.Uint64(gData.buf[gData.off : gData.off+8]))
-								i := y*width + x
-								gData.Data[i] = float64(value)
-								gData.off += 8
-							}
-						}
-					default:
-						return gEC(WithFunction("readData"), WithErrorText("Unsupported data format"))
-					}
-				case 3: // Floating point data
-					switch g.Meta.BitsPerSample[0] {
-					case 32:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								if gData.off <= len(gData.buf) {
-									bits := g.byteOrder.Uint32(gData.buf[gData.off : gData.off+4])
-									float := math.Float32frombits(bits)
-									i := y*width + x
-									gData.Data[i] = float64(float)
-									gData.off += 4
-								}
-							}
-							if xmax*4 < blockWidth {
-								gData.off = gData.off - xmax*4 + blockWidth*4
-							}
-						}
-					case 64:
-						for y := ymin; y < ymax; y++ {
-							for x := xmin; x < xmax; x++ {
-								if gData.off <= len(gData.buf) {
-									bits := g.byteOrder.Uint64(gData.buf[gData.off : gData.off+8])
-									float := math.Float64frombits(bits)
-									i := y*width + x
-									gData.Data[i] = float
-									gData.off += 8
-								}
-							}
-						}
-					default:
-						return gEC(WithFunction("readData"), WithErrorText("Unsupported data format"))
-					}
-				default:
-					return gEC(WithFunction("readData"), WithErrorText("Unsupported sample format"))
-				}
-			case mPaletted:
-				for y := ymin; y < ymax; y++ {
-					for x := xmin; x < xmax; x++ {
-						i := y*width + x
-						val := int(gData.buf[gData.off])
-						gData.Data[i] = float64(g.Meta.palette[val])
-						gData.off++
-					}
-				}
+// closedFile is the tFile of a closed GeoTif
+type closedFile struct{}
 
-			case mRGB:
-				if g.Meta.BitsPerSample[0] == 8 {
-					for y := ymin; y < ymax; y++ {
-						for x := xmin; x < xmax; x++ {
-							red := uint32(gData.buf[gData.off])
-							green := uint32(gData.buf[gData.off+1])
-							blue := uint32(gData.buf[gData.off+2])
-							a := uint32(255)
-							gData.off += 3
-							i := y*width + x
-							val := uint32((a << 24) | (red << 16) | (green << 8) | blue)
-							gData.Data[i] = float64(val)
-						}
-					}
-				} else if g.Meta.BitsPerSample[0] == 16 {
-					for y := ymin; y < ymax; y++ {
-						for x := xmin; x < xmax; x++ {
-							// the spec doesn't talk about 16-bit RGB images so
-							// I'm not sure why I bother with this. They specifically
-							// say that RGB images are 8-bits per channel. Anyhow,
-							// I rescale the 16-bits to an 8-bit channel for simplicity.
-							red := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+0:gData.off+2])) / 65535.0 * 255.0)
-							green := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+2:gData.off+4])) / 65535.0 * 255.0)
-							blue := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+4:gData.off+6])) / 65535.0 * 255.0)
-							a := uint32(255)
-							gData.off += 6
-							i := y*width + x
-							val := uint32((a << 24) | (red << 16) | (green << 8) | blue)
-							gData.Data[i] = float64(val)
-						}
-					}
-				} else {
-					return gEC(WithFunction("readData"), WithErrorText("Unsupported data format"))
-				}
-			case mNRGBA:
-				if g.Meta.BitsPerSample[0] == 8 {
-					for y := ymin; y < ymax; y++ {
-						for x := xmin; x < xmax; x++ {
-							red := uint32(gData.buf[gData.off])
-							green := uint32(gData.buf[gData.off+1])
-							blue := uint32(gData.buf[gData.off+2])
-							a := uint32(gData.buf[gData.off+3])
-							gData.off += 4
-							i := y*width + x
-							val := uint32((a << 24) | (red << 16) | (green << 8) | blue)
-							gData.Data[i] = float64(val)
-						}
-					}
-				} else if g.Meta.BitsPerSample[0] == 16 {
-					for y := ymin; y < ymax; y++ {
-						for x := xmin; x < xmax; x++ {
-							red := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+0:gData.off+2])) / 65535.0 * 255.0)
-							green := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+2:gData.off+4])) / 65535.0 * 255.0)
-							blue := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+4:gData.off+6])) / 65535.0 * 255.0)
-							a := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+6:gData.off+8])) / 65535.0 * 255.0)
-							gData.off += 8
-							i := y*width + x
-							val := uint32((a << 24) | (red << 16) | (green << 8) | blue)
-							gData.Data[i] = float64(val)
-						}
-					}
-				} else {
-					return gEC(WithFunction("readData"), WithErrorText("Unsupported data format"))
-				}
-			case mRGBA:
-				if g.Meta.BitsPerSample[0] == 16 {
-					for y := ymin; y < ymax; y++ {
-						for x := xmin; x < xmax; x++ {
-							red := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+0:gData.off+2])) / 65535.0 * 255.0)
-							green := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+2:gData.off+4])) / 65535.0 * 255.0)
-							blue := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+4:gData.off+6])) / 65535.0 * 255.0)
-							a := uint32(float64(g.byteOrder.Uint16(gData.buf[gData.off+6:gData.off+8])) / 65535.0 * 255.0)
-							gData.off += 8
-							i := y*width + x
-							val := uint32((a << 24) | (red << 16) | (green << 8) | blue)
-							gData.Data[i] = float64(val)
-						}
-					}
-				} else {
-					for y := ymin; y < ymax; y++ {
-						for x := xmin; x < xmax; x++ {
-							red := uint32(gData.buf[gData.off])
-							green := uint32(gData.buf[gData.off+1])
-							blue := uint32(gData.buf[gData.off+2])
-							a := uint32(gData.buf[gData.off+3])
-							gData.off += 4
-							i := y*width + x
-							val := uint32((a << 24) | (red << 16) | (green << 8) | blue)
-							gData.Data[i] = float64(val)
-						}
-					}
-				}
-			}
-		}
+func (closedFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, os.ErrClosed
+}
 
+// Close close the file (or unmap it, or close the zip), the pixels which are read are still in Data,
+// the blocks which are not read can not be read anymore and return an error matched by errors.Is(err, os.ErrClosed)
+// it should not be called while the blocks are being read
+func (g *GeoTif) Close() error {
+	if g.tFile == nil {
+		return nil
+	}
+	if _, ok := g.tFile.(closedFile); ok {
+		return nil
+	}
+	var err error
+	if c, ok := g.tFile.(io.Closer); ok {
+		err = c.Close()
+	}
+	g.tFile = closedFile{}
+	if err != nil {
+		return gEC(WithFunction("GeoTif.Close"), WithError(err))
 	}
-
-	g.Data = gData
 	return nil
 }
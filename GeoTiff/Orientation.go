@@ -0,0 +1,116 @@
+package GeoTiff
+
+// orientation is the Orientation (274) tag, where the first row and the first column of the file are shown
+//
+//	1 top, left     2 top, right     3 bottom, right     4 bottom, left
+//	5 left, top     6 right, top     7 right, bottom     8 left, bottom
+//
+// 5~8 swap the rows and the columns, Meta.Columns, Meta.Rows, Transform, Data and the blocks are the shown raster,
+// the blocks of the file are reordered so that they still come row by row from the left to the right
+// https://www.awaresystems.be/imaging/tiff/tifftags/orientation.html
+type orientation uint
+
+const (
+	orientTopLeft orientation = iota + 1
+	orientTopRight
+	orientBotRight
+	orientBotLeft
+	orientLeftTop
+	orientRightTop
+	orientRightBot
+	orientLeftBot
+)
+
+func (o orientation) transposed() bool {
+	return o >= orientLeftTop && o <= orientLeftBot
+}
+func (o orientation) flipCols() bool {
+	return o == orientTopRight || o == orientBotRight || o == orientRightBot || o == orientLeftBot
+}
+func (o orientation) flipRows() bool {
+	return o == orientBotRight || o == orientBotLeft || o == orientRightTop || o == orientRightBot
+}
+
+// storedPixel return the pixel in the file of width x height for the shown pixel
+func (o orientation) storedPixel(x, y, width, height int) (col, row int) {
+	if o.transposed() {
+		x, y = y, x
+	}
+	if o.flipCols() {
+		x = width - 1 - x
+	}
+	if o.flipRows() {
+		y = height - 1 - y
+	}
+	return x, y
+}
+
+// shownWindow return where the window of the file of width x height is shown
+func (o orientation) shownWindow(x0, y0, w, h, width, height int) (int, int, int, int) {
+	if o.flipCols() {
+		x0 = width - x0 - w
+	}
+	if o.flipRows() {
+		y0 = height - y0 - h
+	}
+	if o.transposed() {
+		return y0, x0, h, w
+	}
+	return x0, y0, w, h
+}
+
+// shownTransform make the transform of the pixels of the file of width x height a transform of the shown pixels
+func (o orientation) shownTransform(t [6]float64, width, height int) [6]float64 {
+	// stored map the corner of the shown pixels to the file
+	stored := func(x, y float64) (float64, float64) {
+		if o.transposed() {
+			x, y = y, x
+		}
+		if o.flipCols() {
+			x = float64(width) - x
+		}
+		if o.flipRows() {
+			y = float64(height) - y
+		}
+		return x, y
+	}
+	c0, r0 := stored(0, 0)
+	c1, r1 := stored(1, 0)
+	c2, r2 := stored(0, 1)
+	return [6]float64{
+		t[0] + t[1]*c0 + t[2]*r0, t[1]*(c1-c0) + t[2]*(r1-r0), t[1]*(c2-c0) + t[2]*(r2-r0),
+		t[3] + t[4]*c0 + t[5]*r0, t[4]*(c1-c0) + t[5]*(r1-r0), t[4]*(c2-c0) + t[5]*(r2-r0),
+	}
+}
+
+// shownBlock reorder the values of the block of the file at [x0, y0, w, h] as the shown window sx0, sy0, sw, sh
+func (o orientation) shownBlock(data []float64, x0, y0, w, sx0, sy0, sw, sh, width, height int) []float64 {
+	out := make([]float64, len(data))
+	for y := 0; y < sh; y++ {
+		for x := 0; x < sw; x++ {
+			c, r := o.storedPixel(sx0+x, sy0+y, width, height)
+			out[y*sw+x] = data[(r-y0)*w+c-x0]
+		}
+	}
+	return out
+}
+
+// storedIndex return the index of the block of the file which is the k-th shown block
+func (bl *blockLayout) storedIndex(k int) int {
+	o := bl.orientation
+	across := bl.blocksAcross
+	if o.transposed() {
+		across = bl.blocksDown
+	}
+	bx, by := k%across, k/across
+	if o.transposed() {
+		bx, by = by, bx
+	}
+	if o.flipCols() {
+		bx = bl.blocksAcross - 1 - bx
+	}
+	if o.flipRows() {
+		by = bl.blocksDown - 1 - by
+	}
+	return by*bl.blocksAcross + bx
+}
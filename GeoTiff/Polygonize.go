@@ -0,0 +1,194 @@
+package GeoTiff
+
+import (
+	"math"
+)
+
+type polygonizeConfig struct {
+	eightConnected bool
+	tolerance      float64
+	mask           *GeoTif
+	field          string
+}
+
+type PolygonizeOptions func(pc *polygonizeConfig)
+
+// WithEightConnected join the pixels touching at the corner too, default is 4 connected
+func WithEightConnected(eightConnected bool) PolygonizeOptions {
+	return func(pc *polygonizeConfig) {
+		pc.eightConnected = eightConnected
+	}
+}
+
+// WithSimplify simplify the rings by Douglas-Peucker, tolerance is in the unit of the CRS
+func WithSimplify(tolerance float64) PolygonizeOptions {
+	return func(pc *polygonizeConfig) {
+		pc.tolerance = tolerance
+	}
+}
+
+// WithPolygonizeMask skip the pixels whose mask is 0 or nodata, the mask should be on the same grid
+func WithPolygonizeMask(mask *GeoTif) PolygonizeOptions {
+	return func(pc *polygonizeConfig) {
+		pc.mask = mask
+	}
+}
+
+// WithPolygonizeField set the name of the value property, default is "value"
+func WithPolygonizeField(field string) PolygonizeOptions {
+	return func(pc *polygonizeConfig) {
+		pc.field = field
+	}
+}
+
+// Polygonize trace the connected regions of the same value into polygons (with holes)
+// every region is a Polygon feature with the property value, the nodata pixels are skipped
+func (g *GeoTif) Polygonize(opts ...PolygonizeOptions) (*GeoJSONFeatureCollection, error) {
+	var gEC = NewGeoErrorCreator("GeoTif.Polygonize")
+	cfg := polygonizeConfig{field: "value"}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := g.pixels(); err != nil {
+		return nil, gEC(WithError(err))
+	}
+	if cfg.mask != nil {
+		if err := g.CheckSameGrid(cfg.mask); err != nil {
+			return nil, gEC(WithError(err), WithMsg("mask"))
+		}
+		if err := cfg.mask.pixels(); err != nil {
+			return nil, gEC(WithError(err), WithMsg("mask"))
+		}
+	}
+	width := int(g.Meta.Columns)
+	height := int(g.Meta.Rows)
+	valid := func(i int) bool {
+		if g.IsNodata(g.Data.Data[i]) {
+			return false
+		}
+		if cfg.mask != nil {
+			m := cfg.mask.Data.Data[i]
+			return m != 0 && !cfg.mask.IsNodata(m)
+		}
+		return true
+	}
+
+	neighbors := [][2]int{{1, 0}, {-1, 0}, {0, 1}, {0, -1}}
+	if cfg.eightConnected {
+		neighbors = append(neighbors, [2]int{1, 1}, [2]int{1, -1}, [2]int{-1, 1}, [2]int{-1, -1})
+	}
+	labels := make([]int32, width*height)
+	fc := NewFeatureCollection()
+	var stack, region []int
+	label := int32(0)
+	for start := range labels {
+		if labels[start] != 0 || !valid(start) {
+			continue
+		}
+		label++
+		value := g.Data.Data[start]
+		labels[start] = label
+		stack = append(stack[:0], start)
+		region = region[:0]
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			region = append(region, i)
+			col, row := i%width, i/width
+			for _, n := range neighbors {
+				c, r := col+n[0], row+n[1]
+				if c < 0 || r < 0 || c >= width || r >= height {
+					continue
+				}
+				j := r*width + c
+				if labels[j] == 0 && g.Data.Data[j] == value && valid(j) {
+					labels[j] = label
+					stack = append(stack, j)
+				}
+			}
+		}
+		// the sides of the region which are not shared with the same region
+		es := newEdgeSet()
+		inRegion := func(c, r int) bool {
+			return c >= 0 && r >= 0 && c < width && r < height && labels[r*width+c] == label
+		}
+		for _, i := range region {
+			c, r := i%width, i/width
+			x0, y0, x1, y1 := float64(c), float64(r), float64(c+1), float64(r+1)
+			if !inRegion(c, r-1) {
+				es.add([2]float64{x0, y0}, [2]float64{x1, y0})
+			}
+			if !inRegion(c+1, r) {
+				es.add([2]float64{x1, y0}, [2]float64{x1, y1})
+			}
+			if !inRegion(c, r+1) {
+				es.add([2]float64{x1, y1}, [2]float64{x0, y1})
+			}
+			if !inRegion(c-1, r) {
+				es.add([2]float64{x0, y1}, [2]float64{x0, y0})
+			}
+		}
+		rings := g.pixelToGeoRings(es.rings())
+		if cfg.tolerance > 0 {
+			for k := range rings {
+				rings[k] = simplifyRing(rings[k], cfg.tolerance)
+			}
+		}
+		for _, polygon := range ringsToPolygons(rings) {
+			fc.Features = append(fc.Features, NewFeature(NewPolygon(polygon), map[string]interface{}{
+				cfg.field: value,
+			}))
+		}
+	}
+	return fc, nil
+}
+
+// simplifyRing simplify the closed ring by Douglas-Peucker, the ring is kept when it would collapse
+func simplifyRing(ring [][2]float64, tolerance float64) [][2]float64 {
+	if len(ring) < 5 {
+		return ring
+	}
+	// split at the farthest point from the first one, so both halves are open lines
+	far, farDist := 0, -1.0
+	for i, p := range ring {
+		if d := math.Hypot(p[0]-ring[0][0], p[1]-ring[0][1]); d > farDist {
+			far, farDist = i, d
+		}
+	}
+	a := douglasPeucker(ring[:far+1], tolerance)
+	b := douglasPeucker(ring[far:], tolerance)
+	ret := append(append([][2]float64{}, a...), b[1:]...)
+	if len(ret) < 4 {
+		return ring
+	}
+	return ret
+}
+
+func douglasPeucker(line [][2]float64, tolerance float64) [][2]float64 {
+	if len(line) < 3 {
+		return line
+	}
+	a, b := line[0], line[len(line)-1]
+	index, maxDist := 0, 0.0
+	for i := 1; i < len(line)-1; i++ {
+		if d := segmentDistance(line[i], a, b); d > maxDist {
+			index, maxDist = i, d
+		}
+	}
+	if maxDist <= tolerance {
+		return [][2]float64{a, b}
+	}
+	left := douglasPeucker(line[:index+1], tolerance)
+	right := douglasPeucker(line[index:], tolerance)
+	return append(left[:len(left)-1:len(left)-1], right...)
+}
+
+func segmentDistance(p, a, b [2]float64) float64 {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	l := dx*dx + dy*dy
+	if l == 0 {
+		return math.Hypot(p[0]-a[0], p[1]-a[1])
+	}
+	t := math.Max(0, math.Min(1, ((p[0]-a[0])*dx+(p[1]-a[1])*dy)/l))
+	return math.Hypot(p[0]-a[0]-t*dx, p[1]-a[1]-t*dy)
+}
@@ -0,0 +1,233 @@
+package GeoTiff
+
+import (
+	"math"
+	"sort"
+)
+
+// the vector tools (contour, polygonize) build polygons by adding the boundary of small pieces
+// (pixels or parts of cells) as directed edges, the edges shared by two pieces cancel each other
+// and the rest are chained into rings
+
+type pointKey [2]int64
+
+// keyOf round the coordinate so that the same point computed twice matches
+func keyOf(p [2]float64) pointKey {
+	return pointKey{int64(math.Round(p[0] * 1e6)), int64(math.Round(p[1] * 1e6))}
+}
+
+type directedEdge struct {
+	from, to [2]float64
+}
+
+type edgeSet struct {
+	edges map[[2]pointKey]directedEdge
+}
+
+func newEdgeSet() *edgeSet {
+	return &edgeSet{edges: map[[2]pointKey]directedEdge{}}
+}
+
+// add the edge, or remove its reverse when it exists
+func (es *edgeSet) add(from, to [2]float64) {
+	a, b := keyOf(from), keyOf(to)
+	if a == b {
+		return
+	}
+	if _, ok := es.edges[[2]pointKey{b, a}]; ok {
+		delete(es.edges, [2]pointKey{b, a})
+		return
+	}
+	es.edges[[2]pointKey{a, b}] = directedEdge{from: from, to: to}
+}
+
+// addRing add the edges of a ring, the ring should not be closed (the last point is not the first one)
+func (es *edgeSet) addRing(ring [][2]float64) {
+	for i := range ring {
+		es.add(ring[i], ring[(i+1)%len(ring)])
+	}
+}
+
+// rings chain the edges into closed rings (the last point is the first one)
+// the collinear points are removed
+func (es *edgeSet) rings() [][][2]float64 {
+	outgoing := map[pointKey][]directedEdge{}
+	keys := make([][2]pointKey, 0, len(es.edges))
+	for k, e := range es.edges {
+		outgoing[k[0]] = append(outgoing[k[0]], e)
+		keys = append(keys, k)
+	}
+	// start from the same edge every time, so the result is stable
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a[0] != b[0] {
+			return a[0][1] < b[0][1] || (a[0][1] == b[0][1] && a[0][0] < b[0][0])
+		}
+		return a[1][1] < b[1][1] || (a[1][1] == b[1][1] && a[1][0] < b[1][0])
+	})
+	used := map[[2]pointKey]bool{}
+	var rings [][][2]float64
+	for _, k := range keys {
+		if used[k] {
+			continue
+		}
+		start := k[0]
+		ring := [][2]float64{es.edges[k].from}
+		cur := k
+		for {
+			used[cur] = true
+			e := es.edges[cur]
+			ring = append(ring, e.to)
+			if cur[1] == start {
+				break
+			}
+			next, ok := pickNextEdge(outgoing[cur[1]], used, e)
+			if !ok {
+				break
+			}
+			cur = [2]pointKey{keyOf(next.from), keyOf(next.to)}
+		}
+		if len(ring) >= 4 && keyOf(ring[0]) == keyOf(ring[len(ring)-1]) {
+			rings = append(rings, removeCollinear(ring))
+		}
+	}
+	return rings
+}
+
+// pickNextEdge choose the unused outgoing edge which turns most to the right,
+// so rings touching at a vertex are split there instead of crossing each other
+func pickNextEdge(candidates []directedEdge, used map[[2]pointKey]bool, in directedEdge) (directedEdge, bool) {
+	var best directedEdge
+	bestAngle := math.Inf(1)
+	found := false
+	inAngle := math.Atan2(in.to[1]-in.from[1], in.to[0]-in.from[0])
+	for _, c := range candidates {
+		if used[[2]pointKey{keyOf(c.from), keyOf(c.to)}] {
+			continue
+		}
+		angle := math.Atan2(c.to[1]-c.from[1], c.to[0]-c.from[0]) - inAngle
+		for angle <= -math.Pi {
+			angle += 2 * math.Pi
+		}
+		for angle > math.Pi {
+			angle -= 2 * math.Pi
+		}
+		if !found || angle < bestAngle {
+			best, bestAngle, found = c, angle, true
+		}
+	}
+	return best, found
+}
+
+// removeCollinear the ring is closed and stays closed
+func removeCollinear(ring [][2]float64) [][2]float64 {
+	pts := ring[:len(ring)-1]
+	n := len(pts)
+	ret := make([][2]float64, 0, n+1)
+	for i := 0; i < n; i++ {
+		a, b, c := pts[(i+n-1)%n], pts[i], pts[(i+1)%n]
+		cross := (b[0]-a[0])*(c[1]-b[1]) - (b[1]-a[1])*(c[0]-b[0])
+		if math.Abs(cross) > 1e-12 {
+			ret = append(ret, b)
+		}
+	}
+	if len(ret) < 3 {
+		return ring
+	}
+	return append(ret, ret[0])
+}
+
+// ringArea is the signed area of a closed ring, it is positive when counterclockwise (y up)
+func ringArea(ring [][2]float64) float64 {
+	area := 0.0
+	for i := 0; i+1 < len(ring); i++ {
+		area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+	}
+	return area / 2
+}
+
+func reverseRing(ring [][2]float64) {
+	for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+		ring[i], ring[j] = ring[j], ring[i]
+	}
+}
+
+func pointInRing(p [2]float64, ring [][2]float64) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a[1] > p[1]) != (b[1] > p[1]) && p[0] < (b[0]-a[0])*(p[1]-a[1])/(b[1]-a[1])+a[0] {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// ringsToPolygons sort the rings (in a y up coordinate) into polygons,
+// a ring is a hole when it is inside an odd number of other rings, and it belongs to the smallest outer ring containing it
+// outer rings are made counterclockwise and holes clockwise (RFC 7946)
+func ringsToPolygons(rings [][][2]float64) [][][][2]float64 {
+	type ringInfo struct {
+		ring  [][2]float64
+		area  float64
+		depth int
+		outer int
+	}
+	infos := make([]ringInfo, len(rings))
+	for i, r := range rings {
+		infos[i] = ringInfo{ring: r, area: math.Abs(ringArea(r)), outer: -1}
+	}
+	// a point of the ring which is not on the other rings: the middle of the first edge
+	probe := func(r [][2]float64) [2]float64 {
+		return [2]float64{(r[0][0] + r[1][0]) / 2, (r[0][1] + r[1][1]) / 2}
+	}
+	for i := range infos {
+		p := probe(infos[i].ring)
+		smallest := -1
+		for j := range infos {
+			if i == j || infos[j].area <= infos[i].area || !pointInRing(p, infos[j].ring) {
+				continue
+			}
+			infos[i].depth++
+			if smallest == -1 || infos[j].area < infos[smallest].area {
+				smallest = j
+			}
+		}
+		infos[i].outer = smallest
+	}
+	var polygons [][][][2]float64
+	index := map[int]int{}
+	for i, info := range infos {
+		if info.depth%2 == 0 {
+			if ringArea(info.ring) < 0 {
+				reverseRing(info.ring)
+			}
+			index[i] = len(polygons)
+			polygons = append(polygons, [][][2]float64{info.ring})
+		}
+	}
+	for _, info := range infos {
+		if info.depth%2 == 1 {
+			if ringArea(info.ring) > 0 {
+				reverseRing(info.ring)
+			}
+			if p, ok := index[info.outer]; ok {
+				polygons[p] = append(polygons[p], info.ring)
+			}
+		}
+	}
+	return polygons
+}
+
+// pixelToGeoRings convert the rings (or lines) in pixel/line coordinate to the CRS of the raster
+func (g *GeoTif) pixelToGeoRings(rings [][][2]float64) [][][2]float64 {
+	ret := make([][][2]float64, len(rings))
+	for i, ring := range rings {
+		ret[i] = make([][2]float64, len(ring))
+		for j, p := range ring {
+			x, y := g.Transform.PixelToGeo(p[0], p[1])
+			ret[i][j] = [2]float64{x, y}
+		}
+	}
+	return ret
+}
@@ -0,0 +1,101 @@
+package GeoTiff
+
+import (
+	"fmt"
+	"math"
+)
+
+// only the CRS used by the tiles are supported: longitude/latitude, web mercator and WGS84 UTM
+
+const (
+	wgs84A = 6378137.0
+	wgs84F = 1 / 298.257223563
+	// maxMercatorLatitude is the latitude where the web mercator world is square
+	maxMercatorLatitude = 85.0511287798066
+	// webMercatorHalf is the half width of the web mercator world
+	webMercatorHalf = math.Pi * wgs84A
+)
+
+// projection convert the coordinates of a CRS from and to longitude/latitude in degrees
+type projection struct {
+	toLonLat   func(x, y float64) (float64, float64)
+	fromLonLat func(lon, lat float64) (float64, float64)
+}
+
+// projection return the projection of the CRS of the tif
+func (g *GeoTif) projection() (projection, error) {
+	if g.IsGeographic() {
+		identity := func(x, y float64) (float64, float64) { return x, y }
+		return projection{toLonLat: identity, fromLonLat: identity}, nil
+	}
+	epsg := g.Meta.EPSGCode
+	switch {
+	case epsg == 3857 || epsg == 3785 || epsg == 900913 || epsg == 102100:
+		return projection{toLonLat: mercatorToLonLat, fromLonLat: lonLatToMercator}, nil
+	case epsg > 32600 && epsg <= 32660:
+		return utmProjection(int(epsg-32600), false), nil
+	case epsg > 32700 && epsg <= 32760:
+		return utmProjection(int(epsg-32700), true), nil
+	}
+	return projection{}, gEC(WithKind(ErrUnsupportedCRS), WithFunction("GeoTif.projection"), WithErrorText(fmt.Sprintf("EPSG %d is not supported", epsg)))
+}
+
+func lonLatToMercator(lon, lat float64) (float64, float64) {
+	lat = math.Max(-maxMercatorLatitude, math.Min(maxMercatorLatitude, lat))
+	x := lon * math.Pi / 180 * wgs84A
+	y := math.Log(math.Tan(math.Pi/4+lat*math.Pi/360)) * wgs84A
+	return x, y
+}
+
+func mercatorToLonLat(x, y float64) (float64, float64) {
+	lon := x / wgs84A * 180 / math.Pi
+	lat := (2*math.Atan(math.Exp(y/wgs84A)) - math.Pi/2) * 180 / math.Pi
+	return lon, lat
+}
+
+// utmProjection is the transverse mercator of Snyder (1987), accurate to a millimeter within the zone
+func utmProjection(zone int, south bool) projection {
+	const k0 = 0.9996
+	e2 := wgs84F * (2 - wgs84F)
+	ep2 := e2 / (1 - e2)
+	lon0 := float64((zone-1)*6-180+3) * math.Pi / 180
+	falseNorthing := 0.0
+	if south {
+		falseNorthing = 10000000
+	}
+	m1 := 1 - e2/4 - 3*e2*e2/64 - 5*e2*e2*e2/256
+	m2 := 3*e2/8 + 3*e2*e2/32 + 45*e2*e2*e2/1024
+	m3 := 15*e2*e2/256 + 45*e2*e2*e2/1024
+	m4 := 35 * e2 * e2 * e2 / 3072
+	fromLonLat := func(lon, lat float64) (float64, float64) {
+		phi := lat * math.Pi / 180
+		sin, cos, tan := math.Sin(phi), math.Cos(phi), math.Tan(phi)
+		n := wgs84A / math.Sqrt(1-e2*sin*sin)
+		t := tan * tan
+		c := ep2 * cos * cos
+		a := cos * (lon*math.Pi/180 - lon0)
+		m := wgs84A * (m1*phi - m2*math.Sin(2*phi) + m3*math.Sin(4*phi) - m4*math.Sin(6*phi))
+		x := k0*n*(a+(1-t+c)*math.Pow(a, 3)/6+(5-18*t+t*t+72*c-58*ep2)*math.Pow(a, 5)/120) + 500000
+		y := k0*(m+n*tan*(a*a/2+(5-t+9*c+4*c*c)*math.Pow(a, 4)/24+(61-58*t+t*t+600*c-330*ep2)*math.Pow(a, 6)/720)) + falseNorthing
+		return x, y
+	}
+	e1 := (1 - math.Sqrt(1-e2)) / (1 + math.Sqrt(1-e2))
+	toLonLat := func(x, y float64) (float64, float64) {
+		mu := (y - falseNorthing) / k0 / (wgs84A * m1)
+		phi1 := mu + (3*e1/2-27*math.Pow(e1, 3)/32)*math.Sin(2*mu) +
+			(21*e1*e1/16-55*math.Pow(e1, 4)/32)*math.Sin(4*mu) +
+			151*math.Pow(e1, 3)/96*math.Sin(6*mu) +
+			1097*math.Pow(e1, 4)/512*math.Sin(8*mu)
+		sin, cos, tan := math.Sin(phi1), math.Cos(phi1), math.Tan(phi1)
+		c1 := ep2 * cos * cos
+		t1 := tan * tan
+		n1 := wgs84A / math.Sqrt(1-e2*sin*sin)
+		r1 := wgs84A * (1 - e2) / math.Pow(1-e2*sin*sin, 1.5)
+		d := (x - 500000) / (n1 * k0)
+		phi := phi1 - n1*tan/r1*(d*d/2-(5+3*t1+10*c1-4*c1*c1-9*ep2)*math.Pow(d, 4)/24+
+			(61+90*t1+298*c1+45*t1*t1-252*ep2-3*c1*c1)*math.Pow(d, 6)/720)
+		lon := lon0 + (d-(1+2*t1+c1)*math.Pow(d, 3)/6+(5-2*c1+28*t1-3*c1*c1+8*ep2+24*t1*t1)*math.Pow(d, 5)/120)/cos
+		return lon * 180 / math.Pi, phi * 180 / math.Pi
+	}
+	return projection{toLonLat: toLonLat, fromLonLat: fromLonLat}
+}
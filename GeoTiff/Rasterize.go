@@ -0,0 +1,364 @@
+package GeoTiff
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// pixelMask marks the pixels of a window (x0, y0, w, h) of the raster
+type pixelMask struct {
+	x0, y0, w, h int
+	bits         []bool
+}
+
+func newPixelMask(x0, y0, x1, y1 int) *pixelMask {
+	w := maxInt(x1-x0, 0)
+	h := maxInt(y1-y0, 0)
+	return &pixelMask{x0: x0, y0: y0, w: w, h: h, bits: make([]bool, w*h)}
+}
+
+func (m *pixelMask) set(col, row int) {
+	c, r := col-m.x0, row-m.y0
+	if c < 0 || r < 0 || c >= m.w || r >= m.h {
+		return
+	}
+	m.bits[r*m.w+c] = true
+}
+
+// setSegment mark the pixels of the window which the segment passes through,
+// the segment is clipped to the window before it is walked, the segment with an infinite or NaN point is skipped
+func (m *pixelMask) setSegment(a, b [2]float64) {
+	if !isFinitePoint(a) || !isFinitePoint(b) {
+		return
+	}
+	if a, b, ok := clipSegment(a, b, float64(m.x0), float64(m.y0), float64(m.x0+m.w), float64(m.y0+m.h)); ok {
+		traverseSegment(a, b, m.set)
+	}
+}
+
+func isFinitePoint(p [2]float64) bool {
+	return !math.IsNaN(p[0]) && !math.IsNaN(p[1]) && !math.IsInf(p[0], 0) && !math.IsInf(p[1], 0)
+}
+
+// clipSegment clip the segment to the box (x0, y0, x1, y1), ok is false when it is out of the box
+// Liang–Barsky, https://en.wikipedia.org/wiki/Liang%E2%80%93Barsky_algorithm
+func clipSegment(a, b [2]float64, x0, y0, x1, y1 float64) ([2]float64, [2]float64, bool) {
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	t0, t1 := 0.0, 1.0
+	for _, e := range [4][2]float64{{-dx, a[0] - x0}, {dx, x1 - a[0]}, {-dy, a[1] - y0}, {dy, y1 - a[1]}} {
+		p, q := e[0], e[1]
+		if p == 0 {
+			if q < 0 {
+				return a, b, false
+			}
+			continue
+		}
+		t := q / p
+		if p < 0 {
+			t0 = math.Max(t0, t)
+		} else {
+			t1 = math.Min(t1, t)
+		}
+		if t0 > t1 {
+			return a, b, false
+		}
+	}
+	return [2]float64{a[0] + t0*dx, a[1] + t0*dy}, [2]float64{a[0] + t1*dx, a[1] + t1*dy}, true
+}
+
+// each call fn for the marked pixels row by row
+func (m *pixelMask) each(fn func(col, row int)) {
+	for r := 0; r < m.h; r++ {
+		for c := 0; c < m.w; c++ {
+			if m.bits[r*m.w+c] {
+				fn(c+m.x0, r+m.y0)
+			}
+		}
+	}
+}
+
+// toPixelRings convert the rings to the pixel/line coordinate of the raster
+func (g *GeoTif) toPixelRings(rings [][][2]float64) [][][2]float64 {
+	ret := make([][][2]float64, len(rings))
+	for i, ring := range rings {
+		ret[i] = make([][2]float64, len(ring))
+		for j, p := range ring {
+			c, r := g.Transform.GeoToPixel(p[0], p[1])
+			ret[i][j] = [2]float64{c, r}
+		}
+	}
+	return ret
+}
+
+// ringsWindow return the pixel window (clipped to the raster) which contains the rings,
+// the infinite and NaN points are skipped
+func ringsWindow(rings [][][2]float64, width, height int) (x0, y0, x1, y1 int) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+	for _, ring := range rings {
+		for _, p := range ring {
+			if !isFinitePoint(p) {
+				continue
+			}
+			minX = math.Min(minX, p[0])
+			minY = math.Min(minY, p[1])
+			maxX = math.Max(maxX, p[0])
+			maxY = math.Max(maxY, p[1])
+		}
+	}
+	if math.IsInf(minX, 1) {
+		return 0, 0, 0, 0
+	}
+	// clipped before the conversion, a point far away does not overflow int
+	x0 = int(math.Min(math.Max(math.Floor(minX), 0), float64(width)))
+	y0 = int(math.Min(math.Max(math.Floor(minY), 0), float64(height)))
+	x1 = int(math.Max(math.Min(math.Floor(maxX)+1, float64(width)), 0))
+	y1 = int(math.Max(math.Min(math.Floor(maxY)+1, float64(height)), 0))
+	return
+}
+
+// rasterizePolygon mark the pixels of the polygon (in pixel/line coordinate) on a new mask
+// a pixel is inside when its center is inside (even-odd rule, so the holes are excluded),
+// with allTouched all the pixels which the boundary passes through are marked too
+func rasterizePolygon(rings [][][2]float64, width, height int, allTouched bool) *pixelMask {
+	mask := newPixelMask(ringsWindow(rings, width, height))
+	if mask.w == 0 || mask.h == 0 {
+		return mask
+	}
+	var xs []float64
+	for row := mask.y0; row < mask.y0+mask.h; row++ {
+		yc := float64(row) + 0.5
+		xs = xs[:0]
+		for _, ring := range rings {
+			n := len(ring)
+			for i := 0; i < n; i++ {
+				a, b := ring[i], ring[(i+1)%n]
+				if !isFinitePoint(a) || !isFinitePoint(b) {
+					continue
+				}
+				if (a[1] <= yc && b[1] > yc) || (b[1] <= yc && a[1] > yc) {
+					xs = append(xs, a[0]+(yc-a[1])/(b[1]-a[1])*(b[0]-a[0]))
+				}
+			}
+		}
+		sort.Float64s(xs)
+		for i := 0; i+1 < len(xs); i += 2 {
+			// centers in [xs[i], xs[i+1])
+			from := int(math.Max(math.Ceil(xs[i]-0.5), float64(mask.x0)))
+			to := int(math.Min(math.Ceil(xs[i+1]-0.5), float64(mask.x0+mask.w)))
+			for col := from; col < to; col++ {
+				mask.set(col, row)
+			}
+		}
+	}
+	if allTouched {
+		for _, ring := range rings {
+			for i := 0; i+1 < len(ring); i++ {
+				mask.setSegment(ring[i], ring[i+1])
+			}
+		}
+	}
+	return mask
+}
+
+// traverseSegment call mark for every pixel the segment passes through, the segment should be clipped by setSegment
+// http://www.cse.yorku.ca/~amana/research/grid.pdf
+func traverseSegment(a, b [2]float64, mark func(col, row int)) {
+	cx, cy := int(math.Floor(a[0])), int(math.Floor(a[1]))
+	ex, ey := int(math.Floor(b[0])), int(math.Floor(b[1]))
+	dx, dy := b[0]-a[0], b[1]-a[1]
+	stepX, stepY := 1, 1
+	tMaxX, tMaxY := math.Inf(1), math.Inf(1)
+	tDeltaX, tDeltaY := math.Inf(1), math.Inf(1)
+	if dx > 0 {
+		tMaxX = (float64(cx+1) - a[0]) / dx
+		tDeltaX = 1 / dx
+	} else if dx < 0 {
+		stepX = -1
+		tMaxX = (a[0] - float64(cx)) / -dx
+		tDeltaX = 1 / -dx
+	}
+	if dy > 0 {
+		tMaxY = (float64(cy+1) - a[1]) / dy
+		tDeltaY = 1 / dy
+	} else if dy < 0 {
+		stepY = -1
+		tMaxY = (a[1] - float64(cy)) / -dy
+		tDeltaY = 1 / -dy
+	}
+	steps := absInt(ex-cx) + absInt(ey-cy)
+	mark(cx, cy)
+	for i := 0; i < steps; i++ {
+		if tMaxX < tMaxY {
+			tMaxX += tDeltaX
+			cx += stepX
+		} else {
+			tMaxY += tDeltaY
+			cy += stepY
+		}
+		mark(cx, cy)
+	}
+}
+
+func absInt(a int) int {
+	if a < 0 {
+		return -a
+	}
+	return a
+}
+
+// MergeMode is how the burn value is merged with the value of the raster
+type MergeMode int
+
+const (
+	MergeReplace MergeMode = iota
+	MergeAdd
+	MergeMax
+)
+
+type rasterizeConfig struct {
+	burnValue  float64
+	attribute  string
+	merge      MergeMode
+	allTouched bool
+}
+
+type RasterizeOptions func(rc *rasterizeConfig)
+
+// WithBurnValue burn the same value for all the features, default is 1
+func WithBurnValue(v float64) RasterizeOptions {
+	return func(rc *rasterizeConfig) {
+		rc.burnValue = v
+	}
+}
+
+// WithBurnAttribute burn the numeric property of every feature
+func WithBurnAttribute(field string) RasterizeOptions {
+	return func(rc *rasterizeConfig) {
+		rc.attribute = field
+	}
+}
+func WithMergeMode(merge MergeMode) RasterizeOptions {
+	return func(rc *rasterizeConfig) {
+		rc.merge = merge
+	}
+}
+
+// WithAllTouched burn all the pixels touched by the polygons, not only those whose center is inside
+func WithAllTouched(allTouched bool) RasterizeOptions {
+	return func(rc *rasterizeConfig) {
+		rc.allTouched = allTouched
+	}
+}
+
+func featureBurnValue(feature GeoJSONFeature, field string) (float64, error) {
+	v, ok := feature.Properties[field]
+	if !ok {
+		return 0, gEC(WithFunction("featureBurnValue"), WithErrorText(fmt.Sprintf("there is no property [%s]", field)))
+	}
+	switch value := v.(type) {
+	case float64:
+		return value, nil
+	case bool:
+		return boolToFloat(value), nil
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, gEC(WithFunction("featureBurnValue"), WithError(err))
+		}
+		return f, nil
+	}
+	return 0, gEC(WithFunction("featureBurnValue"), WithErrorText(fmt.Sprintf("property [%s] is not a number: %v", field, v)))
+}
+
+// Burn rasterize the features onto g in place
+// points burn the pixel which contains them, lines burn all the pixels they pass through,
+// polygons burn the pixels whose center is inside (or all touched with WithAllTouched)
+// a pixel is burnt at most once by a feature
+func (g *GeoTif) Burn(fc *GeoJSONFeatureCollection, opts ...RasterizeOptions) error {
+	var gEC = NewGeoErrorCreator("GeoTif.Burn")
+	cfg := rasterizeConfig{
+		burnValue: 1,
+		merge:     MergeReplace,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := g.pixels(); err != nil {
+		return gEC(WithError(err))
+	}
+	width := int(g.Meta.Columns)
+	height := int(g.Meta.Rows)
+	for i, feature := range fc.Features {
+		if feature.Geometry == nil {
+			continue
+		}
+		value := cfg.burnValue
+		if cfg.attribute != "" {
+			var err error
+			if value, err = featureBurnValue(feature, cfg.attribute); err != nil {
+				return gEC(WithError(err), WithMsg(fmt.Sprintf("feature %d", i)))
+			}
+		}
+		points, err := feature.Geometry.Points()
+		if err != nil {
+			return gEC(WithError(err), WithMsg(fmt.Sprintf("feature %d", i)))
+		}
+		lines, err := feature.Geometry.Lines()
+		if err != nil {
+			return gEC(WithError(err), WithMsg(fmt.Sprintf("feature %d", i)))
+		}
+		polygons, err := feature.Geometry.Polygons()
+		if err != nil {
+			return gEC(WithError(err), WithMsg(fmt.Sprintf("feature %d", i)))
+		}
+
+		var allRings [][][2]float64
+		pixelPoints := g.toPixelRings([][][2]float64{points})[0]
+		pixelLines := g.toPixelRings(lines)
+		pixelPolygons := make([][][][2]float64, len(polygons))
+		for j, polygon := range polygons {
+			pixelPolygons[j] = g.toPixelRings(polygon)
+			allRings = append(allRings, pixelPolygons[j]...)
+		}
+		allRings = append(append(allRings, pixelLines...), pixelPoints)
+		mask := newPixelMask(ringsWindow(allRings, width, height))
+		for _, p := range pixelPoints {
+			if isFinitePoint(p) && p[0] >= 0 && p[1] >= 0 && p[0] < float64(width) && p[1] < float64(height) {
+				mask.set(int(p[0]), int(p[1]))
+			}
+		}
+		for _, line := range pixelLines {
+			for j := 0; j+1 < len(line); j++ {
+				mask.setSegment(line[j], line[j+1])
+			}
+		}
+		for _, polygon := range pixelPolygons {
+			rasterizePolygon(polygon, width, height, cfg.allTouched).each(mask.set)
+		}
+		mask.each(func(col, row int) {
+			k := row*width + col
+			old := g.Data.Data[k]
+			switch {
+			case cfg.merge == MergeReplace || g.IsNodata(old):
+				g.Data.Data[k] = value
+			case cfg.merge == MergeAdd:
+				g.Data.Data[k] = old + value
+			case cfg.merge == MergeMax:
+				g.Data.Data[k] = math.Max(old, value)
+			}
+		})
+	}
+	return nil
+}
+
+// Rasterize burn the features onto a new raster on the grid of the template, the raster is filled with 0 at first
+func Rasterize(fc *GeoJSONFeatureCollection, template *GeoTif, bitsPerSample, sampleFormat uint, opts ...RasterizeOptions) (*GeoTif, error) {
+	g := NewGeoTifLike(template, bitsPerSample, sampleFormat)
+	if err := g.Burn(fc, opts...); err != nil {
+		return nil, gEC(WithFunction("Rasterize"), WithError(err))
+	}
+	return g, nil
+}
@@ -0,0 +1,122 @@
+package GeoTiff
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync/atomic"
+)
+
+// readerID number the tifs which are not opened from a path, it is their name in the keys of the block cache
+var readerID int64
+
+func newReaderCacheKey(name string) string {
+	return fmt.Sprintf("reader#%d:%s", atomic.AddInt64(&readerID, 1), name)
+}
+
+// bytesFile is the io.ReaderAt on the bytes in memory, Slice returns them without copy
+type bytesFile struct {
+	data []byte
+}
+
+func (bf *bytesFile) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 || off >= int64(len(bf.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, bf.data[off:])
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// Slice returns the bytes, they should not be modified
+func (bf *bytesFile) Slice(off, n int) ([]byte, error) {
+	if off < 0 || n < 0 || off+n > len(bf.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return bf.data[off : off+n : off+n], nil
+}
+
+// OpenReader read the tif of size bytes from r, e.g. an upload or a section of an archive
+// r should be readable as long as the GeoTif is used by Sample, Blocks or ProcessBlocks
+func OpenReader(r io.ReaderAt, size int64, opts ...OpenOptions) (*GeoTif, error) {
+	return OpenReaderContext(context.Background(), r, size, opts...)
+}
+
+// OpenReaderContext is OpenReader which stops reading the pixels when ctx is done
+func OpenReaderContext(ctx context.Context, r io.ReaderAt, size int64, opts ...OpenOptions) (*GeoTif, error) {
+	var gEC = NewGeoErrorCreator("OpenReader")
+	g, err := openReader(ctx, "", r, size, opts)
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	return g, nil
+}
+
+// OpenBytes read the tif in memory, the bytes are used without copy and should not be modified
+func OpenBytes(data []byte, opts ...OpenOptions) (*GeoTif, error) {
+	return OpenBytesContext(context.Background(), data, opts...)
+}
+
+// OpenBytesContext is OpenBytes which stops reading the pixels when ctx is done
+func OpenBytesContext(ctx context.Context, data []byte, opts ...OpenOptions) (*GeoTif, error) {
+	var gEC = NewGeoErrorCreator("OpenBytes")
+	g, err := openReader(ctx, "", &bytesFile{data: data}, int64(len(data)), opts)
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	return g, nil
+}
+
+// OpenFS read the tif name of fsys, e.g. an embed.FS or os.DirFS,
+// the file is read through io.ReaderAt when it supports it, otherwise it is buffered as it is read
+func OpenFS(fsys fs.FS, name string, opts ...OpenOptions) (*GeoTif, error) {
+	return OpenFSContext(context.Background(), fsys, name, opts...)
+}
+
+// OpenFSContext is OpenFS which stops reading the pixels when ctx is done
+func OpenFSContext(ctx context.Context, fsys fs.FS, name string, opts ...OpenOptions) (*GeoTif, error) {
+	var gEC = NewGeoErrorCreator("OpenFS")
+	if err := ctx.Err(); err != nil {
+		return nil, gEC(WithError(err))
+	}
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, gEC(WithError(err))
+	}
+	if info.IsDir() {
+		f.Close()
+		return nil, gEC(WithErrorText(fmt.Sprintf("%s is a directory", name)))
+	}
+	g, err := openReader(ctx, name, newReaderAt(f), info.Size(), opts)
+	if err != nil {
+		f.Close()
+		return nil, gEC(WithError(err))
+	}
+	return g, nil
+}
+
+func openReader(ctx context.Context, name string, r io.ReaderAt, size int64, opts []OpenOptions) (*GeoTif, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	cfg := newOpenConfig(opts)
+	geoTif := GeoTif{
+		FilePath:     name,
+		GeoTifHeader: geoTifHeader{},
+	}
+	if err := geoTif.openReader(r, size, newReaderCacheKey(name), cfg); err != nil {
+		return nil, err
+	}
+	if err := geoTif.readData(ctx); err != nil {
+		return nil, err
+	}
+	return &geoTif, nil
+}
@@ -0,0 +1,322 @@
+package GeoTiff
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"sort"
+)
+
+// ColorStop is a color at Position (0~1) of a ColorRamp
+type ColorStop struct {
+	Position float64
+	Color    color.NRGBA
+}
+
+// ColorRamp is interpolated linearly between the stops, the stops are sorted by Position
+type ColorRamp []ColorStop
+
+func hexColor(v uint32) color.NRGBA {
+	return color.NRGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}
+}
+
+// ColorRamps are the named ramps, ndvi is made for the range [-1, 1]
+var ColorRamps = map[string]ColorRamp{
+	"gray": {
+		{0, hexColor(0x000000)}, {1, hexColor(0xffffff)},
+	},
+	"viridis": {
+		{0, hexColor(0x440154)}, {0.111, hexColor(0x482878)}, {0.222, hexColor(0x3e4989)},
+		{0.333, hexColor(0x31688e)}, {0.444, hexColor(0x26828e)}, {0.556, hexColor(0x1f9e89)},
+		{0.667, hexColor(0x35b779)}, {0.778, hexColor(0x6ece58)}, {0.889, hexColor(0xb5de2b)},
+		{1, hexColor(0xfde725)},
+	},
+	"terrain": {
+		{0, hexColor(0x333399)}, {0.15, hexColor(0x0099ff)}, {0.25, hexColor(0x00cc66)},
+		{0.5, hexColor(0xffff99)}, {0.75, hexColor(0x805c54)}, {1, hexColor(0xffffff)},
+	},
+	"ndvi": {
+		{0, hexColor(0x0000ff)}, {0.45, hexColor(0xd2b48c)}, {0.55, hexColor(0xffff99)},
+		{0.7, hexColor(0x66bd63)}, {1, hexColor(0x006837)},
+	},
+}
+
+// At return the color of t (0~1)
+func (cr ColorRamp) At(t float64) color.NRGBA {
+	if len(cr) == 0 {
+		return color.NRGBA{}
+	}
+	if t <= cr[0].Position {
+		return cr[0].Color
+	}
+	for i := 1; i < len(cr); i++ {
+		if t <= cr[i].Position {
+			a, b := cr[i-1], cr[i]
+			f := (t - a.Position) / (b.Position - a.Position)
+			mix := func(x, y uint8) uint8 {
+				return uint8(math.Round(float64(x) + f*(float64(y)-float64(x))))
+			}
+			return color.NRGBA{R: mix(a.Color.R, b.Color.R), G: mix(a.Color.G, b.Color.G), B: mix(a.Color.B, b.Color.B), A: mix(a.Color.A, b.Color.A)}
+		}
+	}
+	return cr[len(cr)-1].Color
+}
+
+// Stretch is how the values are mapped to 0~1 before the color ramp
+type Stretch int
+
+const (
+	// StretchLinear map [min, max] (WithRange, or the range of the data) linearly
+	StretchLinear Stretch = iota
+	// StretchPercentile map the percentiles set by WithPercentile linearly, default is 2% ~ 98%
+	StretchPercentile
+	// StretchHistogramEqualize map the value to its rank in the valid data
+	StretchHistogramEqualize
+)
+
+type renderConfig struct {
+	stretch           Stretch
+	hasRange          bool
+	min, max          float64
+	lowPercent        float64
+	highPercent       float64
+	ramp              ColorRamp
+	classes           map[float64]color.NRGBA
+	hillshade         *GeoTif
+	hillshadeStrength float64
+}
+
+type RenderOptions func(rc *renderConfig)
+
+func WithStretch(stretch Stretch) RenderOptions {
+	return func(rc *renderConfig) {
+		rc.stretch = stretch
+	}
+}
+
+// WithRange set the range of StretchLinear
+func WithRange(min, max float64) RenderOptions {
+	return func(rc *renderConfig) {
+		rc.hasRange = true
+		rc.min = min
+		rc.max = max
+	}
+}
+
+// WithPercentile set the percent (0~100) of StretchPercentile
+func WithPercentile(low, high float64) RenderOptions {
+	return func(rc *renderConfig) {
+		rc.lowPercent = low
+		rc.highPercent = high
+	}
+}
+
+// WithColorRamp default is ColorRamps["gray"]
+func WithColorRamp(ramp ColorRamp) RenderOptions {
+	return func(rc *renderConfig) {
+		rc.ramp = ramp
+	}
+}
+
+// WithClassColors color every class value with its color, the values which are not in the table are transparent
+// the stretch and the ramp are not used
+func WithClassColors(classes map[float64]color.NRGBA) RenderOptions {
+	return func(rc *renderConfig) {
+		rc.classes = classes
+	}
+}
+
+// WithHillshadeBlend darken the colors by the hillshade (0~255, see GeoTif.Hillshade) on the same grid
+// strength is 0~1, 0 does nothing
+func WithHillshadeBlend(hillshade *GeoTif, strength float64) RenderOptions {
+	return func(rc *renderConfig) {
+		rc.hillshade = hillshade
+		rc.hillshadeStrength = strength
+	}
+}
+
+// eachValue call fn with every pixel, the tif opened by OpenGeoTifHeader is read block by block
+// and its pixels are not kept
+func (g *GeoTif) eachValue(fn func(v float64)) error {
+	if g.tFile == nil || len(g.Data.Data) == int(g.Meta.Columns)*int(g.Meta.Rows) {
+		for _, v := range g.Data.Data {
+			fn(v)
+		}
+		return nil
+	}
+	it := g.Blocks()
+	for it.Next() {
+		for _, v := range it.Block().Data {
+			fn(v)
+		}
+	}
+	return it.Err()
+}
+
+// stretcher return the function mapping a value to 0~1
+func (g *GeoTif) stretcher(cfg renderConfig) (func(v float64) float64, error) {
+	var valid []float64
+	if cfg.stretch != StretchLinear || !cfg.hasRange {
+		err := g.eachValue(func(v float64) {
+			if !g.IsNodata(v) {
+				valid = append(valid, v)
+			}
+		})
+		if err != nil {
+			return nil, gEC(WithFunction("GeoTif.stretcher"), WithError(err))
+		}
+	}
+	linear := func(min, max float64) func(v float64) float64 {
+		return func(v float64) float64 {
+			if max == min {
+				return 0.5
+			}
+			return math.Max(0, math.Min(1, (v-min)/(max-min)))
+		}
+	}
+	switch cfg.stretch {
+	case StretchLinear:
+		if cfg.hasRange {
+			return linear(cfg.min, cfg.max), nil
+		}
+		min, max := math.Inf(1), math.Inf(-1)
+		for _, v := range valid {
+			min, max = math.Min(min, v), math.Max(max, v)
+		}
+		return linear(min, max), nil
+	case StretchPercentile:
+		if len(valid) == 0 {
+			return linear(0, 0), nil
+		}
+		sort.Float64s(valid)
+		at := func(percent float64) float64 {
+			i := int(math.Round(percent / 100 * float64(len(valid)-1)))
+			return valid[maxInt(0, minInt(i, len(valid)-1))]
+		}
+		return linear(at(cfg.lowPercent), at(cfg.highPercent)), nil
+	case StretchHistogramEqualize:
+		if len(valid) == 0 {
+			return linear(0, 0), nil
+		}
+		sort.Float64s(valid)
+		n := float64(len(valid))
+		return func(v float64) float64 {
+			// the fraction of the values <= v
+			return float64(sort.Search(len(valid), func(i int) bool { return valid[i] > v })) / n
+		}, nil
+	}
+	return nil, gEC(WithFunction("GeoTif.stretcher"), WithErrorText(fmt.Sprintf("unknown stretch %d", cfg.stretch)))
+}
+
+// colorizer return the color of the value v of the pixel i with the style, false when it is transparent
+// RGB(A) and paletted tifs are drawn with their own colors, the pixels of g are not read except for the stretch
+func (g *GeoTif) colorizer(opts []RenderOptions) (func(v float64, i int) (color.NRGBA, bool), error) {
+	var gEC = NewGeoErrorCreator("GeoTif.colorizer")
+	cfg := renderConfig{
+		stretch:     StretchLinear,
+		lowPercent:  2,
+		highPercent: 98,
+		ramp:        ColorRamps["gray"],
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	switch g.Meta.mode {
+	case mRGB, mRGBA, mNRGBA, mPaletted:
+		return func(v float64, i int) (color.NRGBA, bool) {
+			c := unpackARGB(v)
+			return c, c.A != 0
+		}, nil
+	}
+	if cfg.hillshade != nil {
+		if err := g.CheckSameGrid(cfg.hillshade); err != nil {
+			return nil, gEC(WithError(err), WithMsg("hillshade"))
+		}
+		if err := cfg.hillshade.pixels(); err != nil {
+			return nil, gEC(WithError(err), WithMsg("hillshade"))
+		}
+	}
+	var toUnit func(v float64) float64
+	if cfg.classes == nil {
+		var err error
+		if toUnit, err = g.stretcher(cfg); err != nil {
+			return nil, gEC(WithError(err))
+		}
+	}
+	return func(v float64, i int) (color.NRGBA, bool) {
+		if g.IsNodata(v) {
+			return color.NRGBA{}, false
+		}
+		var c color.NRGBA
+		if cfg.classes != nil {
+			var ok bool
+			if c, ok = cfg.classes[v]; !ok {
+				return color.NRGBA{}, false
+			}
+		} else {
+			c = cfg.ramp.At(toUnit(v))
+		}
+		if cfg.hillshade != nil && cfg.hillshadeStrength > 0 {
+			if hs := cfg.hillshade.Data.Data[i]; !cfg.hillshade.IsNodata(hs) {
+				f := 1 - cfg.hillshadeStrength + cfg.hillshadeStrength*math.Max(0, math.Min(1, hs/255))
+				c.R = uint8(float64(c.R) * f)
+				c.G = uint8(float64(c.G) * f)
+				c.B = uint8(float64(c.B) * f)
+			}
+		}
+		return c, true
+	}, nil
+}
+
+// Render draw the band with the style, nodata is transparent
+func (g *GeoTif) Render(opts ...RenderOptions) (*image.NRGBA, error) {
+	if err := g.pixels(); err != nil {
+		return nil, gEC(WithFunction("GeoTif.Render"), WithError(err))
+	}
+	colorOf, err := g.colorizer(opts)
+	if err != nil {
+		return nil, gEC(WithFunction("GeoTif.Render"), WithError(err))
+	}
+	width := int(g.Meta.Columns)
+	height := int(g.Meta.Rows)
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i, v := range g.Data.Data {
+		if c, ok := colorOf(v, i); ok {
+			img.SetNRGBA(i%width, i/width, c)
+		}
+	}
+	return img, nil
+}
+
+// RenderPNG render the band and encode it as png
+func (g *GeoTif) RenderPNG(w io.Writer, opts ...RenderOptions) error {
+	img, err := g.Render(opts...)
+	if err != nil {
+		return gEC(WithFunction("GeoTif.RenderPNG"), WithError(err))
+	}
+	if err = png.Encode(w, img); err != nil {
+		return gEC(WithFunction("GeoTif.RenderPNG"), WithError(err))
+	}
+	return nil
+}
+
+// SavePNG render the band to the png file
+func (g *GeoTif) SavePNG(FilePath string, opts ...RenderOptions) error {
+	var gEC = NewGeoErrorCreator("GeoTif.SavePNG")
+	f, err := os.Create(FilePath)
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	if err = g.RenderPNG(f, opts...); err != nil {
+		f.Close()
+		return gEC(WithError(err))
+	}
+	if err = f.Close(); err != nil {
+		return gEC(WithError(err))
+	}
+	return nil
+}
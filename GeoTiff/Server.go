@@ -0,0 +1,379 @@
+package GeoTiff
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// tileCache is a LRU of the encoded tiles
+type tileCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[string]*list.Element
+}
+
+type tileCacheItem struct {
+	key  string
+	data []byte
+}
+
+func newTileCache(capacity int) *tileCache {
+	return &tileCache{capacity: capacity, order: list.New(), items: map[string]*list.Element{}}
+}
+
+func (tc *tileCache) get(key string) ([]byte, bool) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	e, ok := tc.items[key]
+	if !ok {
+		return nil, false
+	}
+	tc.order.MoveToFront(e)
+	return e.Value.(*tileCacheItem).data, true
+}
+
+// drop remove the tiles whose keys start with prefix
+func (tc *tileCache) drop(prefix string) {
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	for key, e := range tc.items {
+		if strings.HasPrefix(key, prefix) {
+			tc.order.Remove(e)
+			delete(tc.items, key)
+		}
+	}
+}
+
+func (tc *tileCache) put(key string, data []byte) {
+	if tc.capacity <= 0 {
+		return
+	}
+	tc.mu.Lock()
+	defer tc.mu.Unlock()
+	if e, ok := tc.items[key]; ok {
+		e.Value.(*tileCacheItem).data = data
+		tc.order.MoveToFront(e)
+		return
+	}
+	tc.items[key] = tc.order.PushFront(&tileCacheItem{key: key, data: data})
+	for tc.order.Len() > tc.capacity {
+		e := tc.order.Back()
+		tc.order.Remove(e)
+		delete(tc.items, e.Value.(*tileCacheItem).key)
+	}
+}
+
+type serverConfig struct {
+	cacheSize  int
+	blockCache *BlockCache
+}
+
+type ServerOptions func(sc *serverConfig)
+
+// WithTileCacheSize set how many tiles are kept in memory, default is 1024, 0 disables the cache
+func WithTileCacheSize(size int) ServerOptions {
+	return func(sc *serverConfig) {
+		sc.cacheSize = size
+	}
+}
+
+// WithServerBlockCache is the block cache of the files added by AddFile, default is a cache of 64MB
+func WithServerBlockCache(cache *BlockCache) ServerOptions {
+	return func(sc *serverConfig) {
+		sc.blockCache = cache
+	}
+}
+
+// serverLayer is counted by the requests which use it, the layers map holds one more reference,
+// the tif is closed when the last reference is released
+type serverLayer struct {
+	g     *GeoTif
+	tiler *Tiler
+	// gen is in the keys of the tiles, the tiles of a replaced layer are never served for the new one
+	gen  uint64
+	refs int32
+}
+
+// release drop a reference, the last one closes the tif
+func (l *serverLayer) release() error {
+	if atomic.AddInt32(&l.refs, -1) == 0 {
+		return l.g.Close()
+	}
+	return nil
+}
+
+// TileServer is the http.Handler which render the layers on demand
+//
+//	GET /                          the names of the layers
+//	GET /{layer}/{z}/{x}/{y}.png   the XYZ tile, transparent when it is out of the raster
+//	GET /{layer}/point?lon=&lat=   the value of the pixel at the location
+//	GET /{layer}/metadata.json     the size, CRS, bounds and metadata of the layer
+//
+// it can be used as the url "http://host/{layer}/{z}/{x}/{y}.png" of a Leaflet tile layer
+type TileServer struct {
+	mu     sync.RWMutex
+	layers map[string]*serverLayer
+	gen    uint64
+	cache  *tileCache
+	blocks *BlockCache
+}
+
+func NewTileServer(opts ...ServerOptions) *TileServer {
+	cfg := serverConfig{
+		cacheSize: 1024,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.blockCache == nil {
+		cfg.blockCache = NewBlockCache(64 << 20)
+	}
+	return &TileServer{layers: map[string]*serverLayer{}, cache: newTileCache(cfg.cacheSize), blocks: cfg.blockCache}
+}
+
+// AddLayer serve the tif as the layer, the tiles are styled by opts (see NewTiler)
+// the tiles of any zoom are served, WithZoomRange only changes the zoom range in metadata.json,
+// the layer with the same name is replaced, its tif is closed after the requests which are using it finish
+func (s *TileServer) AddLayer(name string, g *GeoTif, opts ...TileOptions) error {
+	var gEC = NewGeoErrorCreator("TileServer.AddLayer")
+	if name == "" || strings.Contains(name, "/") {
+		return gEC(WithErrorText(fmt.Sprintf("layer name %q is invalid", name)))
+	}
+	tiler, err := NewTiler(g, opts...)
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	s.mu.Lock()
+	old, replaced := s.layers[name]
+	s.gen++
+	s.layers[name] = &serverLayer{g: g, tiler: tiler, gen: s.gen, refs: 1}
+	// the tiles of the old layer with the same name are dropped
+	s.cache.drop(name + "/")
+	s.mu.Unlock()
+	// the same tif is kept by the new layer, the old one does not close it
+	if replaced && old.g != g {
+		if err = old.release(); err != nil {
+			return gEC(WithError(err), WithMsg(fmt.Sprintf("close the old layer %q", name)))
+		}
+	}
+	return nil
+}
+
+// AddFile serve the tif as the layer, only the header is read, the tiles decode the blocks under them
+// through the block cache of the server
+func (s *TileServer) AddFile(name, FilePath string, opts ...TileOptions) error {
+	var gEC = NewGeoErrorCreator("TileServer.AddFile")
+	g, err := OpenGeoTifHeader(FilePath, WithBlockCache(s.blocks))
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	if err = s.AddLayer(name, g, opts...); err != nil {
+		g.Close()
+		return gEC(WithError(err))
+	}
+	return nil
+}
+
+// Close remove all layers, their tifs are closed after the requests which are using them finish
+func (s *TileServer) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var err error
+	for name, l := range s.layers {
+		if e := l.release(); e != nil && err == nil {
+			err = gEC(WithFunction("TileServer.Close"), WithError(e), WithMsg(fmt.Sprintf("layer %q", name)))
+		}
+		delete(s.layers, name)
+		s.cache.drop(name + "/")
+	}
+	return err
+}
+
+// acquire return the layer with a reference, it must be released after the request
+func (s *TileServer) acquire(name string) (*serverLayer, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	l, ok := s.layers[name]
+	if ok {
+		atomic.AddInt32(&l.refs, 1)
+	}
+	return l, ok
+}
+
+// putTile cache the tile only when the layer is not replaced, a late tile of the old layer is ignored
+func (s *TileServer) putTile(name string, l *serverLayer, key string, data []byte) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if cur, ok := s.layers[name]; ok && cur.gen == l.gen {
+		s.cache.put(key, data)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (s *TileServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) == 1 && parts[0] == "" {
+		s.mu.RLock()
+		names := make([]string, 0, len(s.layers))
+		for name := range s.layers {
+			names = append(names, name)
+		}
+		s.mu.RUnlock()
+		sort.Strings(names)
+		writeJSON(w, names)
+		return
+	}
+	l, ok := s.acquire(parts[0])
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	defer l.release()
+	switch {
+	case len(parts) == 4 && strings.HasSuffix(parts[3], ".png"):
+		s.serveTile(w, r, parts[0], l, parts[1], parts[2], strings.TrimSuffix(parts[3], ".png"))
+	case len(parts) == 2 && parts[1] == "point":
+		s.servePoint(w, r, l)
+	case len(parts) == 2 && parts[1] == "metadata.json":
+		s.serveMetadata(w, parts[0], l)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *TileServer) serveTile(w http.ResponseWriter, r *http.Request, name string, l *serverLayer, zs, xs, ys string) {
+	z, errZ := strconv.Atoi(zs)
+	x, errX := strconv.Atoi(xs)
+	y, errY := strconv.Atoi(ys)
+	if errZ != nil || errX != nil || errY != nil || z < 0 || z > 30 || x < 0 || y < 0 || x >= 1<<uint(z) || y >= 1<<uint(z) {
+		http.NotFound(w, r)
+		return
+	}
+	key := fmt.Sprintf("%s/%d/%d/%d/%d", name, l.gen, z, x, y)
+	data, ok := s.cache.get(key)
+	if !ok {
+		var err error
+		if data, err = l.tiler.TilePNG(z, x, y); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if data == nil {
+			data = emptyTile(l.tiler.cfg.size)
+		}
+		s.putTile(name, l, key, data)
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+	_, _ = w.Write(data)
+}
+
+// emptyTile is the transparent tile
+func emptyTile(size int) []byte {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, size, size)))
+	return buf.Bytes()
+}
+
+// PointValue is the response of /{layer}/point
+type PointValue struct {
+	Lon    float64  `json:"lon"`
+	Lat    float64  `json:"lat"`
+	Col    int      `json:"col"`
+	Row    int      `json:"row"`
+	Value  *float64 `json:"value"`
+	Nodata bool     `json:"nodata"`
+}
+
+func (s *TileServer) servePoint(w http.ResponseWriter, r *http.Request, l *serverLayer) {
+	lon, errLon := strconv.ParseFloat(r.URL.Query().Get("lon"), 64)
+	lat, errLat := strconv.ParseFloat(r.URL.Query().Get("lat"), 64)
+	if errLon != nil || errLat != nil {
+		http.Error(w, "lon and lat are required", http.StatusBadRequest)
+		return
+	}
+	col, row := l.g.Transform.GeoToPixel(l.tiler.proj.fromLonLat(lon, lat))
+	p := PointValue{Lon: lon, Lat: lat, Col: int(math.Floor(col)), Row: int(math.Floor(row))}
+	v, err := l.g.Sample(p.Col, p.Row)
+	if errors.Is(err, ErrOutOfRange) {
+		http.Error(w, "the point is out of the raster", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if l.g.IsNodata(v) {
+		p.Nodata = true
+	} else {
+		p.Value = &v
+	}
+	writeJSON(w, p)
+}
+
+// LayerMetadata is the response of /{layer}/metadata.json
+type LayerMetadata struct {
+	Name          string            `json:"name"`
+	Columns       uint              `json:"columns"`
+	Rows          uint              `json:"rows"`
+	BitsPerSample uint              `json:"bitsPerSample"`
+	SampleFormat  uint              `json:"sampleFormat"`
+	EPSG          uint              `json:"epsg"`
+	Nodata        *float64          `json:"nodata"`
+	Transform     [6]float64        `json:"transform"`
+	Bounds        [4]float64        `json:"bounds"`
+	LonLatBounds  [4]float64        `json:"lonLatBounds"`
+	MinZoom       int               `json:"minZoom"`
+	MaxZoom       int               `json:"maxZoom"`
+	TileSize      int               `json:"tileSize"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+}
+
+func (s *TileServer) serveMetadata(w http.ResponseWriter, name string, l *serverLayer) {
+	b := l.g.Bounds()
+	mb := l.tiler.Bounds()
+	minLon, minLat := mercatorToLonLat(mb.MinX, mb.MinY)
+	maxLon, maxLat := mercatorToLonLat(mb.MaxX, mb.MaxY)
+	m := LayerMetadata{
+		Name:         name,
+		Columns:      l.g.Meta.Columns,
+		Rows:         l.g.Meta.Rows,
+		SampleFormat: l.g.Meta.SampleFormat,
+		EPSG:         l.g.Meta.EPSGCode,
+		Transform:    l.g.Transform.Data,
+		Bounds:       [4]float64{b.MinX, b.MinY, b.MaxX, b.MaxY},
+		LonLatBounds: [4]float64{minLon, minLat, maxLon, maxLat},
+		TileSize:     l.tiler.cfg.size,
+		Metadata:     l.g.Metadata.Items,
+	}
+	if len(l.g.Meta.BitsPerSample) > 0 {
+		m.BitsPerSample = l.g.Meta.BitsPerSample[0]
+	}
+	if nodata, ok := l.g.Meta.Nodata(); ok {
+		m.Nodata = &nodata
+	}
+	m.MinZoom, m.MaxZoom = l.tiler.ZoomRange()
+	writeJSON(w, m)
+}
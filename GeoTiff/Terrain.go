@@ -0,0 +1,209 @@
+package GeoTiff
+
+import (
+	"math"
+	"strconv"
+)
+
+// TerrainAlgorithm is how the gradient is calculated from the 3x3 window
+//
+//	a b c
+//	d e f
+//	g h i
+type TerrainAlgorithm int
+
+const (
+	// Horn (1981), dz/dx = ((c + 2f + i) - (a + 2d + g)) / 8dx
+	Horn TerrainAlgorithm = iota
+	// ZevenbergenThorne (1987), dz/dx = (f - d) / 2dx, smoother for smooth landscapes
+	ZevenbergenThorne
+)
+
+// metersPerDegree is the length of one degree on the WGS84 equator
+const metersPerDegree = math.Pi * 6378137 / 180
+
+const terrainNodata = -9999
+
+type terrainConfig struct {
+	algorithm    TerrainAlgorithm
+	zFactor      float64
+	slopePercent bool
+	azimuth      float64
+	altitude     float64
+}
+
+type TerrainOptions func(tc *terrainConfig)
+
+func WithTerrainAlgorithm(algorithm TerrainAlgorithm) TerrainOptions {
+	return func(tc *terrainConfig) {
+		tc.algorithm = algorithm
+	}
+}
+
+// WithZFactor scale the elevation, e.g. 0.3048 for elevations in feet, default is 1
+func WithZFactor(zFactor float64) TerrainOptions {
+	return func(tc *terrainConfig) {
+		tc.zFactor = zFactor
+	}
+}
+
+// WithSlopePercent return the slope in percent instead of degrees
+func WithSlopePercent(percent bool) TerrainOptions {
+	return func(tc *terrainConfig) {
+		tc.slopePercent = percent
+	}
+}
+
+// WithSun set the azimuth (clockwise from north) and altitude of the light in degrees, default is 315 and 45
+func WithSun(azimuth, altitude float64) TerrainOptions {
+	return func(tc *terrainConfig) {
+		tc.azimuth = azimuth
+		tc.altitude = altitude
+	}
+}
+
+// IsGeographic report whether the CRS is longitude/latitude
+func (g *GeoTif) IsGeographic() bool {
+	if atr, err := g.GeoKeys.getAttributeByTag(GTModelTypeGeoKey); err == nil && len(atr.GeoAttributeValue.uint) > 0 {
+		return atr.GeoAttributeValue.uint[0] == 2
+	}
+	return g.Meta.EPSGCode >= 4000 && g.Meta.EPSGCode < 5000
+}
+
+// terrain call fn with the 3x3 window of every pixel and the pixel size in meters,
+// the result is a float32 raster, the pixels on the border or next to nodata are nodata,
+// name is the function in the error
+func (g *GeoTif) terrain(name string, opts []TerrainOptions, fn func(w *[9]float64, dx, dy float64, cfg terrainConfig) float64) (*GeoTif, error) {
+	cfg := terrainConfig{
+		algorithm: Horn,
+		zFactor:   1,
+		azimuth:   315,
+		altitude:  45,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := g.pixels(); err != nil {
+		return nil, gEC(WithFunction(name), WithError(err))
+	}
+	out := NewGeoTifLike(g, 32, SampleFormatFloat)
+	out.Meta.NodataValue = strconv.Itoa(terrainNodata)
+	width := int(g.Meta.Columns)
+	height := int(g.Meta.Rows)
+	geographic := g.IsGeographic()
+	// north up: dy > 0
+	dx := g.Transform.Data[1]
+	dy := -g.Transform.Data[5]
+	var w [9]float64
+	for row := 0; row < height; row++ {
+		rowDx, rowDy := dx, dy
+		if geographic {
+			_, lat := g.Transform.PixelToGeo(float64(width)/2, float64(row)+0.5)
+			rowDx = dx * metersPerDegree * math.Cos(lat*math.Pi/180)
+			rowDy = dy * metersPerDegree
+		}
+	pixels:
+		for col := 0; col < width; col++ {
+			i := row*width + col
+			out.Data.Data[i] = terrainNodata
+			if row == 0 || col == 0 || row == height-1 || col == width-1 {
+				continue
+			}
+			for k := 0; k < 9; k++ {
+				v := g.Data.Data[(row+k/3-1)*width+col+k%3-1]
+				if g.IsNodata(v) {
+					continue pixels
+				}
+				w[k] = v * cfg.zFactor
+			}
+			if r := fn(&w, rowDx, rowDy, cfg); !math.IsNaN(r) {
+				out.Data.Data[i] = r
+			}
+		}
+	}
+	return out, nil
+}
+
+// gradient return dz/dx (to the east) and dz/dy (to the north)
+func gradient(w *[9]float64, dx, dy float64, algorithm TerrainAlgorithm) (float64, float64) {
+	if algorithm == ZevenbergenThorne {
+		return (w[5] - w[3]) / (2 * dx), (w[1] - w[7]) / (2 * dy)
+	}
+	p := ((w[2] + 2*w[5] + w[8]) - (w[0] + 2*w[3] + w[6])) / (8 * dx)
+	q := ((w[0] + 2*w[1] + w[2]) - (w[6] + 2*w[7] + w[8])) / (8 * dy)
+	return p, q
+}
+
+func slopeOf(p, q float64) float64 {
+	return math.Atan(math.Hypot(p, q))
+}
+
+// aspectOf is the downslope direction, clockwise from north in degrees, -1 when it is flat
+func aspectOf(p, q float64) float64 {
+	if p == 0 && q == 0 {
+		return -1
+	}
+	a := math.Atan2(-p, -q) * 180 / math.Pi
+	if a < 0 {
+		a += 360
+	}
+	return a
+}
+
+// Slope is in degrees, or percent with WithSlopePercent
+func (g *GeoTif) Slope(opts ...TerrainOptions) (*GeoTif, error) {
+	return g.terrain("GeoTif.Slope", opts, func(w *[9]float64, dx, dy float64, cfg terrainConfig) float64 {
+		p, q := gradient(w, dx, dy, cfg.algorithm)
+		if cfg.slopePercent {
+			return math.Hypot(p, q) * 100
+		}
+		return slopeOf(p, q) * 180 / math.Pi
+	})
+}
+
+// Aspect is the downslope direction, clockwise from north in degrees, the flat pixel is -1
+func (g *GeoTif) Aspect(opts ...TerrainOptions) (*GeoTif, error) {
+	return g.terrain("GeoTif.Aspect", opts, func(w *[9]float64, dx, dy float64, cfg terrainConfig) float64 {
+		return aspectOf(gradient(w, dx, dy, cfg.algorithm))
+	})
+}
+
+// Hillshade is the illumination in 0~255 by the sun set with WithSun
+func (g *GeoTif) Hillshade(opts ...TerrainOptions) (*GeoTif, error) {
+	return g.terrain("GeoTif.Hillshade", opts, func(w *[9]float64, dx, dy float64, cfg terrainConfig) float64 {
+		p, q := gradient(w, dx, dy, cfg.algorithm)
+		slope := slopeOf(p, q)
+		zenith := (90 - cfg.altitude) * math.Pi / 180
+		shade := math.Cos(zenith) * math.Cos(slope)
+		if p != 0 || q != 0 {
+			aspect := aspectOf(p, q) * math.Pi / 180
+			shade += math.Sin(zenith) * math.Sin(slope) * math.Cos(cfg.azimuth*math.Pi/180-aspect)
+		}
+		return math.Max(0, shade) * 255
+	})
+}
+
+// Roughness is the largest difference in the 3x3 window
+func (g *GeoTif) Roughness(opts ...TerrainOptions) (*GeoTif, error) {
+	return g.terrain("GeoTif.Roughness", opts, func(w *[9]float64, dx, dy float64, cfg terrainConfig) float64 {
+		min, max := w[0], w[0]
+		for _, v := range w {
+			min = math.Min(min, v)
+			max = math.Max(max, v)
+		}
+		return max - min
+	})
+}
+
+// TPI (topographic position index) is the difference between the pixel and the mean of its 8 neighbors
+func (g *GeoTif) TPI(opts ...TerrainOptions) (*GeoTif, error) {
+	return g.terrain("GeoTif.TPI", opts, func(w *[9]float64, dx, dy float64, cfg terrainConfig) float64 {
+		sum := 0.0
+		for k, v := range w {
+			if k != 4 {
+				sum += v
+			}
+		}
+		return w[4] - sum/8
+	})
+}
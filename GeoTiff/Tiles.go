@@ -0,0 +1,354 @@
+package GeoTiff
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/SunIBAS/gotool/compress"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+type tileConfig struct {
+	size    int
+	minZoom int
+	maxZoom int
+	tms     bool
+	workers int
+	render  []RenderOptions
+}
+
+type TileOptions func(tc *tileConfig)
+
+// WithTileSize is 256 or 512, default is 256
+func WithTileSize(size int) TileOptions {
+	return func(tc *tileConfig) {
+		tc.size = size
+	}
+}
+
+// WithZoomRange default is from the zoom where the raster fits in one tile to the zoom of its resolution
+func WithZoomRange(min, max int) TileOptions {
+	return func(tc *tileConfig) {
+		tc.minZoom = min
+		tc.maxZoom = max
+	}
+}
+
+// WithTMS write the tiles with the TMS y (from the south) instead of the XYZ y (from the north)
+func WithTMS(tms bool) TileOptions {
+	return func(tc *tileConfig) {
+		tc.tms = tms
+	}
+}
+
+// WithTileWorkers set how many tiles are rendered at the same time, default is runtime.NumCPU()
+func WithTileWorkers(workers int) TileOptions {
+	return func(tc *tileConfig) {
+		tc.workers = workers
+	}
+}
+
+// WithTileRender is the style of the tiles, the stretch is calculated on the whole raster
+func WithTileRender(opts ...RenderOptions) TileOptions {
+	return func(tc *tileConfig) {
+		tc.render = append(tc.render, opts...)
+	}
+}
+
+// Tiler cut a tif into the web mercator (EPSG:3857) tiles, the pixels are sampled by the nearest neighbor
+// and nodata is transparent
+type Tiler struct {
+	g       *GeoTif
+	cfg     tileConfig
+	proj    projection
+	colorOf func(v float64, i int) (color.NRGBA, bool)
+	bounds  Bounds
+}
+
+// NewTiler prepare the style and the extent of the tiles
+// the CRS of the tif should be longitude/latitude, web mercator or WGS84 UTM,
+// the tif opened by OpenGeoTifHeader is not read into memory, every tile decode the blocks under it
+func NewTiler(g *GeoTif, opts ...TileOptions) (*Tiler, error) {
+	var gEC = NewGeoErrorCreator("NewTiler")
+	cfg := tileConfig{
+		size:    256,
+		minZoom: -1,
+		maxZoom: -1,
+		workers: runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.size != 256 && cfg.size != 512 {
+		return nil, gEC(WithErrorText(fmt.Sprintf("tile size %d is not 256 or 512", cfg.size)))
+	}
+	if cfg.workers <= 0 {
+		cfg.workers = 1
+	}
+	proj, err := g.projection()
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	colorOf, err := g.colorizer(cfg.render)
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	t := &Tiler{g: g, cfg: cfg, proj: proj, colorOf: colorOf}
+	t.bounds = t.mercatorBounds()
+	if t.cfg.minZoom < 0 || t.cfg.maxZoom < 0 {
+		// the zoom where a tile pixel is not larger than a raster pixel
+		resolution := math.Max(t.bounds.Width()/float64(g.Meta.Columns), t.bounds.Height()/float64(g.Meta.Rows))
+		maxZoom := int(math.Ceil(math.Log2(2 * webMercatorHalf / (float64(cfg.size) * resolution))))
+		maxZoom = maxInt(0, minInt(maxZoom, 24))
+		minZoom := int(math.Floor(math.Log2(2 * webMercatorHalf / math.Max(t.bounds.Width(), t.bounds.Height()))))
+		minZoom = maxInt(0, minInt(minZoom, maxZoom))
+		if t.cfg.minZoom < 0 {
+			t.cfg.minZoom = minZoom
+		}
+		if t.cfg.maxZoom < 0 {
+			t.cfg.maxZoom = maxInt(maxZoom, t.cfg.minZoom)
+		}
+	}
+	if t.cfg.minZoom > t.cfg.maxZoom {
+		return nil, gEC(WithErrorText(fmt.Sprintf("zoom range [%d, %d] is empty", t.cfg.minZoom, t.cfg.maxZoom)))
+	}
+	return t, nil
+}
+
+// mercatorBounds project the border of the raster to web mercator
+func (t *Tiler) mercatorBounds() Bounds {
+	const steps = 20
+	b := Bounds{MinX: math.Inf(1), MinY: math.Inf(1), MaxX: math.Inf(-1), MaxY: math.Inf(-1)}
+	width := float64(t.g.Meta.Columns)
+	height := float64(t.g.Meta.Rows)
+	add := func(col, row float64) {
+		x, y := t.g.Transform.PixelToGeo(col, row)
+		mx, my := lonLatToMercator(t.proj.toLonLat(x, y))
+		b.MinX, b.MaxX = math.Min(b.MinX, mx), math.Max(b.MaxX, mx)
+		b.MinY, b.MaxY = math.Min(b.MinY, my), math.Max(b.MaxY, my)
+	}
+	for k := 0; k <= steps; k++ {
+		f := float64(k) / steps
+		add(f*width, 0)
+		add(f*width, height)
+		add(0, f*height)
+		add(width, f*height)
+	}
+	return b
+}
+
+// ZoomRange return the zooms which are written
+func (t *Tiler) ZoomRange() (int, int) {
+	return t.cfg.minZoom, t.cfg.maxZoom
+}
+
+// Bounds return the extent of the raster in web mercator
+func (t *Tiler) Bounds() Bounds {
+	return t.bounds
+}
+
+// TileBounds return the extent of the XYZ tile in web mercator
+func TileBounds(z, x, y int) Bounds {
+	size := 2 * webMercatorHalf / float64(int(1)<<uint(z))
+	return Bounds{
+		MinX: -webMercatorHalf + float64(x)*size,
+		MaxX: -webMercatorHalf + float64(x+1)*size,
+		MinY: webMercatorHalf - float64(y+1)*size,
+		MaxY: webMercatorHalf - float64(y)*size,
+	}
+}
+
+// TileRange return the XYZ tiles [x0, x1) x [y0, y1) of the zoom which intersect the raster
+func (t *Tiler) TileRange(z int) (x0, y0, x1, y1 int) {
+	n := 1 << uint(z)
+	size := 2 * webMercatorHalf / float64(n)
+	x0 = maxInt(int(math.Floor((t.bounds.MinX+webMercatorHalf)/size)), 0)
+	x1 = minInt(int(math.Ceil((t.bounds.MaxX+webMercatorHalf)/size)), n)
+	y0 = maxInt(int(math.Floor((webMercatorHalf-t.bounds.MaxY)/size)), 0)
+	y1 = minInt(int(math.Ceil((webMercatorHalf-t.bounds.MinY)/size)), n)
+	return
+}
+
+// Tile render the XYZ tile, false when there is no valid pixel in it
+func (t *Tiler) Tile(z, x, y int) (*image.NRGBA, bool, error) {
+	img, valid, err := t.tile(z, x, y)
+	if err != nil {
+		return nil, false, gEC(WithFunction("Tiler.Tile"), WithError(err))
+	}
+	return img, valid, nil
+}
+
+// tile render the XYZ tile, the pixels are read by Sample when they are not in memory
+func (t *Tiler) tile(z, x, y int) (*image.NRGBA, bool, error) {
+	b := TileBounds(z, x, y)
+	size := t.cfg.size
+	resolution := b.Width() / float64(size)
+	width := int(t.g.Meta.Columns)
+	height := int(t.g.Meta.Rows)
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	sample, err := t.g.sampler()
+	if err != nil {
+		return img, false, err
+	}
+	valid := false
+	for r := 0; r < size; r++ {
+		my := b.MaxY - (float64(r)+0.5)*resolution
+		for c := 0; c < size; c++ {
+			mx := b.MinX + (float64(c)+0.5)*resolution
+			col, row := t.g.Transform.GeoToPixel(t.proj.fromLonLat(mercatorToLonLat(mx, my)))
+			col, row = math.Floor(col), math.Floor(row)
+			if col < 0 || row < 0 || col >= float64(width) || row >= float64(height) {
+				continue
+			}
+			v, err := sample(int(col), int(row))
+			if err != nil {
+				return img, valid, err
+			}
+			if v, ok := t.colorOf(v, int(row)*width+int(col)); ok {
+				img.SetNRGBA(c, r, v)
+				valid = true
+			}
+		}
+	}
+	return img, valid, nil
+}
+
+// TilePNG render the XYZ tile as png, nil when there is no valid pixel in it
+func (t *Tiler) TilePNG(z, x, y int) ([]byte, error) {
+	img, ok, err := t.tile(z, x, y)
+	if err != nil {
+		return nil, gEC(WithFunction("Tiler.TilePNG"), WithError(err))
+	}
+	if !ok {
+		return nil, nil
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, gEC(WithFunction("Tiler.TilePNG"), WithError(err))
+	}
+	return buf.Bytes(), nil
+}
+
+type tileJob struct {
+	z, x, y int
+	data    []byte
+	err     error
+}
+
+// generate render the tiles of the zoom range with the workers, emit is called one by one with
+// the path "z/x/y.png", the empty tiles are skipped
+func (t *Tiler) generate(emit func(path string, data []byte) error) error {
+	jobs := make(chan tileJob)
+	results := make(chan tileJob)
+	stop := make(chan struct{})
+	go func() {
+		defer close(jobs)
+		for z := t.cfg.minZoom; z <= t.cfg.maxZoom; z++ {
+			x0, y0, x1, y1 := t.TileRange(z)
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					select {
+					case jobs <- tileJob{z: z, x: x, y: y}:
+					case <-stop:
+						return
+					}
+				}
+			}
+		}
+	}()
+	var wg sync.WaitGroup
+	for w := 0; w < t.cfg.workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job.data, job.err = t.TilePNG(job.z, job.x, job.y)
+				results <- job
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+	var err error
+	for job := range results {
+		if err != nil || job.data == nil && job.err == nil {
+			continue
+		}
+		if job.err == nil {
+			y := job.y
+			if t.cfg.tms {
+				y = (1 << uint(job.z)) - 1 - y
+			}
+			job.err = emit(fmt.Sprintf("%d/%d/%d.png", job.z, job.x, y), job.data)
+		}
+		if job.err != nil {
+			err = gEC(WithFunction("Tiler.generate"), WithError(job.err), WithMsg(fmt.Sprintf("tile %d/%d/%d", job.z, job.x, job.y)))
+			close(stop)
+		}
+	}
+	return err
+}
+
+// WriteDir write the tiles to dir/z/x/y.png
+func (t *Tiler) WriteDir(dir string) error {
+	err := t.generate(func(path string, data []byte) error {
+		path = filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			return err
+		}
+		return os.WriteFile(path, data, 0644)
+	})
+	if err != nil {
+		return gEC(WithFunction("Tiler.WriteDir"), WithError(err))
+	}
+	return nil
+}
+
+// WriteZip write the tiles into the zip as z/x/y.png through the compress package, every tile is compressed
+// into the zip when it is rendered, the entries of the zip which exists are kept except the tiles of the zoom range
+func (t *Tiler) WriteZip(zipFilePath string) error {
+	var gEC = NewGeoErrorCreator("Tiler.WriteZip")
+	z := compress.Zip{
+		ZipFilePath: zipFilePath,
+		Keep: func(name string) bool {
+			return !t.isTile(name)
+		},
+	}
+	if err := z.Create(); err != nil {
+		return gEC(WithError(err))
+	}
+	err := t.generate(func(path string, data []byte) error {
+		return z.Add(compress.NewZipBytesItem(path, data))
+	})
+	if e := z.Close(); e != nil && err == nil {
+		err = e
+	}
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	return nil
+}
+
+// isTile report whether the name is z/x/y.png of a tile in the zoom range and the bounds
+func (t *Tiler) isTile(name string) bool {
+	var z, x, y int
+	if n, err := fmt.Sscanf(name, "%d/%d/%d.png", &z, &x, &y); err != nil || n != 3 || fmt.Sprintf("%d/%d/%d.png", z, x, y) != name {
+		return false
+	}
+	if z < t.cfg.minZoom || z > t.cfg.maxZoom {
+		return false
+	}
+	if t.cfg.tms {
+		y = (1 << uint(z)) - 1 - y
+	}
+	x0, y0, x1, y1 := t.TileRange(z)
+	return x >= x0 && x < x1 && y >= y0 && y < y1
+}
@@ -26,7 +26,7 @@ func getAttributeAndCheck(gAttributes GeoAttributes, atrTag AttributeTag, size i
 		if len(f64) >= size {
 			return f64, nil
 		} else {
-			return nil, gEC(WithFunction("getAttributeAndCheck"), WithErrorText(fmt.Sprintf("require len is %d, but got %d", size, len(f64))))
+			return nil, gEC(WithKind(ErrCorrupt), WithFunction("getAttributeAndCheck"), WithErrorText(fmt.Sprintf("require len is %d, but got %d", size, len(f64))))
 		}
 	}
 }
@@ -54,6 +54,18 @@ func (t *transform) Init(allAttribute ...geoAttribute) error {
 		t.Data[3] = val[7]
 		t.Data[4] = val[4]
 		t.Data[5] = val[5]
+	} else if val, err = getAttributeAndCheck(allAttribute, ModelPixelScaleTag, 2); err == nil {
+		t.Data[1] = val[0]
+		t.Data[5] = -math.Abs(val[1])
+		if val, err = getAttributeAndCheck(allAttribute, ModelTiepointTag, 6); err == nil {
+			t.Data[0] = val[3] - val[0]*t.Data[1]
+			t.Data[3] = val[4] - val[1]*t.Data[5]
+
+			if t.PixelIsPoint && !t.PointGeoIgnore {
+				t.Data[0] -= t.Data[1]*0.5 + t.Data[2]*0.5
+				t.Data[3] -= t.Data[4]*0.5 + t.Data[5]*0.5
+			}
+		}
 	} else if val, err = getAttributeAndCheck(allAttribute, ModelTiepointTag, 6); err == nil {
 		//https://github.com/grumets/MiraMonMapBrowser/blob/b997173bc0ee2ebd1d61567a0d4e33d1c44004a4/src/geotiff/geotiffimage.js#L744
 		valCount := len(val) / 6
@@ -75,23 +87,11 @@ func (t *transform) Init(allAttribute ...geoAttribute) error {
 		t.Data[4] = t.Resolution[1]
 		t.Data[5] = t.TilePoints[0].y
 		//fmt.Println("i don't know how programming")
-	} else if val, err = getAttributeAndCheck(allAttribute, ModelPixelScaleTag, 2); err == nil {
-		t.Data[1] = val[0]
-		t.Data[5] = -math.Abs(val[1])
-		if val, err = getAttributeAndCheck(allAttribute, ModelTiepointTag, 6); err == nil {
-			t.Data[0] = val[3] - val[0]*t.Data[1]
-			t.Data[3] = val[4] - val[1]*t.Data[5]
-
-			if t.PixelIsPoint && !t.PointGeoIgnore {
-				t.Data[0] -= t.Data[1]*0.5 + t.Data[2]*0.5
-				t.Data[3] -= t.Data[4]*0.5 + t.Data[5]*0.5
-			}
-		}
 	} else {
-		return gEC(WithFunction("Transform.Init"), WithErrorText("can not init t.Data"))
+		return gEC(WithKind(ErrMissingTag), WithFunction("Transform.Init"), WithErrorText("can not init t.Data"))
 	}
-	t.Resolution[0] = t.Data[0]
-	t.Resolution[1] = t.Data[4]
+	t.Resolution[0] = t.Data[1]
+	t.Resolution[1] = t.Data[5]
 	t.Resolution[2] = 0
 	return nil
 }
@@ -0,0 +1,547 @@
+package GeoTiff
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Compression types which the Writer support
+const (
+	CompressionNone    = cNone
+	CompressionDeflate = cDeflate
+)
+
+type writerConfig struct {
+	compression  CompressionType
+	rowsPerStrip int
+	sparse       bool
+}
+
+type WriterOptions func(wc *writerConfig)
+
+func WithCompression(compression CompressionType) WriterOptions {
+	return func(wc *writerConfig) {
+		wc.compression = compression
+	}
+}
+func WithRowsPerStrip(rowsPerStrip int) WriterOptions {
+	return func(wc *writerConfig) {
+		wc.rowsPerStrip = rowsPerStrip
+	}
+}
+
+// WithSparse skip the strips which are all nodata (or 0 when there is no nodata) like SPARSE_OK of GDAL,
+// their offset and byte count are 0 and they are read back as nodata
+func WithSparse(sparse bool) WriterOptions {
+	return func(wc *writerConfig) {
+		wc.sparse = sparse
+	}
+}
+
+// Writer write a single band (gray) GeoTif strip by strip
+//
+//	header | strip 0 | strip 1 | ... | IFD | values of the IFD
+//
+// the IFD is written by Close, and the offset in the header is patched at last
+type Writer struct {
+	w         io.WriteSeeker
+	byteOrder binary.ByteOrder
+	cfg       writerConfig
+
+	meta      Meta
+	transform transform
+	geoKeys   GeoAttributes
+	metadata  GDALMetadata
+
+	pos          int64
+	rows         int
+	pending      []float64
+	stripOffsets []uint32
+	stripCounts  []uint32
+	putSample    func(buf []byte, v float64)
+	sampleBytes  int
+
+	// the row of blocks which is being filled by WriteBlock
+	band       []float64
+	bandCols   int
+	bandHeight int
+}
+
+// NewWriter create a Writer for a raster which is like the template
+// Columns, Rows, BitsPerSample, SampleFormat, NodataValue, Transform, GeoKeys and Metadata are taken from the template
+func NewWriter(w io.WriteSeeker, template *GeoTif, opts ...WriterOptions) (*Writer, error) {
+	var gEC = NewGeoErrorCreator("NewWriter")
+	gw := &Writer{
+		w:         w,
+		byteOrder: binary.LittleEndian,
+		cfg: writerConfig{
+			compression:  CompressionNone,
+			rowsPerStrip: 0,
+		},
+		meta:      template.Meta,
+		transform: template.Transform,
+		geoKeys:   template.GeoKeys,
+		metadata:  template.Metadata,
+	}
+	for _, opt := range opts {
+		opt(&gw.cfg)
+	}
+	if gw.cfg.compression != CompressionNone && gw.cfg.compression != CompressionDeflate {
+		return nil, gEC(WithKind(ErrUnsupportedCompression), WithErrorText(fmt.Sprintf("Unsupported compression value %d", gw.cfg.compression)))
+	}
+	if gw.meta.Columns == 0 || gw.meta.Rows == 0 {
+		return nil, gEC(WithErrorText(fmt.Sprintf("wrong size %dx%d", gw.meta.Columns, gw.meta.Rows)))
+	}
+	if gw.meta.mode != mGray && gw.meta.mode != mGrayInvert {
+		return nil, gEC(WithKind(ErrUnsupportedSampleFormat), WithErrorText("only gray image can be written"))
+	}
+	if len(gw.meta.BitsPerSample) == 0 {
+		return nil, gEC(WithErrorText("BitsPerSample is empty"))
+	}
+	var err error
+	// NaN is written as nodata to the integer samples, or 0 when there is no nodata
+	nan, ok := gw.meta.Nodata()
+	if !ok || math.IsNaN(nan) {
+		nan = 0
+	}
+	if gw.putSample, err = sampleEncoder(gw.byteOrder, gw.meta.SampleFormat, gw.meta.BitsPerSample[0], nan); err != nil {
+		return nil, gEC(WithError(err))
+	}
+	gw.sampleBytes = int(gw.meta.BitsPerSample[0] / 8)
+	if gw.cfg.rowsPerStrip <= 0 {
+		// about 64k bytes per strip
+		gw.cfg.rowsPerStrip = 1 + (1<<16)/(int(gw.meta.Columns)*gw.sampleBytes)
+	}
+	gw.cfg.rowsPerStrip = minInt(gw.cfg.rowsPerStrip, int(gw.meta.Rows))
+
+	header := make([]byte, 8)
+	binary.BigEndian.PutUint32(header[0:4], littleEndian)
+	if err = gw.write(header); err != nil {
+		return nil, gEC(WithError(err))
+	}
+	return gw, nil
+}
+
+// sampleEncoder return the function which write a sample, the integer samples are rounded and clamped to the range
+// of the type, NaN is written as nan since its conversion to an integer is not defined
+func sampleEncoder(order binary.ByteOrder, sampleFormat, bitsPerSample uint, nan float64) (func(buf []byte, v float64), error) {
+	clamp := func(v, min, max float64) float64 {
+		if math.IsNaN(v) {
+			v = nan
+		}
+		v = math.Round(v)
+		if v < min {
+			return min
+		}
+		if v > max {
+			return max
+		}
+		return v
+	}
+	switch sampleFormat {
+	case 1: // Unsigned integer data
+		switch bitsPerSample {
+		case 8:
+			return func(buf []byte, v float64) { buf[0] = uint8(clamp(v, 0, math.MaxUint8)) }, nil
+		case 16:
+			return func(buf []byte, v float64) { order.PutUint16(buf, uint16(clamp(v, 0, math.MaxUint16))) }, nil
+		case 32:
+			return func(buf []byte, v float64) { order.PutUint32(buf, uint32(clamp(v, 0, math.MaxUint32))) }, nil
+		}
+	case 2: // Signed integer data
+		switch bitsPerSample {
+		case 8:
+			return func(buf []byte, v float64) { buf[0] = uint8(int8(clamp(v, math.MinInt8, math.MaxInt8))) }, nil
+		case 16:
+			return func(buf []byte, v float64) {
+				order.PutUint16(buf, uint16(int16(clamp(v, math.MinInt16, math.MaxInt16))))
+			}, nil
+		case 32:
+			return func(buf []byte, v float64) {
+				order.PutUint32(buf, uint32(int32(clamp(v, math.MinInt32, math.MaxInt32))))
+			}, nil
+		}
+	case 3: // Floating point data
+		switch bitsPerSample {
+		case 32:
+			return func(buf []byte, v float64) { order.PutUint32(buf, math.Float32bits(float32(v))) }, nil
+		case 64:
+			return func(buf []byte, v float64) { order.PutUint64(buf, math.Float64bits(v)) }, nil
+		}
+	}
+	return nil, gEC(WithKind(ErrUnsupportedSampleFormat), WithFunction("sampleEncoder"), WithErrorText(fmt.Sprintf("Unsupported data format, SampleFormat [%d] BitsPerSample [%d]", sampleFormat, bitsPerSample)))
+}
+
+func (gw *Writer) write(data []byte) error {
+	n, err := gw.w.Write(data)
+	gw.pos += int64(n)
+	return err
+}
+
+// WriteRows append whole rows, len(data) should be a multiple of Columns
+func (gw *Writer) WriteRows(data []float64) error {
+	width := int(gw.meta.Columns)
+	if len(data)%width != 0 {
+		return gEC(WithFunction("Writer.WriteRows"), WithErrorText(fmt.Sprintf("len(data) [%d] is not a multiple of columns [%d]", len(data), width)))
+	}
+	if gw.rows+len(gw.pending)/width+len(data)/width > int(gw.meta.Rows) {
+		return gEC(WithKind(ErrOutOfRange), WithFunction("Writer.WriteRows"), WithErrorText(fmt.Sprintf("too many rows, the image has only %d rows", gw.meta.Rows)))
+	}
+	gw.pending = append(gw.pending, data...)
+	stripLen := gw.cfg.rowsPerStrip * width
+	for len(gw.pending) >= stripLen {
+		if err := gw.writeStrip(gw.pending[:stripLen]); err != nil {
+			return gEC(WithFunction("Writer.WriteRows"), WithError(err))
+		}
+		gw.pending = gw.pending[stripLen:]
+	}
+	// the last strip may be shorter
+	if gw.rows+len(gw.pending)/width == int(gw.meta.Rows) && len(gw.pending) > 0 {
+		if err := gw.writeStrip(gw.pending); err != nil {
+			return gEC(WithFunction("Writer.WriteRows"), WithError(err))
+		}
+		gw.pending = nil
+	}
+	return nil
+}
+
+// WriteBlock make Writer a BlockSink, the blocks should come row by row from the left to the right
+// and the blocks of a row should have the same height
+func (gw *Writer) WriteBlock(b Block) error {
+	width := int(gw.meta.Columns)
+	if b.Row != gw.rows+len(gw.pending)/width || b.Col != gw.bandCols || b.Col+b.Width > width ||
+		(gw.bandCols > 0 && b.Height != gw.bandHeight) || len(b.Data) != b.Width*b.Height {
+		return gEC(WithKind(ErrOutOfRange), WithFunction("Writer.WriteBlock"), WithErrorText(fmt.Sprintf("block %d [%d, %d, %dx%d] is not the next block", b.Index, b.Col, b.Row, b.Width, b.Height)))
+	}
+	if gw.bandCols == 0 {
+		gw.bandHeight = b.Height
+		if len(gw.band) != b.Height*width {
+			gw.band = make([]float64, b.Height*width)
+		}
+	}
+	for r := 0; r < b.Height; r++ {
+		copy(gw.band[r*width+b.Col:], b.Data[r*b.Width:(r+1)*b.Width])
+	}
+	gw.bandCols += b.Width
+	if gw.bandCols < width {
+		return nil
+	}
+	gw.bandCols = 0
+	if err := gw.WriteRows(gw.band); err != nil {
+		return gEC(WithFunction("Writer.WriteBlock"), WithError(err))
+	}
+	return nil
+}
+
+// isEmpty check all the values are nodata, or 0 when there is no nodata
+func (gw *Writer) isEmpty(data []float64) bool {
+	nodata, _ := gw.meta.Nodata()
+	for _, v := range data {
+		if v != nodata && !(math.IsNaN(v) && math.IsNaN(nodata)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (gw *Writer) writeStrip(data []float64) error {
+	if gw.cfg.sparse && gw.isEmpty(data) {
+		gw.stripOffsets = append(gw.stripOffsets, 0)
+		gw.stripCounts = append(gw.stripCounts, 0)
+		gw.rows += len(data) / int(gw.meta.Columns)
+		return nil
+	}
+	raw := make([]byte, len(data)*gw.sampleBytes)
+	for i, v := range data {
+		gw.putSample(raw[i*gw.sampleBytes:], v)
+	}
+	if gw.cfg.compression == CompressionDeflate {
+		var b bytes.Buffer
+		zw := zlib.NewWriter(&b)
+		if _, err := zw.Write(raw); err != nil {
+			return gEC(WithFunction("Writer.writeStrip"), WithError(err))
+		}
+		if err := zw.Close(); err != nil {
+			return gEC(WithFunction("Writer.writeStrip"), WithError(err))
+		}
+		raw = b.Bytes()
+	}
+	gw.stripOffsets = append(gw.stripOffsets, uint32(gw.pos))
+	gw.stripCounts = append(gw.stripCounts, uint32(len(raw)))
+	gw.rows += len(data) / int(gw.meta.Columns)
+	return gw.write(raw)
+}
+
+// Close write the IFD, it does not close the underlying writer
+func (gw *Writer) Close() error {
+	var gEC = NewGeoErrorCreator("Writer.Close")
+	if gw.rows != int(gw.meta.Rows) {
+		return gEC(WithErrorText(fmt.Sprintf("only %d of %d rows are written", gw.rows, gw.meta.Rows)))
+	}
+	attributes, err := gw.attributes()
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	if gw.pos%2 == 1 {
+		if err = gw.write([]byte{0}); err != nil {
+			return gEC(WithError(err))
+		}
+	}
+	ifdOffset := gw.pos
+	ifd, err := encodeIFD(attributes, gw.byteOrder, ifdOffset)
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	if err = gw.write(ifd); err != nil {
+		return gEC(WithError(err))
+	}
+	if _, err = gw.w.Seek(4, io.SeekStart); err != nil {
+		return gEC(WithError(err))
+	}
+	offset := make([]byte, 4)
+	gw.byteOrder.PutUint32(offset, uint32(ifdOffset))
+	if _, err = gw.w.Write(offset); err != nil {
+		return gEC(WithError(err))
+	}
+	if _, err = gw.w.Seek(gw.pos, io.SeekStart); err != nil {
+		return gEC(WithError(err))
+	}
+	return nil
+}
+
+// encodeIFD encode the attributes as an IFD which is put at ifdOffset
+// the values which are longer than 4 bytes follow the IFD
+func encodeIFD(attributes GeoAttributes, order binary.ByteOrder, ifdOffset int64) ([]byte, error) {
+	sort.Slice(attributes, func(i, j int) bool {
+		return attributes[i].Tag < attributes[j].Tag
+	})
+	ifdLen := 2 + int64(len(attributes))*geoFileAttributeSize + 4
+	valueOffset := ifdOffset + ifdLen
+	var values []byte
+	for i := range attributes {
+		if attributes[i].Bytes() > 4 {
+			attributes[i].Offset = uint32(valueOffset + int64(len(values)))
+			values = append(values, attributes[i].SourceValue...)
+			if len(values)%2 == 1 {
+				values = append(values, 0)
+			}
+		}
+	}
+	if valueOffset+int64(len(values)) > math.MaxUint32 {
+		return nil, gEC(WithKind(ErrTooLarge), WithFunction("encodeIFD"), WithErrorText("file is larger than 4GB"))
+	}
+	data := make([]byte, 2, ifdLen+int64(len(values)))
+	order.PutUint16(data, uint16(len(attributes)))
+	for _, attribute := range attributes {
+		data = append(data, attribute.toBytes(order)...)
+	}
+	// there is no next IFD
+	data = append(data, 0, 0, 0, 0)
+	return append(data, values...), nil
+}
+
+func (gw *Writer) attributes() (GeoAttributes, error) {
+	bitsPerSample := uint16(gw.meta.BitsPerSample[0])
+	photometric := uint16(PI_BlackIsZero)
+	if gw.meta.mode == mGrayInvert {
+		photometric = uint16(PI_WhiteIsZero)
+	}
+	attributes := GeoAttributes{
+		newLongAttribute(gw.byteOrder, ImageWidth, uint32(gw.meta.Columns)),
+		newLongAttribute(gw.byteOrder, ImageLength, uint32(gw.meta.Rows)),
+		newShortAttribute(gw.byteOrder, BitsPerSample, bitsPerSample),
+		newShortAttribute(gw.byteOrder, Compression, uint16(gw.cfg.compression)),
+		newShortAttribute(gw.byteOrder, PhotometricInterpretation, photometric),
+		newLongAttribute(gw.byteOrder, StripOffsets, gw.stripOffsets...),
+		newShortAttribute(gw.byteOrder, SamplesPerPixel, 1),
+		newLongAttribute(gw.byteOrder, RowsPerStrip, uint32(gw.cfg.rowsPerStrip)),
+		newLongAttribute(gw.byteOrder, StripByteCounts, gw.stripCounts...),
+		newShortAttribute(gw.byteOrder, PlanarConfiguration, 1),
+		newShortAttribute(gw.byteOrder, SampleFormat, uint16(gw.meta.SampleFormat)),
+	}
+	t := gw.transform.Data
+	// ModelPixelScaleTag is north up, a flipped raster (e.g. from the Orientation of the file) needs the matrix
+	if t[2] == 0 && t[4] == 0 && t[1] > 0 && t[5] < 0 {
+		attributes = append(attributes,
+			newDoubleAttribute(gw.byteOrder, ModelPixelScaleTag, t[1], -t[5], 0),
+			newDoubleAttribute(gw.byteOrder, ModelTiepointTag, 0, 0, 0, t[0], t[3], 0),
+		)
+	} else {
+		attributes = append(attributes, newDoubleAttribute(gw.byteOrder, ModelTransformationTag,
+			t[1], t[2], 0, t[0],
+			t[4], t[5], 0, t[3],
+			0, 0, 0, 0,
+			0, 0, 0, 1,
+		))
+	}
+	geoKeys := gw.geoKeys
+	if len(geoKeys) == 0 && gw.meta.EPSGCode != 0 {
+		geoKeys = geoKeysFromEPSG(gw.byteOrder, gw.meta.EPSGCode)
+	}
+	// the directory is written even without keys, the reader requires it
+	geoKeyAttributes, err := encodeGeoKeys(gw.byteOrder, geoKeys)
+	if err != nil {
+		return nil, gEC(WithFunction("Writer.attributes"), WithError(err))
+	}
+	attributes = append(attributes, geoKeyAttributes...)
+	if !gw.metadata.IsEmpty() {
+		attributes = append(attributes, newASCIIAttribute(GDAL_METADATA, gw.metadata.String()))
+	}
+	if nodata := strings.TrimRight(gw.meta.NodataValue, "\x00"); nodata != "" {
+		attributes = append(attributes, newASCIIAttribute(GDAL_NODATA, nodata))
+	}
+	return attributes, nil
+}
+
+// geoKeysFromEPSG create the minimal GeoKeys for an EPSG code
+// codes of 4000~4999 are taken as geographic CRS
+func geoKeysFromEPSG(order binary.ByteOrder, epsg uint) GeoAttributes {
+	if epsg >= 4000 && epsg < 5000 {
+		return GeoAttributes{
+			newShortAttribute(order, GTModelTypeGeoKey, 2),
+			newShortAttribute(order, GTRasterTypeGeoKey, 1),
+			newShortAttribute(order, GeographicTypeGeoKey, uint16(epsg)),
+		}
+	}
+	return GeoAttributes{
+		newShortAttribute(order, GTModelTypeGeoKey, 1),
+		newShortAttribute(order, GTRasterTypeGeoKey, 1),
+		newShortAttribute(order, ProjectedCSTypeGeoKey, uint16(epsg)),
+	}
+}
+
+// encodeGeoKeys build GeoKeyDirectoryTag, GeoDoubleParamsTag and GeoAsciiParamsTag from the GeoKeys
+// http://geotiff.maptools.org/spec/geotiff2.4.html
+// the keys whose values are in other tags are not parsed (their Type is 0), they are dropped
+// since the tags they point to may not be written
+func encodeGeoKeys(order binary.ByteOrder, geoKeys GeoAttributes) (GeoAttributes, error) {
+	keys := make(GeoAttributes, 0, len(geoKeys))
+	for _, key := range geoKeys {
+		if key.Type == SHORT || key.Type == DOUBLE || key.Type == ASCII {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Tag < keys[j].Tag
+	})
+	directory := []uint16{1, 1, 0, uint16(len(keys))}
+	var doubles []float64
+	var ascii string
+	for _, key := range keys {
+		switch key.Type {
+		case SHORT:
+			if len(key.GeoAttributeValue.SHORT) == 0 {
+				return nil, gEC(WithFunction("encodeGeoKeys"), WithErrorText(fmt.Sprintf("GeoKey [%d] has no value", key.Tag)))
+			}
+			directory = append(directory, uint16(key.Tag), 0, 1, key.GeoAttributeValue.SHORT[0])
+		case DOUBLE:
+			directory = append(directory, uint16(key.Tag), uint16(GeoDoubleParamsTag), uint16(len(key.GeoAttributeValue.DOUBLE)), uint16(len(doubles)))
+			doubles = append(doubles, key.GeoAttributeValue.DOUBLE...)
+		case ASCII:
+			s := strings.TrimRight(key.GeoAttributeValue.ASCII, "\x00")
+			if !strings.HasSuffix(s, "|") {
+				s += "|"
+			}
+			directory = append(directory, uint16(key.Tag), uint16(GeoAsciiParamsTag), uint16(len(s)), uint16(len(ascii)))
+			ascii += s
+		}
+	}
+	attributes := GeoAttributes{newShortAttribute(order, GeoKeyDirectoryTag, directory...)}
+	if len(doubles) > 0 {
+		attributes = append(attributes, newDoubleAttribute(order, GeoDoubleParamsTag, doubles...))
+	}
+	if len(ascii) > 0 {
+		attributes = append(attributes, newASCIIAttribute(GeoAsciiParamsTag, ascii))
+	}
+	return attributes, nil
+}
+
+func newShortAttribute(order binary.ByteOrder, tag AttributeTag, values ...uint16) geoAttribute {
+	gAttribute := geoAttribute{Tag: tag, Type: SHORT, Len: uint32(len(values))}
+	gAttribute.SourceValue = make([]byte, maxInt(len(values)*2, 4))
+	for i, v := range values {
+		order.PutUint16(gAttribute.SourceValue[i*2:], v)
+	}
+	_ = gAttribute.parseValue(order)
+	return gAttribute
+}
+func newLongAttribute(order binary.ByteOrder, tag AttributeTag, values ...uint32) geoAttribute {
+	gAttribute := geoAttribute{Tag: tag, Type: LONG, Len: uint32(len(values))}
+	gAttribute.SourceValue = make([]byte, maxInt(len(values)*4, 4))
+	for i, v := range values {
+		order.PutUint32(gAttribute.SourceValue[i*4:], v)
+	}
+	_ = gAttribute.parseValue(order)
+	return gAttribute
+}
+func newDoubleAttribute(order binary.ByteOrder, tag AttributeTag, values ...float64) geoAttribute {
+	gAttribute := geoAttribute{Tag: tag, Type: DOUBLE, Len: uint32(len(values))}
+	gAttribute.SourceValue = make([]byte, len(values)*8)
+	for i, v := range values {
+		order.PutUint64(gAttribute.SourceValue[i*8:], math.Float64bits(v))
+	}
+	_ = gAttribute.parseValue(order)
+	return gAttribute
+}
+
+// newASCIIAttribute append the NUL to the value
+func newASCIIAttribute(tag AttributeTag, value string) geoAttribute {
+	source := append([]byte(value), 0)
+	gAttribute := geoAttribute{Tag: tag, Type: ASCII, Len: uint32(len(source))}
+	for len(source) < 4 {
+		source = append(source, 0)
+	}
+	gAttribute.SourceValue = source
+	_ = gAttribute.parseValue(binary.LittleEndian)
+	return gAttribute
+}
+
+func maxInt(a, b int) int {
+	if a >= b {
+		return a
+	}
+	return b
+}
+
+// Write the GeoTif to w
+func (g *GeoTif) Write(w io.WriteSeeker, opts ...WriterOptions) error {
+	var gEC = NewGeoErrorCreator("GeoTif.Write")
+	gw, err := NewWriter(w, g, opts...)
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	if err = g.pixels(); err != nil {
+		return gEC(WithError(err))
+	}
+	if err = gw.WriteRows(g.Data.Data); err != nil {
+		return gEC(WithError(err))
+	}
+	if err = gw.Close(); err != nil {
+		return gEC(WithError(err))
+	}
+	return nil
+}
+
+// Save the GeoTif to FilePath
+func (g *GeoTif) Save(FilePath string, opts ...WriterOptions) error {
+	var gEC = NewGeoErrorCreator("GeoTif.Save")
+	f, err := os.Create(FilePath)
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	if err = g.Write(f, opts...); err != nil {
+		f.Close()
+		return gEC(WithError(err))
+	}
+	if err = f.Close(); err != nil {
+		return gEC(WithError(err))
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package GeoTiff
+
+import (
+	"context"
+	"fmt"
+	"github.com/SunIBAS/gotool/compress"
+	"strings"
+)
+
+// VSIZipPrefix open the tif in a zip like GDAL, OpenGeoTif("/vsizip/data/ndvi.zip/2020/ndvi.tif")
+// read the entry "2020/ndvi.tif" of "data/ndvi.zip" without extracting it
+const VSIZipPrefix = "/vsizip/"
+
+// splitVSIZip split "/vsizip/{zip}/{entry}" after the first ".zip"
+func splitVSIZip(FilePath string) (zipFilePath, name string, ok bool) {
+	if !strings.HasPrefix(FilePath, VSIZipPrefix) {
+		return "", "", false
+	}
+	rest := FilePath[len(VSIZipPrefix):]
+	lower := strings.ToLower(rest)
+	for from := 0; ; {
+		i := strings.Index(lower[from:], ".zip")
+		if i < 0 {
+			return "", "", false
+		}
+		end := from + i + len(".zip")
+		if end < len(rest) && (rest[end] == '/' || rest[end] == '\\') {
+			return rest[:end], rest[end+1:], true
+		}
+		from = end
+	}
+}
+
+// VSIZipPath return the path of the entry of the zip which can be opened by OpenGeoTif
+func VSIZipPath(zipFilePath, name string) string {
+	return VSIZipPrefix + zipFilePath + "/" + name
+}
+
+// OpenZip read the tif name (e.g. "2020/ndvi.tif") in the zip without extracting it,
+// a stored entry is read with random access and a compressed one is decompressed into memory as far as it is read
+func OpenZip(zipFilePath, name string, opts ...OpenOptions) (*GeoTif, error) {
+	return OpenZipContext(context.Background(), zipFilePath, name, opts...)
+}
+
+// OpenZipContext is OpenZip which stops reading the pixels when ctx is done
+func OpenZipContext(ctx context.Context, zipFilePath, name string, opts ...OpenOptions) (*GeoTif, error) {
+	var gEC = NewGeoErrorCreator("OpenZip")
+	g, err := OpenGeoTifContext(ctx, VSIZipPath(zipFilePath, name), opts...)
+	if err != nil {
+		return nil, gEC(WithError(err))
+	}
+	return g, nil
+}
+
+// openZip open the entry of the zip as tFile, WithMmap is not used
+func (g *GeoTif) openZip(zipFilePath, name string, cfg openConfig) error {
+	var gEC = NewGeoErrorCreator("openZip")
+	entry, err := compress.OpenZipEntry(zipFilePath, name)
+	if err != nil {
+		return gEC(WithError(err))
+	}
+	cacheFile := ""
+	if cfg.blockCache != nil {
+		zipKey, err := fileCacheKey(zipFilePath)
+		if err != nil {
+			entry.Close()
+			return gEC(WithError(err))
+		}
+		cacheFile = VSIZipPath(zipKey, entry.Name)
+	}
+	if err = g.openReader(entry, entry.Size, cacheFile, cfg); err != nil {
+		entry.Close()
+		return gEC(WithError(err), WithMsg(fmt.Sprintf("%s in %s", name, zipFilePath)))
+	}
+	return nil
+}
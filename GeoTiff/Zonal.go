@@ -0,0 +1,126 @@
+package GeoTiff
+
+import (
+	"fmt"
+	"math"
+)
+
+// ZonalStats is the statistics of the valid (not nodata) pixels in a polygon
+// Mean, Min, Max and Majority are NaN when Count is 0
+type ZonalStats struct {
+	Key        string
+	Properties map[string]interface{}
+	Count      int
+	Sum        float64
+	Mean       float64
+	Min        float64
+	Max        float64
+	// Majority is the most frequent value, the smallest one wins when there is a tie
+	Majority float64
+}
+
+type zonalConfig struct {
+	allTouched bool
+	keyField   string
+}
+
+type ZonalOptions func(zc *zonalConfig)
+
+// WithZonalAllTouched take all the pixels touched by the polygon, not only those whose center is inside
+func WithZonalAllTouched(allTouched bool) ZonalOptions {
+	return func(zc *zonalConfig) {
+		zc.allTouched = allTouched
+	}
+}
+
+// WithZonalKey key the result by the property of the feature, e.g. the code of the county
+func WithZonalKey(field string) ZonalOptions {
+	return func(zc *zonalConfig) {
+		zc.keyField = field
+	}
+}
+
+// ZonalStatistics calculate the statistics of g in every polygon of the FeatureCollection
+// the result is keyed by the property given by WithZonalKey, or the id of the feature, or its index
+// the features which are not polygon are skipped
+func ZonalStatistics(fc *GeoJSONFeatureCollection, g *GeoTif, opts ...ZonalOptions) (map[string]ZonalStats, error) {
+	var gEC = NewGeoErrorCreator("ZonalStatistics")
+	cfg := zonalConfig{}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if err := g.pixels(); err != nil {
+		return nil, gEC(WithError(err))
+	}
+	width := int(g.Meta.Columns)
+	height := int(g.Meta.Rows)
+	ret := map[string]ZonalStats{}
+	for i, feature := range fc.Features {
+		key := fmt.Sprint(i)
+		if cfg.keyField != "" {
+			v, ok := feature.Properties[cfg.keyField]
+			if !ok {
+				return nil, gEC(WithErrorText(fmt.Sprintf("feature %d has no property [%s]", i, cfg.keyField)))
+			}
+			key = fmt.Sprint(v)
+		} else if feature.ID != nil {
+			key = fmt.Sprint(feature.ID)
+		}
+		if _, ok := ret[key]; ok {
+			return nil, gEC(WithErrorText(fmt.Sprintf("key [%s] of feature %d is not unique", key, i)))
+		}
+		if feature.Geometry == nil {
+			continue
+		}
+		polygons, err := feature.Geometry.Polygons()
+		if err != nil {
+			return nil, gEC(WithError(err), WithMsg(fmt.Sprintf("feature %d", i)))
+		}
+		if len(polygons) == 0 {
+			continue
+		}
+		// the polygons of a MultiPolygon may share pixels, so they are marked on one mask
+		var allRings [][][2]float64
+		pixelPolygons := make([][][][2]float64, len(polygons))
+		for j, polygon := range polygons {
+			pixelPolygons[j] = g.toPixelRings(polygon)
+			allRings = append(allRings, pixelPolygons[j]...)
+		}
+		mask := newPixelMask(ringsWindow(allRings, width, height))
+		for _, polygon := range pixelPolygons {
+			rasterizePolygon(polygon, width, height, cfg.allTouched).each(mask.set)
+		}
+		stats := ZonalStats{
+			Key:        key,
+			Properties: feature.Properties,
+			Min:        math.Inf(1),
+			Max:        math.Inf(-1),
+		}
+		counts := map[float64]int{}
+		mask.each(func(col, row int) {
+			v := g.Data.Data[row*width+col]
+			if g.IsNodata(v) {
+				return
+			}
+			stats.Count++
+			stats.Sum += v
+			stats.Min = math.Min(stats.Min, v)
+			stats.Max = math.Max(stats.Max, v)
+			counts[v]++
+		})
+		if stats.Count == 0 {
+			stats.Mean, stats.Min, stats.Max, stats.Majority = math.NaN(), math.NaN(), math.NaN(), math.NaN()
+		} else {
+			stats.Mean = stats.Sum / float64(stats.Count)
+			best := 0
+			for v, c := range counts {
+				if c > best || (c == best && v < stats.Majority) {
+					best = c
+					stats.Majority = v
+				}
+			}
+		}
+		ret[key] = stats
+	}
+	return ret, nil
+}
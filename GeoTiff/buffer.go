@@ -4,10 +4,15 @@
 
 package GeoTiff
 
-import "io"
+import (
+	"io"
+	"sync"
+)
 
 // buffer buffers an io.Reader to satisfy io.ReaderAt.
+// It can be read by many goroutines at the same time.
 type buffer struct {
+	mu  sync.Mutex
 	r   io.Reader
 	buf []byte
 }
@@ -43,20 +48,39 @@ func (b *buffer) ReadAt(p []byte, off int64) (int, error) {
 		return 0, io.ErrUnexpectedEOF
 	}
 
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	err := b.fill(end)
-	return copy(p, b.buf[o:end]), err
+	if err != nil {
+		// the reader ends before end, copy what is read
+		if o >= len(b.buf) {
+			return 0, err
+		}
+		return copy(p, b.buf[o:]), err
+	}
+	return copy(p, b.buf[o:end]), nil
 }
 
 // Slice returns a slice of the underlying buffer. The slice contains
 // n bytes starting at offset off.
 func (b *buffer) Slice(off, n int) ([]byte, error) {
 	end := off + n
+	b.mu.Lock()
+	defer b.mu.Unlock()
 	if err := b.fill(end); err != nil {
 		return nil, err
 	}
 	return b.buf[off:end], nil
 }
 
+// Close closes the reader when it is an io.Closer.
+func (b *buffer) Close() error {
+	if c, ok := b.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
 // newReaderAt converts an io.Reader into an io.ReaderAt.
 func newReaderAt(r io.Reader) io.ReaderAt {
 	if ra, ok := r.(io.ReaderAt); ok {
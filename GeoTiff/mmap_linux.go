@@ -0,0 +1,44 @@
+//go:build linux
+
+package GeoTiff
+
+import (
+	"os"
+	"syscall"
+)
+
+// mmapFile is an io.ReaderAt on the memory mapped file, Slice returns the mapped memory without copy
+type mmapFile struct {
+	bytesFile
+}
+
+// openMmap map the whole file read only
+func openMmap(FilePath string) (*mmapFile, error) {
+	f, err := os.Open(FilePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	size := info.Size()
+	if size <= 0 || int64(int(size)) != size {
+		return nil, gEC(WithFunction("openMmap"), WithErrorText("the file is empty or too large to be mapped"))
+	}
+	data, err := syscall.Mmap(int(f.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED)
+	if err != nil {
+		return nil, err
+	}
+	return &mmapFile{bytesFile{data: data}}, nil
+}
+
+func (m *mmapFile) Close() error {
+	if m.data == nil {
+		return nil
+	}
+	err := syscall.Munmap(m.data)
+	m.data = nil
+	return err
+}
@@ -0,0 +1,24 @@
+//go:build !linux
+
+package GeoTiff
+
+// mmapFile is only supported on linux, WithMmap falls back to os.Open on the other systems
+type mmapFile struct {
+	data []byte
+}
+
+func openMmap(FilePath string) (*mmapFile, error) {
+	return nil, gEC(WithFunction("openMmap"), WithErrorText("mmap is only supported on linux"))
+}
+
+func (m *mmapFile) ReadAt(p []byte, off int64) (int, error) {
+	return 0, gEC(WithFunction("mmapFile.ReadAt"), WithErrorText("mmap is only supported on linux"))
+}
+
+func (m *mmapFile) Slice(off, n int) ([]byte, error) {
+	return nil, gEC(WithFunction("mmapFile.Slice"), WithErrorText("mmap is only supported on linux"))
+}
+
+func (m *mmapFile) Close() error {
+	return nil
+}
@@ -4,7 +4,7 @@ import (
 	"archive/zip"
 	"errors"
 	"fmt"
-	"gotool/FileDirUtils"
+	"github.com/SunIBAS/gotool/FileDirUtils"
 	"io"
 	"os"
 	"path/filepath"
@@ -13,8 +13,10 @@ import (
 type Zip struct {
 	ZipFilePath string
 	ZipItem     []ZipItem
-	zipFile     *os.File
-	zipWriter   *zip.Writer
+	// Keep choose the files of the zip which exists to be copied into the new one, all are copied when it is nil
+	Keep      func(name string) bool
+	zipFile   *os.File
+	zipWriter *zip.Writer
 }
 
 // Close /////////////  Write zip file  ////////////////
@@ -52,13 +54,13 @@ func (z *Zip) copyForNewWriter(ZipFilePath string) error {
 	if err != nil {
 		return err
 	}
-	destZipFile, err := os.Create(f)
+	destZipFile, err := os.Create(ZipFilePath)
 	if err != nil {
 		return err
 	}
 	// 创建一个zip writer，指向目标ZIP文件
 	zipWriter := zip.NewWriter(destZipFile)
-	err = copyFilesToZip(sourceZipFile, zipWriter)
+	err = copyFilesToZip(sourceZipFile, zipWriter, z.Keep)
 	if err != nil {
 		return err
 	}
@@ -72,8 +74,11 @@ func (z *Zip) copyForNewWriter(ZipFilePath string) error {
 	}
 	return nil
 }
-func copyFilesToZip(source *zip.ReadCloser, dest *zip.Writer) error {
+func copyFilesToZip(source *zip.ReadCloser, dest *zip.Writer, keep func(name string) bool) error {
 	for _, file := range source.File {
+		if keep != nil && !keep(file.Name) {
+			continue
+		}
 		sourceFile, err := file.Open()
 		if err != nil {
 			return err
@@ -92,6 +97,21 @@ func copyFilesToZip(source *zip.ReadCloser, dest *zip.Writer) error {
 	}
 	return nil
 }
+
+// Create open the zip to write the items one by one with Add, it should be closed by Close,
+// the files of the zip which exists are copied into it first (see Keep)
+func (z *Zip) Create() error {
+	return z.initForCompress()
+}
+
+// Add write the item into the zip opened by Create
+func (z *Zip) Add(item ZipItem) error {
+	if z.zipWriter == nil {
+		return errors.New("[Add] the zip is not created")
+	}
+	return item.WriteFile(z.zipWriter)
+}
+
 func (z *Zip) Compress() error {
 	if e := z.initForCompress(); e != nil {
 		return e
@@ -0,0 +1,131 @@
+package compress
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync"
+)
+
+// ZipEntry is an io.ReaderAt on a file in the zip without extracting it,
+// the stored file is read from the zip directly, the compressed one is decompressed into memory as far as it is read
+type ZipEntry struct {
+	Name string
+	// Size is the uncompressed size
+	Size    int64
+	Stored  bool
+	r       io.ReaderAt
+	rc      io.ReadCloser
+	zipFile *os.File
+}
+
+// OpenEntry open the file name of the zip, see OpenZipEntry
+func (z *Zip) OpenEntry(name string) (*ZipEntry, error) {
+	return OpenZipEntry(z.ZipFilePath, name)
+}
+
+// OpenZipEntry open the file name (e.g. "dir/a.tif") of the zip, it should be closed
+func OpenZipEntry(zipFilePath, name string) (*ZipEntry, error) {
+	f, err := os.Open(zipFilePath)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	zr, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		f.Close()
+		return nil, errors.New(fmt.Sprintf("[OpenZipEntry] %s {%v}", zipFilePath, err))
+	}
+	name = strings.TrimPrefix(path.Clean(strings.ReplaceAll(name, "\\", "/")), "/")
+	for _, file := range zr.File {
+		if file.Name != name {
+			continue
+		}
+		if file.FileInfo().IsDir() {
+			break
+		}
+		ze := &ZipEntry{Name: name, Size: int64(file.UncompressedSize64), zipFile: f}
+		if file.Method == zip.Store && file.CompressedSize64 == file.UncompressedSize64 {
+			offset, err := file.DataOffset()
+			if err != nil {
+				f.Close()
+				return nil, err
+			}
+			ze.Stored = true
+			ze.r = io.NewSectionReader(f, offset, ze.Size)
+			return ze, nil
+		}
+		if ze.rc, err = file.Open(); err != nil {
+			f.Close()
+			return nil, err
+		}
+		ze.r = &lazyBuffer{r: ze.rc}
+		return ze, nil
+	}
+	f.Close()
+	return nil, errors.New(fmt.Sprintf("[OpenZipEntry] %s is not a file of %s", name, zipFilePath))
+}
+
+func (ze *ZipEntry) ReadAt(p []byte, off int64) (int, error) {
+	if ze.r == nil {
+		return 0, os.ErrClosed
+	}
+	return ze.r.ReadAt(p, off)
+}
+
+func (ze *ZipEntry) Close() error {
+	if ze.zipFile == nil {
+		return nil
+	}
+	if ze.rc != nil {
+		ze.rc.Close()
+	}
+	err := ze.zipFile.Close()
+	ze.r, ze.rc, ze.zipFile = nil, nil, nil
+	return err
+}
+
+// lazyBuffer keep what is read from r, ReadAt reads r until the end of the bytes it returns,
+// it can be read by many goroutines at the same time
+// it is the buffer of the GeoTiff package (taken from golang.org/x/image/tiff) without Slice, it is copied here
+// because GeoTiff imports compress to read the zips and compress can not import it back
+type lazyBuffer struct {
+	mu  sync.Mutex
+	r   io.Reader
+	buf []byte
+	err error
+}
+
+func (lb *lazyBuffer) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("[lazyBuffer.ReadAt] negative offset")
+	}
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	end := off + int64(len(p))
+	for int64(len(lb.buf)) < end && lb.err == nil {
+		chunk := make([]byte, 32*1024)
+		n, err := lb.r.Read(chunk)
+		lb.buf = append(lb.buf, chunk[:n]...)
+		lb.err = err
+	}
+	var n int
+	if off < int64(len(lb.buf)) {
+		n = copy(p, lb.buf[off:])
+	}
+	if n < len(p) {
+		if lb.err != nil && lb.err != io.EOF {
+			return n, lb.err
+		}
+		return n, io.EOF
+	}
+	return n, nil
+}
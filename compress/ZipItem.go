@@ -40,6 +40,29 @@ func (zsi ZipStringItem) WriteFile(writer *zip.Writer) error {
 	return nil
 }
 
+type ZipBytesItem struct {
+	filePath string
+	content  []byte
+}
+
+func NewZipBytesItem(filepath string, content []byte) ZipBytesItem {
+	return ZipBytesItem{
+		filePath: filepath,
+		content:  content,
+	}
+}
+func (zbi ZipBytesItem) FilePath() string {
+	return zbi.filePath
+}
+func (zbi ZipBytesItem) WriteFile(writer *zip.Writer) error {
+	w, err := writer.Create(zbi.filePath)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(zbi.content)
+	return err
+}
+
 type ZipFileItem struct {
 	absoluteFilePath string
 	filePath         string
@@ -0,0 +1,96 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBlockCache(t *testing.T) {
+	file := newTiledFile(t)
+	cache := GeoTiff.NewBlockCache(1 << 20)
+	a, err := GeoTiff.OpenGeoTifHeader(file, GeoTiff.WithBlockCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GeoTiff.OpenGeoTifHeader(file, GeoTiff.WithBlockCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, g := range []*GeoTiff.GeoTif{a, b} {
+		for row := 0; row < 3; row++ {
+			for col := 0; col < 5; col++ {
+				v, err := g.Sample(col, row)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if v != float64(row*5+col+1) {
+					t.Errorf("pixel [%d, %d] is %v", col, row, v)
+				}
+			}
+		}
+	}
+	// 15 samples of each reader in 4 blocks, the second reader only hits
+	s := cache.Stats()
+	if s.Misses != 4 || s.Hits != 26 || s.Blocks != 4 || s.Bytes != (8+2+4+1)*8 || s.Evictions != 0 {
+		t.Errorf("stats is %+v", s)
+	}
+	if _, err = a.Sample(5, 0); err == nil {
+		t.Error("the pixel out of the raster is sampled")
+	}
+
+	// the blocks of the iterator are copies
+	it := a.Blocks()
+	it.Next()
+	it.Block().Data[0] = -1
+	if v, _ := b.Sample(0, 0); v != 1 {
+		t.Errorf("the cached block is modified, %v", v)
+	}
+
+	// the first tile (64 bytes) is larger than the cache and is not kept
+	small := GeoTiff.NewBlockCache(40)
+	c, err := GeoTiff.OpenGeoTifHeader(file, GeoTiff.WithBlockCache(small))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = c.ReadData(); err != nil {
+		t.Fatal(err)
+	}
+	if s = small.Stats(); s.Misses != 4 || s.Bytes > 40 || s.Blocks+int(s.Evictions) != 3 {
+		t.Errorf("small stats is %+v", s)
+	}
+	small.Purge()
+	if s = small.Stats(); s.Blocks != 0 || s.Misses != 0 || s.HitRate() != 0 {
+		t.Errorf("purged stats is %+v", s)
+	}
+}
+
+// the blocks of a file which is rewritten at the same path are not used
+func TestBlockCacheRewrite(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rewritten.tif")
+	cache := GeoTiff.NewBlockCache(1 << 20)
+	for k, v := range []float64{1, 2} {
+		g := GeoTiff.NewGeoTif(5, 3, 8, GeoTiff.SampleFormatUint)
+		for i := range g.Data.Data {
+			g.Data.Data[i] = v
+		}
+		if err := g.Save(file); err != nil {
+			t.Fatal(err)
+		}
+		// the same size, and another modification time
+		modified := time.Date(2020, 1, 1+k, 0, 0, 0, 0, time.UTC)
+		if err := os.Chtimes(file, modified, modified); err != nil {
+			t.Fatal(err)
+		}
+		h, err := GeoTiff.OpenGeoTifHeader(file, GeoTiff.WithBlockCache(cache))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := h.Sample(0, 0); err != nil || got != v {
+			t.Errorf("the pixel of the file %d is %v, %v", k, got, err)
+		}
+		h.Close()
+	}
+}
@@ -0,0 +1,222 @@
+package GeoTiff
+
+import (
+	"encoding/binary"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// rawTag is a SHORT (3), LONG (4) or DOUBLE (12, the values are in doubles) tag of rawTIFF
+type rawTag struct {
+	tag     uint16
+	typ     uint16
+	values  []uint32
+	doubles []float64
+}
+
+// rawTIFF build a little endian tif of 8 bits gray pixels, the blocks are stored one by one after the header
+// and their offsets and byte counts are set, the extra tags replace the tags with the same number
+func rawTIFF(width, height, blockWidth, blockHeight int, tiled bool, blocks [][]byte, extra ...rawTag) []byte {
+	buf := []byte{'I', 'I', 42, 0, 0, 0, 0, 0}
+	var offsets, counts []uint32
+	for _, b := range blocks {
+		offsets = append(offsets, uint32(len(buf)))
+		counts = append(counts, uint32(len(b)))
+		buf = append(buf, b...)
+	}
+	if len(buf)%2 == 1 {
+		buf = append(buf, 0)
+	}
+	tags := map[uint16]rawTag{
+		256:   {256, 4, []uint32{uint32(width)}, nil},
+		257:   {257, 4, []uint32{uint32(height)}, nil},
+		258:   {258, 3, []uint32{8}, nil},
+		259:   {259, 3, []uint32{1}, nil},
+		262:   {262, 3, []uint32{1}, nil},
+		277:   {277, 3, []uint32{1}, nil},
+		339:   {339, 3, []uint32{1}, nil},
+		33550: {33550, 12, nil, []float64{1, 1, 0}},
+		33922: {33922, 12, nil, []float64{0, 0, 0, 0, float64(height), 0}},
+		34735: {34735, 3, []uint32{1, 1, 0, 0}, nil},
+	}
+	if tiled {
+		tags[322] = rawTag{322, 4, []uint32{uint32(blockWidth)}, nil}
+		tags[323] = rawTag{323, 4, []uint32{uint32(blockHeight)}, nil}
+		tags[324] = rawTag{324, 4, offsets, nil}
+		tags[325] = rawTag{325, 4, counts, nil}
+	} else {
+		tags[278] = rawTag{278, 4, []uint32{uint32(blockHeight)}, nil}
+		tags[273] = rawTag{273, 4, offsets, nil}
+		tags[279] = rawTag{279, 4, counts, nil}
+	}
+	for _, t := range extra {
+		tags[t.tag] = t
+	}
+	keys := make([]int, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, int(k))
+	}
+	sort.Ints(keys)
+
+	ifdOffset := len(buf)
+	binary.LittleEndian.PutUint32(buf[4:], uint32(ifdOffset))
+	extraOffset := ifdOffset + 2 + 12*len(keys) + 4
+	var ifd, values []byte
+	ifd = binary.LittleEndian.AppendUint16(ifd, uint16(len(keys)))
+	for _, k := range keys {
+		t := tags[uint16(k)]
+		var raw []byte
+		count := len(t.values)
+		for _, v := range t.doubles {
+			raw = binary.LittleEndian.AppendUint64(raw, math.Float64bits(v))
+			count++
+		}
+		for _, v := range t.values {
+			if t.typ == 3 {
+				raw = binary.LittleEndian.AppendUint16(raw, uint16(v))
+			} else {
+				raw = binary.LittleEndian.AppendUint32(raw, v)
+			}
+		}
+		ifd = binary.LittleEndian.AppendUint16(ifd, t.tag)
+		ifd = binary.LittleEndian.AppendUint16(ifd, t.typ)
+		ifd = binary.LittleEndian.AppendUint32(ifd, uint32(count))
+		if len(raw) <= 4 {
+			ifd = append(ifd, append(raw, make([]byte, 4-len(raw))...)...)
+		} else {
+			ifd = binary.LittleEndian.AppendUint32(ifd, uint32(extraOffset+len(values)))
+			values = append(values, raw...)
+		}
+	}
+	ifd = append(ifd, 0, 0, 0, 0)
+	return append(append(buf, ifd...), values...)
+}
+
+func writeRaw(t *testing.T, data []byte) string {
+	file := filepath.Join(t.TempDir(), "raw.tif")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+// newTiledFile is a 5x3 image cut into padded 4x2 tiles, the pixel is y*5+x+1 and the padding is 0xEE
+func newTiledFile(t *testing.T) string {
+	var tiles [][]byte
+	for ty := 0; ty < 2; ty++ {
+		for tx := 0; tx < 2; tx++ {
+			tile := make([]byte, 8)
+			for i := range tile {
+				x, y := tx*4+i%4, ty*2+i/4
+				tile[i] = 0xEE
+				if x < 5 && y < 3 {
+					tile[i] = byte(y*5 + x + 1)
+				}
+			}
+			tiles = append(tiles, tile)
+		}
+	}
+	return writeRaw(t, rawTIFF(5, 3, 4, 2, true, tiles))
+}
+
+func TestReadPaddedTiles(t *testing.T) {
+	g, err := GeoTiff.OpenGeoTif(newTiledFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range g.Data.Data {
+		if v != float64(i+1) {
+			t.Fatalf("Data is %v", g.Data.Data)
+		}
+	}
+
+	// 5x3 strips of 2 rows with the horizontal predictor
+	strips := [][]byte{{1, 1, 1, 1, 1, 6, 1, 1, 1, 1}, {11, 1, 1, 1, 1}}
+	g, err = GeoTiff.OpenGeoTif(writeRaw(t, rawTIFF(5, 3, 5, 2, false, strips, rawTag{317, 3, []uint32{2}, nil})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range g.Data.Data {
+		if v != float64(i+1) {
+			t.Fatalf("predictor Data is %v", g.Data.Data)
+		}
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	g, err := GeoTiff.OpenGeoTifHeader(newTiledFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.Data.Data != nil {
+		t.Fatal("the header has pixels")
+	}
+	it := g.Blocks()
+	if it.Len() != 4 {
+		t.Fatalf("%d blocks", it.Len())
+	}
+	windows := [][4]int{{0, 0, 4, 2}, {4, 0, 1, 2}, {0, 2, 4, 1}, {4, 2, 1, 1}}
+	for k := 0; it.Next(); k++ {
+		b := it.Block()
+		if [4]int{b.Col, b.Row, b.Width, b.Height} != windows[k] || b.Data[0] != float64(b.Row*5+b.Col+1) {
+			t.Errorf("block %d is %+v", k, b)
+		}
+	}
+	if err = it.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	double := func(b GeoTiff.Block) ([]float64, error) {
+		out := make([]float64, len(b.Data))
+		for i, v := range b.Data {
+			out[i] = v * 2
+		}
+		return out, nil
+	}
+	out := GeoTiff.NewGeoTifLike(g, 16, GeoTiff.SampleFormatUint)
+	out.Meta.Columns, out.Meta.Rows = 5, 3
+	if err = g.ProcessBlocks(3, double, out); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range out.Data.Data {
+		if v != float64(2*(i+1)) {
+			t.Fatalf("processed Data is %v", out.Data.Data)
+		}
+	}
+
+	// the blocks are written in order through the Writer
+	file := filepath.Join(t.TempDir(), "processed.tif")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := GeoTiff.NewWriter(f, out, GeoTiff.WithRowsPerStrip(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = g.ProcessBlocks(2, double, w); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	written, err := GeoTiff.OpenGeoTif(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range written.Data.Data {
+		if v != out.Data.Data[i] {
+			t.Fatalf("written Data is %v", written.Data.Data)
+		}
+	}
+
+	// a raster in memory is split into strips
+	if it = newZonalRaster().Blocks(); it.Len() != 1 || !it.Next() || len(it.Block().Data) != 16 {
+		t.Errorf("memory blocks are wrong, %v", it.Err())
+	}
+}
@@ -0,0 +1,115 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExpression(t *testing.T) {
+	cases := map[string]float64{
+		"1 + 2 * 3":                   7,
+		"-2^2":                        -4,
+		"2^3^2":                       512,
+		"(1 + 2) * 3":                 9,
+		"a > 1 ? a : -a":              2,
+		"where(a == 2 && !0, 10, 20)": 10,
+		"max(a, 5) % 3":               2,
+		"sqrt(16) + abs(-1) + 1e1":    15,
+	}
+	for source, want := range cases {
+		expr, err := GeoTiff.ParseExpression(source)
+		if err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		vars := make([]float64, len(expr.Variables))
+		for i := range vars {
+			vars[i] = 2
+		}
+		if got := expr.Eval(vars); got != want {
+			t.Errorf("%s = %v, want %v", source, got, want)
+		}
+	}
+	// the constants are lower case, E and NaN are variables
+	expr, err := GeoTiff.ParseExpression("E + e * 0 + isnan(nan) + NaN + pi / pi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expr.Variables) != 2 || expr.Variables[0] != "E" || expr.Variables[1] != "NaN" {
+		t.Fatalf("variables are %v", expr.Variables)
+	}
+	if got := expr.Eval([]float64{10, 100}); got != 112 {
+		t.Errorf("E + e * 0 + isnan(nan) + NaN + pi / pi = %v, want 112", got)
+	}
+	for _, source := range []string{"1 +", "(1", "foo(1)", "1 ? 2", "sqrt(1, 2)", "1 # 2"} {
+		if _, err := GeoTiff.ParseExpression(source); err == nil {
+			t.Errorf("%s should fail", source)
+		}
+	}
+}
+
+func TestCalcNDVI(t *testing.T) {
+	transform := [6]float64{500000, 30, 0, 4000000, 0, -30}
+	red := newRaster(16, GeoTiff.SampleFormatUint, 3, transform, 10, 20, 0, 40, 50, 60)
+	nir := newRaster(16, GeoTiff.SampleFormatUint, 3, transform, 30, 20, 30, 40, 150, 0)
+	inputs := map[string]*GeoTiff.GeoTif{"B3": red, "B4": nir}
+	out, err := GeoTiff.Calc("(B4-B3)/(B4+B3)", inputs, GeoTiff.WithCalcBlockRows(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{0.5, 0, -9999, 0, 0.5, -9999}
+	for i, v := range want {
+		if math.Abs(out.Data.Data[i]-v) > 1e-6 {
+			t.Errorf("Data[%d] is %v, want %v", i, out.Data.Data[i], v)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "ndvi.tif")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = GeoTiff.CalcTo(f, "(B4-B3)/(B4+B3)", inputs); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	geo, err := GeoTiff.OpenGeoTif(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range want {
+		if math.Abs(geo.Data.Data[i]-v) > 1e-6 {
+			t.Errorf("file Data[%d] is %v, want %v", i, geo.Data.Data[i], v)
+		}
+	}
+
+	// the inputs opened by OpenGeoTifHeader are read strip by strip, their Data is not filled
+	headers := map[string]*GeoTiff.GeoTif{}
+	for name, band := range inputs {
+		bandFile := filepath.Join(t.TempDir(), name+".tif")
+		if err = band.Save(bandFile, GeoTiff.WithRowsPerStrip(1)); err != nil {
+			t.Fatal(err)
+		}
+		if headers[name], err = GeoTiff.OpenGeoTifHeader(bandFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if out, err = GeoTiff.Calc("(B4-B3)/(B4+B3)", headers, GeoTiff.WithCalcBlockRows(1)); err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range want {
+		if math.Abs(out.Data.Data[i]-v) > 1e-6 {
+			t.Errorf("header Data[%d] is %v, want %v", i, out.Data.Data[i], v)
+		}
+	}
+	if headers["B3"].Data.Data != nil {
+		t.Error("the pixels of the input are read into Data")
+	}
+
+	nir.Transform.Data[0] += 30
+	if _, err = GeoTiff.Calc("B4-B3", inputs); err == nil {
+		t.Error("inputs on different grids should fail")
+	}
+}
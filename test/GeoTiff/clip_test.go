@@ -0,0 +1,85 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"image/color"
+	"testing"
+)
+
+func TestClip(t *testing.T) {
+	g := newZonalRaster()
+	out, err := g.Clip(GeoTiff.Bounds{MinX: 0.5, MinY: 1.2, MaxX: 2, MaxY: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{5, 6, 9, 9}
+	if out.Meta.Columns != 2 || out.Meta.Rows != 2 || out.Transform.Data[0] != 0 || out.Transform.Data[3] != 3 {
+		t.Fatalf("grid is %dx%d %v", out.Meta.Columns, out.Meta.Rows, out.Transform.Data)
+	}
+	for i, v := range want {
+		if out.Data.Data[i] != v {
+			t.Fatalf("Data is %v, want %v", out.Data.Data, want)
+		}
+	}
+	if _, err = g.Clip(GeoTiff.Bounds{MinX: 10, MinY: 10, MaxX: 11, MaxY: 11}); err == nil {
+		t.Error("bbox out of the raster should fail")
+	}
+}
+
+func TestClipByPolygon(t *testing.T) {
+	g := newZonalRaster()
+	// the triangle covers the centers of (0, 0), (1, 0), (0, 1)
+	fc, err := GeoTiff.ParseGeoJSON([]byte(`{"type":"Polygon","coordinates":[[[0,4],[2.2,4],[0,1.8],[0,4]]]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := g.ClipByPolygon(fc, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{1, 2, 255, 5, 255, 255, 255, 255, 255}
+	if out.Meta.Columns != 3 || out.Meta.Rows != 3 {
+		t.Fatalf("size is %dx%d", out.Meta.Columns, out.Meta.Rows)
+	}
+	for i, v := range want {
+		if out.Data.Data[i] != v {
+			t.Fatalf("Data is %v, want %v", out.Data.Data, want)
+		}
+	}
+	if out, err = g.ClipByPolygon(fc, false); err != nil {
+		t.Fatal(err)
+	}
+	if out.Meta.Columns != 4 || out.Data.Data[15] != 255 || out.Data.Data[0] != 1 || g.Data.Data[15] != 16 {
+		t.Errorf("Data is %v", out.Data.Data)
+	}
+}
+
+// the window of a RGB raster is still RGB
+func TestClipRGB(t *testing.T) {
+	var strip []byte
+	for i := 0; i < 6; i++ {
+		strip = append(strip, byte(i*10), byte(i*10+1), byte(i*10+2))
+	}
+	g, err := GeoTiff.OpenGeoTif(writeRaw(t, rawTIFF(3, 2, 3, 2, false, [][]byte{strip},
+		rawTag{258, 3, []uint32{8, 8, 8}, nil}, rawTag{262, 3, []uint32{2}, nil}, rawTag{277, 3, []uint32{3}, nil})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := g.Clip(GeoTiff.Bounds{MinX: 1, MinY: 0, MaxX: 3, MaxY: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.Meta.Columns != 2 || out.Meta.Rows != 1 || out.Meta.PhotometricInterp != 2 || len(out.Meta.BitsPerSample) != 3 {
+		t.Fatalf("clip is %dx%d, photometric %d, bits %v", out.Meta.Columns, out.Meta.Rows, out.Meta.PhotometricInterp, out.Meta.BitsPerSample)
+	}
+	img, err := out.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the pixels 4 and 5 of the bottom row
+	for i, want := range []color.NRGBA{{40, 41, 42, 255}, {50, 51, 52, 255}} {
+		if c := color.NRGBAModel.Convert(img.At(i, 0)).(color.NRGBA); c != want {
+			t.Errorf("pixel %d is %v, want %v", i, c, want)
+		}
+	}
+}
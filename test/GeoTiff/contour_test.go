@@ -0,0 +1,99 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"math"
+	"testing"
+)
+
+// a 5x5 peak in the middle
+func newPeakRaster() *GeoTiff.GeoTif {
+	g := newRaster(32, GeoTiff.SampleFormatFloat, 5, [6]float64{0, 1, 0, 5, 0, -1},
+		0, 0, 0, 0, 0,
+		0, 1, 1, 1, 0,
+		0, 1, 4, 1, 0,
+		0, 1, 1, 1, 0,
+		0, 0, 0, 0, 0,
+	)
+	g.Meta.NodataValue = "-9999"
+	return g
+}
+
+func TestContour(t *testing.T) {
+	g := newPeakRaster()
+	fc, err := g.Contour(GeoTiff.WithContourInterval(2, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 1 || fc.Features[0].Properties["elev"] != 2.0 {
+		t.Fatalf("features are %+v", fc.Features)
+	}
+	lines, err := fc.Features[0].Geometry.Lines()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(lines) != 1 || lines[0][0] != lines[0][len(lines[0])-1] {
+		t.Fatalf("contour 2 should be one closed line, but %v", lines)
+	}
+	// the line crosses the middle of 1 and 4, 1/3 pixel away from the peak at (2.5, 2.5)
+	for _, p := range lines[0] {
+		if p[0] < 1.8 || p[0] > 3.2 || p[1] < 1.8 || p[1] > 3.2 {
+			t.Errorf("point %v is out of the range", p)
+		}
+	}
+
+	g.Data.Data[12] = -9999
+	if fc, err = g.Contour(GeoTiff.WithContourLevels(2)); err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 0 {
+		t.Errorf("the peak is nodata, but got %+v", fc.Features)
+	}
+}
+
+// the levels of a small interval are base + k*interval, 0.1 added 10 times is not 1
+func TestContourIntervalLevels(t *testing.T) {
+	fc, err := newPeakRaster().Contour(GeoTiff.WithContourInterval(0.1, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, f := range fc.Features {
+		v := f.Properties["elev"].(float64)
+		if k := math.Round(v / 0.1); v != k*0.1 {
+			t.Errorf("level %v is not %v*0.1", v, k)
+		}
+		found = found || v == 2
+	}
+	if !found {
+		t.Error("there is no contour at 2")
+	}
+}
+
+func TestContourPolygons(t *testing.T) {
+	g := newPeakRaster()
+	fc, err := g.ContourPolygons(GeoTiff.WithContourLevels(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 2 {
+		t.Fatalf("require 2 bands, but got %d", len(fc.Features))
+	}
+	low, err := fc.Features[0].Geometry.Polygons()
+	if err != nil {
+		t.Fatal(err)
+	}
+	high, err := fc.Features[1].Geometry.Polygons()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(low) != 1 || len(low[0]) != 2 {
+		t.Errorf("the low band should be one polygon with a hole, but %v", low)
+	}
+	if len(high) != 1 || len(high[0]) != 1 {
+		t.Errorf("the high band should be one polygon, but %v", high)
+	}
+	if fc.Features[1].Properties["elev_min"] != 2.0 || fc.Features[1].Properties["elev_max"] != 4.0 {
+		t.Errorf("properties are %v", fc.Features[1].Properties)
+	}
+}
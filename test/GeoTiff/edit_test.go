@@ -0,0 +1,146 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTagEditor(t *testing.T) {
+	g := GeoTiff.NewGeoTif(4, 3, 16, GeoTiff.SampleFormatInt)
+	g.Transform.Data = [6]float64{100, 10, 0, 200, 0, -10}
+	g.Meta.EPSGCode = 32650
+	g.Meta.NodataValue = "-9999"
+	g.Metadata.Items["AREA"] = "Xinjiang"
+	for i := range g.Data.Data {
+		g.Data.Data[i] = float64(i * 100)
+	}
+	file := filepath.Join(t.TempDir(), "edit.tif")
+	if err := g.Save(file, GeoTiff.WithRowsPerStrip(2)); err != nil {
+		t.Fatal(err)
+	}
+	before, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more tags, the IFD is appended
+	e, err := GeoTiff.OpenTagEditor(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = e.SetEPSG(32645); err != nil {
+		t.Fatal(err)
+	}
+	e.SetGeoKeyASCII(GeoTiff.PCSCitationGeoKey, "WGS 84 / UTM zone 45N")
+	if err = e.SetNodata("-1"); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.SetASCII(GeoTiff.Software, "gotool"); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.SetLong(GeoTiff.StripOffsets, 0); err == nil {
+		t.Error("StripOffsets is changed")
+	}
+	if err = e.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Close(); err != nil {
+		t.Fatal(err)
+	}
+	after, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) <= len(before) {
+		t.Errorf("the IFD is not appended, %d bytes", len(after))
+	}
+	geo, err := GeoTiff.OpenGeoTif(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if geo.Meta.EPSGCode != 32645 || strings.TrimRight(geo.Meta.NodataValue, "\x00") != "-1" {
+		t.Errorf("EPSG %d nodata %q", geo.Meta.EPSGCode, geo.Meta.NodataValue)
+	}
+	if geo.Transform.Data != g.Transform.Data || geo.Metadata.Items["AREA"] != "Xinjiang" {
+		t.Errorf("transform %v metadata %v", geo.Transform.Data, geo.Metadata.Items)
+	}
+	if !reflect.DeepEqual(geo.Data.Data, g.Data.Data) {
+		t.Errorf("Data is %v", geo.Data.Data)
+	}
+
+	// less tags, the IFD is rewritten where it is
+	if e, err = GeoTiff.OpenTagEditor(file); err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, attribute := range e.Attributes() {
+		found = found || attribute.Tag == GeoTiff.Software && attribute.GeoAttributeValue.ASCII == "gotool\x00"
+	}
+	if !found {
+		t.Error("Software is not saved")
+	}
+	if err = e.SetMetadata(GeoTiff.NewGDALMetadata()); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.SetNodata(""); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Save(); err != nil {
+		t.Fatal(err)
+	}
+	e.Close()
+	if info, err := os.Stat(file); err != nil || info.Size() != int64(len(after)) {
+		t.Errorf("the file is grown %v", err)
+	}
+	if geo, err = GeoTiff.OpenGeoTif(file); err != nil {
+		t.Fatal(err)
+	}
+	if geo.Meta.NodataValue != "" || !geo.Metadata.IsEmpty() || geo.Meta.EPSGCode != 32645 {
+		t.Errorf("nodata %q metadata %v EPSG %d", geo.Meta.NodataValue, geo.Metadata, geo.Meta.EPSGCode)
+	}
+	if !reflect.DeepEqual(geo.Data.Data, g.Data.Data) {
+		t.Errorf("Data is %v", geo.Data.Data)
+	}
+}
+
+// the GeoKey whose value is in another tag (4096 in ModelPixelScaleTag) is not parsed, Save and TagEditor drop it
+func TestUnknownGeoKeyLocation(t *testing.T) {
+	file := writeRaw(t, rawTIFF(2, 2, 2, 2, false, [][]byte{{1, 2, 3, 4}},
+		rawTag{34735, 3, []uint32{1, 1, 0, 3, 1024, 0, 1, 1, 3072, 0, 1, 32650, 4096, 33550, 1, 0}, nil}))
+	g, err := GeoTiff.OpenGeoTif(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := filepath.Join(t.TempDir(), "saved.tif")
+	if err = g.Save(saved); err != nil {
+		t.Fatal(err)
+	}
+	if g, err = GeoTiff.OpenGeoTif(saved); err != nil {
+		t.Fatal(err)
+	}
+	if g.Meta.EPSGCode != 32650 {
+		t.Errorf("EPSG of the saved tif is %d", g.Meta.EPSGCode)
+	}
+
+	e, err := GeoTiff.OpenTagEditor(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = e.SetEPSG(32645); err != nil {
+		t.Fatal(err)
+	}
+	if err = e.Save(); err != nil {
+		t.Fatal(err)
+	}
+	e.Close()
+	if g, err = GeoTiff.OpenGeoTif(file); err != nil {
+		t.Fatal(err)
+	}
+	if g.Meta.EPSGCode != 32645 {
+		t.Errorf("EPSG of the edited tif is %d", g.Meta.EPSGCode)
+	}
+}
@@ -0,0 +1,67 @@
+package GeoTiff
+
+import (
+	"errors"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestGeoErrorWrap(t *testing.T) {
+	gEC := GeoTiff.NewGeoErrorCreator("f")
+	err := error(gEC(GeoTiff.WithError(os.ErrNotExist), GeoTiff.WithErrorText("x"), GeoTiff.WithMsg("m")))
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatal("WithErrorText drops the wrapped error")
+	}
+	if got, want := err.Error(), "[f] m: x: file does not exist"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	outer := error(GeoTiff.NewGeoErrorCreator("g")(GeoTiff.WithError(err)))
+	var ge GeoTiff.GeoError
+	if !errors.As(outer, &ge) || ge.Function != "g" || !errors.Is(outer, os.ErrNotExist) {
+		t.Fatalf("the chain is broken: %v", outer)
+	}
+	kind := error(gEC(GeoTiff.WithKind(GeoTiff.ErrCorrupt)))
+	if !errors.Is(GeoTiff.NewGeoErrorCreator("g")(GeoTiff.WithError(kind)), GeoTiff.ErrCorrupt) || errors.Is(kind, GeoTiff.ErrTooLarge) {
+		t.Fatal("the kind is not matched")
+	}
+	if got, want := kind.Error(), "[f] corrupt tif"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestErrorKinds(t *testing.T) {
+	block := [][]byte{{1, 2, 3}}
+	data := rawTIFF(3, 1, 3, 1, false, block)
+	if _, err := openBytes(t, data[:len(data)-3]); !errors.Is(err, GeoTiff.ErrCorrupt) {
+		t.Errorf("truncated file: %v", err)
+	}
+	// 7 is JPEG
+	_, err := openBytes(t, rawTIFF(3, 1, 3, 1, false, block, rawTag{259, 3, []uint32{7}, nil}))
+	if !errors.Is(err, GeoTiff.ErrUnsupportedCompression) {
+		t.Errorf("JPEG: %v", err)
+	} else if !strings.Contains(err.Error(), "[OpenGeoTif]") || !strings.Contains(err.Error(), "block 0") {
+		t.Errorf("the function and the message are not in %q", err)
+	}
+	if _, err = openBytes(t, rawTIFF(3, 1, 3, 1, false, block, rawTag{339, 3, []uint32{3}, nil})); !errors.Is(err, GeoTiff.ErrUnsupportedSampleFormat) {
+		t.Errorf("8 bits float: %v", err)
+	}
+	if _, err = openBytes(t, rawTIFF(3, 1, 3, 1, false, block, rawTag{34735, 3, []uint32{1, 1, 0, 1, 1024, 34736, 1, 0}, nil})); !errors.Is(err, GeoTiff.ErrMissingTag) {
+		t.Errorf("no GeoDoubleParams: %v", err)
+	}
+	if _, err = openBytes(t, rawTIFF(1<<11, 1<<11, 1<<11, 1<<11, true, block)); !errors.Is(err, GeoTiff.ErrTooLarge) {
+		t.Errorf("too many pixels: %v", err)
+	}
+	g, err := openBytes(t, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = g.Sample(3, 0); !errors.Is(err, GeoTiff.ErrOutOfRange) {
+		t.Errorf("sample: %v", err)
+	}
+	g.Meta.EPSGCode = 2000
+	if _, err = GeoTiff.NewTiler(g); !errors.Is(err, GeoTiff.ErrUnsupportedCRS) {
+		t.Errorf("tiler: %v", err)
+	}
+}
@@ -0,0 +1,122 @@
+package GeoTiff
+
+import (
+	"encoding/binary"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// malformedSeeds are the valid tifs the fuzzer starts from
+func malformedSeeds(t testing.TB) [][]byte {
+	strip := rawTIFF(3, 2, 3, 1, false, [][]byte{{1, 2, 3}, {4, 5, 6}})
+	tiled := rawTIFF(5, 3, 4, 2, true, [][]byte{make([]byte, 8), make([]byte, 8), make([]byte, 8), make([]byte, 8)})
+	predictor := rawTIFF(3, 1, 3, 1, false, [][]byte{{1, 1, 1}}, rawTag{317, 3, []uint32{2}, nil})
+	packBits := rawTIFF(4, 1, 4, 1, false, [][]byte{{0xFD, 7}}, rawTag{259, 3, []uint32{32773}, nil})
+	g := GeoTiff.NewGeoTif(4, 3, 32, 3)
+	g.Transform.Data = [6]float64{10, 0.5, 0, 20, 0, -0.5}
+	file := filepath.Join(t.TempDir(), "seed.tif")
+	if err := g.Save(file, GeoTiff.WithCompression(GeoTiff.CompressionDeflate)); err != nil {
+		t.Fatal(err)
+	}
+	written, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return [][]byte{strip, tiled, predictor, packBits, written}
+}
+
+func openBytes(t testing.TB, data []byte) (*GeoTiff.GeoTif, error) {
+	file := filepath.Join(t.TempDir(), "fuzz.tif")
+	if err := os.WriteFile(file, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return GeoTiff.OpenGeoTif(file, GeoTiff.WithMaxPixels(1<<20))
+}
+
+func FuzzOpenGeoTif(f *testing.F) {
+	for _, seed := range malformedSeeds(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// any error is fine, a panic is not
+		_, _ = openBytes(t, data)
+	})
+}
+
+// TestMalformedSeeds cut and corrupt every seed at every byte, nothing should panic
+func TestMalformedSeeds(t *testing.T) {
+	for _, seed := range malformedSeeds(t) {
+		if _, err := openBytes(t, seed); err != nil {
+			t.Fatalf("the seed should be valid: %v", err)
+		}
+		for i := 0; i < len(seed); i++ {
+			if _, err := openBytes(t, seed[:i]); err == nil {
+				t.Fatalf("the file cut at %d of %d bytes is opened", i, len(seed))
+			}
+			corrupt := append([]byte(nil), seed...)
+			corrupt[i] ^= 0xFF
+			_, _ = openBytes(t, corrupt)
+		}
+	}
+}
+
+func TestMalformedTags(t *testing.T) {
+	block := [][]byte{{1, 2, 3}}
+	tests := map[string][]byte{
+		// 2^30 LONG values are 4 GB
+		"huge count": rawTIFF(3, 1, 3, 1, false, block, rawTag{273, 4, []uint32{8, 0}, nil}),
+		// the directory says 5 keys but has none
+		"short geokey directory": rawTIFF(3, 1, 3, 1, false, block, rawTag{34735, 3, []uint32{1, 1, 0, 5}, nil}),
+		// the double key is after the end of GeoDoubleParams
+		"geokey out of params": rawTIFF(3, 1, 3, 1, false, block,
+			rawTag{34735, 3, []uint32{1, 1, 0, 1, 2057, 34736, 1, 9}, nil},
+			rawTag{34736, 12, nil, []float64{6378137, 0}}),
+		"missing offsets":   rawTIFF(3, 2, 3, 1, false, block),
+		"no width":          rawTIFF(0, 1, 3, 1, false, block),
+		"too many pixels":   rawTIFF(1<<11, 1<<11, 1<<11, 1<<11, true, block),
+		"block out of file": rawTIFF(3, 1, 3, 1, false, block, rawTag{279, 4, []uint32{1 << 20}, nil}),
+		"cut packbits":      rawTIFF(3, 1, 3, 1, false, [][]byte{{0x05, 1}}, rawTag{259, 3, []uint32{32773}, nil}),
+	}
+	for name, data := range tests {
+		if name == "huge count" {
+			// set the count of StripOffsets to 2^30 with the value still at offset 8
+			data = setCount(t, data, 273, 1<<30)
+		}
+		if _, err := openBytes(t, data); err == nil {
+			t.Errorf("%s: the file is opened", name)
+		} else if _, ok := err.(GeoTiff.GeoError); !ok {
+			t.Errorf("%s: the error is %T, not GeoError", name, err)
+		}
+	}
+}
+
+// setCount change the count of the tag in the IFD of a rawTIFF
+func setCount(t *testing.T, data []byte, tag uint16, count uint32) []byte {
+	data = append([]byte(nil), data...)
+	ifd := int(binary.LittleEndian.Uint32(data[4:]))
+	n := int(binary.LittleEndian.Uint16(data[ifd:]))
+	for i := 0; i < n; i++ {
+		entry := data[ifd+2+12*i:]
+		if binary.LittleEndian.Uint16(entry) == tag {
+			binary.LittleEndian.PutUint32(entry[4:], count)
+			return data
+		}
+	}
+	t.Fatalf("tag %d is not found", tag)
+	return nil
+}
+
+func TestPackBitsRuns(t *testing.T) {
+	// a run of 3 x 7, a no operation and a literal of 1 byte
+	data := rawTIFF(4, 1, 4, 1, false, [][]byte{{0xFE, 7, 0x80, 0x00, 9}}, rawTag{259, 3, []uint32{32773}, nil})
+	g, err := openBytes(t, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []float64{7, 7, 7, 9}; !reflect.DeepEqual(g.Data.Data, want) {
+		t.Fatalf("got %v, want %v", g.Data.Data, want)
+	}
+}
@@ -0,0 +1,77 @@
+package GeoTiff
+
+import (
+	"errors"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"testing"
+)
+
+// the in-memory methods read the pixels of the tif opened by OpenGeoTifHeader
+func TestHeaderOnly(t *testing.T) {
+	file := newTiledFile(t)
+	open := func() *GeoTiff.GeoTif {
+		g, err := GeoTiff.OpenGeoTifHeader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { g.Close() })
+		return g
+	}
+
+	w, err := open().Window(1, 1, 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Data.Data[0] != 7 || w.Data.Data[3] != 13 {
+		t.Errorf("Window = %v", w.Data.Data)
+	}
+	c, err := open().Clip(open().Bounds())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(c.Data.Data) != 15 || c.Data.Data[14] != 15 {
+		t.Errorf("Clip = %v", c.Data.Data)
+	}
+	s, err := open().Slope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Data.Data) != 15 {
+		t.Errorf("Slope has %d values", len(s.Data.Data))
+	}
+	fc, err := open().Polygonize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 15 {
+		t.Errorf("Polygonize got %d features", len(fc.Features))
+	}
+	img, err := open().Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, g, b, _ := img.At(4, 2).RGBA()
+	if r == 0 && g == 0 && b == 0 {
+		t.Error("Image().At(4, 2) is black")
+	}
+	if v, ok := open().At(0, 0); ok || v != 0 {
+		t.Errorf("At of a header = %v %v", v, ok)
+	}
+
+	// a raster which has no file can not be read
+	empty := GeoTiff.NewGeoTif(2, 2, 8, 1)
+	empty.Data.Data = nil
+	if _, err := empty.Window(0, 0, 1, 1); !errors.Is(err, GeoTiff.ErrOutOfRange) {
+		t.Errorf("Window of an empty raster: %v", err)
+	}
+	if _, err := empty.Polygonize(); !errors.Is(err, GeoTiff.ErrOutOfRange) {
+		t.Errorf("Polygonize of an empty raster: %v", err)
+	}
+
+	// the pixels of a closed header can not be read
+	closed := open()
+	closed.Close()
+	if _, err := closed.Window(0, 0, 1, 1); err == nil {
+		t.Error("Window of a closed header should fail")
+	}
+}
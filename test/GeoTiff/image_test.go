@@ -0,0 +1,71 @@
+package GeoTiff
+
+import (
+	"bytes"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"testing"
+)
+
+func TestBandImage(t *testing.T) {
+	g := newZonalRaster()
+	band, err := g.BandImage(1, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err = png.Encode(&buf, band); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.Gray16Model.Convert(img.At(3, 3)).(color.Gray16); c.Y != 65535 {
+		t.Errorf("max is %v", c)
+	}
+	if c := color.Gray16Model.Convert(img.At(0, 0)).(color.Gray16); c.Y != 0 {
+		t.Errorf("min is %v", c)
+	}
+	// nodata
+	if c := color.Gray16Model.Convert(img.At(2, 2)).(color.Gray16); c.Y != 0 {
+		t.Errorf("nodata is %v", c)
+	}
+	gray, err := g.Image()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := gray.At(1, 0).(color.Gray16); c.Y != 2*257 {
+		t.Errorf("8 bits gray is %v", c)
+	}
+
+	// the pixels which are not in Data is an error
+	g.Data.Data = g.Data.Data[:3]
+	if _, err = g.Image(); err == nil {
+		t.Error("the image of a raster without pixels has no error")
+	}
+	if _, err = g.BandImage(1, 16); err == nil {
+		t.Error("the band image of a raster without pixels has no error")
+	}
+}
+
+func TestRGBImage(t *testing.T) {
+	r := newZonalRaster()
+	g := newZonalRaster()
+	b := newZonalRaster()
+	img, err := GeoTiff.NewRGBImage(r, g, b, [3]float64{0, 0, 16}, [3]float64{16, 16, 0})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), img, image.Point{}, draw.Src)
+	if c := dst.RGBAAt(3, 3); c.R != 255 || c.B != 0 || c.A != 255 {
+		t.Errorf("color is %v", c)
+	}
+	if c := dst.RGBAAt(2, 2); c.A != 0 {
+		t.Errorf("nodata should be transparent, but %v", c)
+	}
+}
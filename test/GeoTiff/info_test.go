@@ -0,0 +1,86 @@
+package GeoTiff
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestInfo(t *testing.T) {
+	info, err := newLonLatRaster().Info(GeoTiff.WithInfoStats(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Columns != 100 || info.Rows != 100 || info.EPSG != 4326 || !info.Geographic || info.Compression != "NONE" {
+		t.Errorf("wrong info %+v", info)
+	}
+	if info.LonLatBounds == nil || *info.LonLatBounds != [4]float64{10, 45, 11, 46} {
+		t.Errorf("lon/lat bounds %v", info.LonLatBounds)
+	}
+	if len(info.Bands) != 1 || info.Bands[0].Type != "Byte" || info.Bands[0].ColorInterp != "Gray" {
+		t.Fatalf("wrong bands %+v", info.Bands)
+	}
+	s := info.Bands[0].Stats
+	// the right half is 51~100 in every row
+	if s == nil || s.Min != 51 || s.Max != 100 || s.Mean != 75.5 || s.Count != 5000 || s.NodataCount != 5000 ||
+		math.Abs(s.StdDev-math.Sqrt((50*50-1)/12.0)) > 1e-9 {
+		t.Errorf("wrong stats %+v", s)
+	}
+	data, err := info.JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded GeoTiff.Info
+	if err = json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Bands[0].Stats.Mean != 75.5 || *decoded.Nodata != 0 {
+		t.Errorf("wrong json %s", data)
+	}
+	text := info.String()
+	for _, want := range []string{"Size is 100, 100", "EPSG:4326", "Band 1 Block=100x100 Type=Byte, ColorInterp=Gray", "NoData Value=0", "Mean=75.500"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("%q is not in\n%s", want, text)
+		}
+	}
+}
+
+func TestInfoOverviews(t *testing.T) {
+	data := rawTIFF(4, 2, 4, 2, true, [][]byte{make([]byte, 8)})
+	// append an overview IFD of 2x1 and link it after the first IFD
+	ifd := int(binary.LittleEndian.Uint32(data[4:]))
+	n := int(binary.LittleEndian.Uint16(data[ifd:]))
+	binary.LittleEndian.PutUint32(data[ifd+2+12*n:], uint32(len(data)))
+	overview := binary.LittleEndian.AppendUint16(nil, 3)
+	for _, e := range [][3]uint32{{254, 4, 1}, {256, 4, 2}, {257, 4, 1}} {
+		overview = binary.LittleEndian.AppendUint16(overview, uint16(e[0]))
+		overview = binary.LittleEndian.AppendUint16(overview, uint16(e[1]))
+		overview = binary.LittleEndian.AppendUint32(overview, 1)
+		overview = binary.LittleEndian.AppendUint32(overview, e[2])
+	}
+	// the overview points back to the first IFD, the loop is stopped
+	data = append(data, binary.LittleEndian.AppendUint32(overview, uint32(ifd))...)
+	g, err := GeoTiff.OpenGeoTifHeader(writeRaw(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := g.Info(GeoTiff.WithInfoStats(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Tiled || info.Blocks != 1 || info.BlockWidth != 4 {
+		t.Errorf("wrong layout %+v", info)
+	}
+	if len(info.Overviews) != 1 || info.Overviews[0] != (GeoTiff.OverviewInfo{Columns: 2, Rows: 1}) {
+		t.Errorf("wrong overviews %v", info.Overviews)
+	}
+	if s := info.Bands[0].Stats; s == nil || s.Count != 8 || s.Max != 0 {
+		t.Errorf("wrong stats %+v", s)
+	}
+	if !strings.Contains(info.String(), "Overviews: 2x1") {
+		t.Errorf("no overviews in\n%s", info.String())
+	}
+}
@@ -0,0 +1,153 @@
+package GeoTiff
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClose(t *testing.T) {
+	file := newTiledFile(t)
+	g, err := GeoTiff.OpenGeoTif(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append([]float64(nil), g.Data.Data...)
+	if err = g.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = g.Close(); err != nil {
+		t.Fatalf("close twice: %v", err)
+	}
+	if !reflect.DeepEqual(g.Data.Data, data) {
+		t.Error("Data is changed by Close")
+	}
+
+	h, err := GeoTiff.OpenGeoTifHeader(file, GeoTiff.WithMmap(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v, err := h.Sample(4, 2); err != nil || v != 15 {
+		t.Fatalf("sample %v %v", v, err)
+	}
+	if err = h.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.Sample(0, 0); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("sample after Close: %v", err)
+	}
+	if err = h.ReadData(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("ReadData after Close: %v", err)
+	}
+}
+
+func TestContextAndProgress(t *testing.T) {
+	file := newTiledFile(t)
+	var done []int
+	g, err := GeoTiff.OpenGeoTif(file, GeoTiff.WithProgress(func(n, total int) {
+		if total != 4 {
+			t.Errorf("total is %d", total)
+		}
+		done = append(done, n)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer g.Close()
+	if !reflect.DeepEqual(done, []int{1, 2, 3, 4}) {
+		t.Errorf("progress %v", done)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = GeoTiff.OpenGeoTifContext(ctx, file); !errors.Is(err, context.Canceled) {
+		t.Errorf("open with a cancelled context: %v", err)
+	}
+
+	// cancel after the first block
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	h, err := GeoTiff.OpenGeoTifHeader(file, GeoTiff.WithProgress(func(n, total int) {
+		if n == 1 {
+			cancel()
+		}
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer h.Close()
+	if err = h.ReadDataContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("ReadDataContext: %v", err)
+	}
+	if h.Data.Data != nil {
+		t.Error("Data is set by the cancelled read")
+	}
+	out := GeoTiff.NewGeoTifLike(h, 8, GeoTiff.SampleFormatUint)
+	if err = h.ProcessBlocksContext(ctx, 2, nil, out); !errors.Is(err, context.Canceled) {
+		t.Errorf("ProcessBlocksContext: %v", err)
+	}
+	if _, err = h.InfoContext(ctx, GeoTiff.WithInfoStats(true)); !errors.Is(err, context.Canceled) {
+		t.Errorf("InfoContext: %v", err)
+	}
+
+	// the other openers stop reading the pixels after the first block
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zipFile := filepath.Join(t.TempDir(), "tiled.zip")
+	zf, err := os.Create(zipFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	if w, err := zw.CreateHeader(&zip.FileHeader{Name: "tiled.tif", Method: zip.Store}); err != nil {
+		t.Fatal(err)
+	} else if _, err = w.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zf.Close()
+	openers := map[string]func(ctx context.Context, opt GeoTiff.OpenOptions) (*GeoTiff.GeoTif, error){
+		"OpenReaderContext": func(ctx context.Context, opt GeoTiff.OpenOptions) (*GeoTiff.GeoTif, error) {
+			return GeoTiff.OpenReaderContext(ctx, bytes.NewReader(data), int64(len(data)), opt)
+		},
+		"OpenBytesContext": func(ctx context.Context, opt GeoTiff.OpenOptions) (*GeoTiff.GeoTif, error) {
+			return GeoTiff.OpenBytesContext(ctx, data, opt)
+		},
+		"OpenFSContext": func(ctx context.Context, opt GeoTiff.OpenOptions) (*GeoTiff.GeoTif, error) {
+			return GeoTiff.OpenFSContext(ctx, os.DirFS(filepath.Dir(file)), filepath.Base(file), opt)
+		},
+		"OpenZipContext": func(ctx context.Context, opt GeoTiff.OpenOptions) (*GeoTiff.GeoTif, error) {
+			return GeoTiff.OpenZipContext(ctx, zipFile, "tiled.tif", opt)
+		},
+	}
+	for name, open := range openers {
+		ctx, cancel := context.WithCancel(context.Background())
+		g, err := open(ctx, GeoTiff.WithProgress(func(n, total int) {
+			if n == 1 {
+				cancel()
+			}
+		}))
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: %v", name, err)
+		}
+		if g != nil {
+			g.Close()
+		}
+		if g, err = open(context.Background(), GeoTiff.WithProgress(nil)); err != nil || g.Data.Data[14] != 15 {
+			t.Errorf("%s without cancel: %v", name, err)
+		} else {
+			g.Close()
+		}
+		cancel()
+	}
+}
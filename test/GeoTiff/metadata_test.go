@@ -0,0 +1,79 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestGDALMetadataRoundTrip(t *testing.T) {
+	g := GeoTiff.NewGeoTif(4, 3, 16, GeoTiff.SampleFormatInt)
+	g.Transform.Data = [6]float64{100, 10, 0, 200, 0, -10}
+	g.Meta.EPSGCode = 32650
+	g.Meta.NodataValue = "-9999"
+	for i := range g.Data.Data {
+		g.Data.Data[i] = float64(i * 100)
+	}
+	g.Data.Data[5] = -9999
+	g.Metadata.Items["AREA"] = "Xinjiang"
+	band := g.Metadata.Band(0)
+	band.Description = "NDVI"
+	band.Scale = 0.0001
+	band.Unit = "1"
+	band.Items["SENSOR"] = "MODIS"
+
+	file := filepath.Join(t.TempDir(), "metadata.tif")
+	if err := g.Save(file, GeoTiff.WithCompression(GeoTiff.CompressionDeflate), GeoTiff.WithRowsPerStrip(2)); err != nil {
+		t.Fatal(err)
+	}
+	geo, err := GeoTiff.OpenGeoTif(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if geo.Metadata.Items["AREA"] != "Xinjiang" {
+		t.Errorf("dataset item is %v", geo.Metadata.Items)
+	}
+	b, ok := geo.Metadata.Bands[0]
+	if !ok {
+		t.Fatal("band metadata is lost")
+	}
+	if b.Description != "NDVI" || b.Scale != 0.0001 || b.Offset != 0 || b.Unit != "1" || b.Items["SENSOR"] != "MODIS" {
+		t.Errorf("band metadata is %+v", b)
+	}
+	if geo.Meta.EPSGCode != 32650 {
+		t.Errorf("EPSG is %d", geo.Meta.EPSGCode)
+	}
+	if geo.Transform.Data != g.Transform.Data {
+		t.Errorf("transform is %v", geo.Transform.Data)
+	}
+	for i, v := range g.Data.Data {
+		if geo.Data.Data[i] != v {
+			t.Fatalf("Data[%d] is %v, want %v", i, geo.Data.Data[i], v)
+		}
+	}
+	physical := geo.PhysicalData(0)
+	if physical[5] != -9999 || math.Abs(physical[3]-0.03) > 1e-9 {
+		t.Errorf("physical data is %v", physical)
+	}
+}
+
+// NaN is written as nodata to the integer samples, or 0 without nodata
+func TestWriteNaN(t *testing.T) {
+	for nodata, want := range map[string]float64{"-9999": -9999, "": 0} {
+		g := GeoTiff.NewGeoTif(2, 1, 16, GeoTiff.SampleFormatInt)
+		g.Meta.NodataValue = nodata
+		g.Data.Data = []float64{math.NaN(), 7}
+		file := filepath.Join(t.TempDir(), "nan.tif")
+		if err := g.Save(file); err != nil {
+			t.Fatal(err)
+		}
+		geo, err := GeoTiff.OpenGeoTif(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if geo.Data.Data[0] != want || geo.Data.Data[1] != 7 {
+			t.Errorf("nodata %q: Data is %v", nodata, geo.Data.Data)
+		}
+	}
+}
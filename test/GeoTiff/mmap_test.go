@@ -0,0 +1,39 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMmap(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "mmap.tif")
+	src := newZonalRaster()
+	if err := src.Save(file, GeoTiff.WithRowsPerStrip(3)); err != nil {
+		t.Fatal(err)
+	}
+	g, err := GeoTiff.OpenGeoTif(file, GeoTiff.WithMmap(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range g.Data.Data {
+		if v != src.Data.Data[i] {
+			t.Fatalf("Data is %v", g.Data.Data)
+		}
+	}
+
+	// the predictor is undone on a copy of the mapped memory
+	strips := [][]byte{{1, 1, 1, 1, 1, 6, 1, 1, 1, 1}, {11, 1, 1, 1, 1}}
+	file = writeRaw(t, rawTIFF(5, 3, 5, 2, false, strips, rawTag{317, 3, []uint32{2}, nil}))
+	for k := 0; k < 2; k++ {
+		g, err = GeoTiff.OpenGeoTif(file, GeoTiff.WithMmap(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range g.Data.Data {
+			if v != float64(i+1) {
+				t.Fatalf("open %d: predictor Data is %v", k, g.Data.Data)
+			}
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMosaic(t *testing.T) {
+	// b overlaps the right column of a, c is below a
+	a := newRaster(16, GeoTiff.SampleFormatUint, 2, [6]float64{0, 10, 0, 20, 0, -10}, 1, 2, 3, 4)
+	b := newRaster(16, GeoTiff.SampleFormatUint, 2, [6]float64{10, 10, 0, 20, 0, -10}, 10, 20, 0, 40)
+	c := newRaster(16, GeoTiff.SampleFormatUint, 2, [6]float64{0, 10, 0, 0, 0, -10}, 5, 6, 7, 8)
+	cases := map[GeoTiff.MosaicRule][]float64{
+		GeoTiff.MosaicFirst: {1, 2, 20, 3, 4, 40, 5, 6, 0, 7, 8, 0},
+		GeoTiff.MosaicLast:  {1, 10, 20, 3, 4, 40, 5, 6, 0, 7, 8, 0},
+		GeoTiff.MosaicMin:   {1, 2, 20, 3, 4, 40, 5, 6, 0, 7, 8, 0},
+		GeoTiff.MosaicMax:   {1, 10, 20, 3, 4, 40, 5, 6, 0, 7, 8, 0},
+		GeoTiff.MosaicMean:  {1, 6, 20, 3, 4, 40, 5, 6, 0, 7, 8, 0},
+	}
+	for rule, want := range cases {
+		out, err := GeoTiff.Mosaic([]*GeoTiff.GeoTif{a, b, c}, GeoTiff.WithMosaicRule(rule), GeoTiff.WithMosaicBlockRows(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Meta.Columns != 3 || out.Meta.Rows != 4 || out.Transform.Data[0] != 0 || out.Transform.Data[3] != 20 {
+			t.Fatalf("grid is %dx%d %v", out.Meta.Columns, out.Meta.Rows, out.Transform.Data)
+		}
+		for i, v := range want {
+			if out.Data.Data[i] != v {
+				t.Errorf("rule %d: Data is %v, want %v", rule, out.Data.Data, want)
+				break
+			}
+		}
+	}
+
+	// the minimum does not depend on the order, b comes first here
+	for rule, want := range map[GeoTiff.MosaicRule]float64{GeoTiff.MosaicFirst: 10, GeoTiff.MosaicMin: 2} {
+		out, err := GeoTiff.Mosaic([]*GeoTiff.GeoTif{b, a, c}, GeoTiff.WithMosaicRule(rule))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Data.Data[1] != want {
+			t.Errorf("rule %d of b, a, c: the overlap is %v, want %v", rule, out.Data.Data[1], want)
+		}
+	}
+
+	file := filepath.Join(t.TempDir(), "mosaic.tif")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = GeoTiff.MosaicTo(f, []*GeoTiff.GeoTif{a, b, c}); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	geo, err := GeoTiff.OpenGeoTif(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if geo.Meta.Columns != 3 || geo.Data.Data[2] != 20 || geo.Data.Data[11] != 0 {
+		t.Errorf("file Data is %v", geo.Data.Data)
+	}
+
+	// the inputs opened by OpenGeoTifHeader are read strip by strip, their Data is not filled
+	headers := make([]*GeoTiff.GeoTif, 3)
+	for i, in := range []*GeoTiff.GeoTif{a, b, c} {
+		inFile := filepath.Join(t.TempDir(), "in.tif")
+		if err = in.Save(inFile, GeoTiff.WithRowsPerStrip(1)); err != nil {
+			t.Fatal(err)
+		}
+		if headers[i], err = GeoTiff.OpenGeoTifHeader(inFile); err != nil {
+			t.Fatal(err)
+		}
+	}
+	out, err := GeoTiff.Mosaic(headers, GeoTiff.WithMosaicRule(GeoTiff.MosaicMean), GeoTiff.WithMosaicBlockRows(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range cases[GeoTiff.MosaicMean] {
+		if out.Data.Data[i] != v {
+			t.Fatalf("header Data is %v, want %v", out.Data.Data, cases[GeoTiff.MosaicMean])
+		}
+	}
+	if headers[0].Data.Data != nil {
+		t.Error("the pixels of the input are read into Data")
+	}
+
+	b.Transform.Data[0] = 15
+	if _, err = GeoTiff.Mosaic([]*GeoTiff.GeoTif{a, b}); err == nil {
+		t.Error("inputs not aligned should fail")
+	}
+}
@@ -0,0 +1,94 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOrientation(t *testing.T) {
+	// the file is 3x2 in tiles of 2x2, the last tile is cut
+	//	1 2 3
+	//	4 5 6
+	tiles := [][]byte{{1, 2, 4, 5}, {3, 0xEE, 6, 0xEE}}
+	scale := rawTag{33550, 12, nil, []float64{10, 10, 0}}
+	tiepoint := rawTag{33922, 12, nil, []float64{0, 0, 0, 100, 200, 0}}
+	wants := map[uint32][]float64{
+		1: {1, 2, 3, 4, 5, 6},
+		2: {3, 2, 1, 6, 5, 4},
+		3: {6, 5, 4, 3, 2, 1},
+		4: {4, 5, 6, 1, 2, 3},
+		5: {1, 4, 2, 5, 3, 6},
+		6: {4, 1, 5, 2, 6, 3},
+		7: {6, 3, 5, 2, 4, 1},
+		8: {3, 6, 2, 5, 1, 4},
+	}
+	// stored is the pixel of the file of the value
+	stored := func(v float64) (float64, float64) {
+		return float64(int(v-1) % 3), float64(int(v-1) / 3)
+	}
+	for o, want := range wants {
+		file := writeRaw(t, rawTIFF(3, 2, 2, 2, true, tiles, scale, tiepoint, rawTag{274, 3, []uint32{o}, nil}))
+		g, err := GeoTiff.OpenGeoTif(file)
+		if err != nil {
+			t.Fatalf("orientation %d: %v", o, err)
+		}
+		columns := uint(3)
+		if o >= 5 {
+			columns = 2
+		}
+		if g.Meta.Columns != columns || g.Meta.Rows != 6/columns {
+			t.Errorf("orientation %d: size %dx%d", o, g.Meta.Columns, g.Meta.Rows)
+		}
+		if !reflect.DeepEqual(g.Data.Data, want) {
+			t.Errorf("orientation %d: Data is %v, want %v", o, g.Data.Data, want)
+		}
+		// a pixel is at the same place as it is in the file
+		for i, v := range want {
+			col, row := float64(i%int(columns)), float64(i/int(columns))
+			x, y := g.Transform.PixelToGeo(col+0.5, row+0.5)
+			c, r := stored(v)
+			if math.Abs(x-(100+10*(c+0.5))) > 1e-9 || math.Abs(y-(200-10*(r+0.5))) > 1e-9 {
+				t.Errorf("orientation %d: pixel %d of %v is at %v %v", o, i, v, x, y)
+			}
+		}
+
+		// the header decodes the blocks as they are shown
+		h, err := GeoTiff.OpenGeoTifHeader(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, v := range want {
+			if s, err := h.Sample(i%int(columns), i/int(columns)); err != nil || s != v {
+				t.Errorf("orientation %d: sample %d is %v %v", o, i, s, err)
+			}
+		}
+		out := filepath.Join(t.TempDir(), "shown.tif")
+		f, err := os.Create(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w, err := GeoTiff.NewWriter(f, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = h.ProcessBlocks(2, nil, w); err != nil {
+			t.Fatalf("orientation %d: %v", o, err)
+		}
+		if err = w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+		h.Close()
+		written, err := GeoTiff.OpenGeoTif(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(written.Data.Data, want) || written.Transform.Data != g.Transform.Data {
+			t.Errorf("orientation %d: written %v %v", o, written.Data.Data, written.Transform.Data)
+		}
+	}
+}
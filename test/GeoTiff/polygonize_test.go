@@ -0,0 +1,93 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"math"
+	"testing"
+)
+
+func polygonArea(polygon [][][2]float64) float64 {
+	area := 0.0
+	for _, ring := range polygon {
+		for i := 0; i+1 < len(ring); i++ {
+			area += ring[i][0]*ring[i+1][1] - ring[i+1][0]*ring[i][1]
+		}
+	}
+	return area / 2
+}
+
+func TestPolygonize(t *testing.T) {
+	g := newRaster(8, GeoTiff.SampleFormatUint, 4, [6]float64{0, 1, 0, 4, 0, -1},
+		1, 1, 1, 3,
+		1, 2, 1, 0,
+		1, 1, 1, 0,
+		0, 0, 0, 3,
+	)
+	fc, err := g.Polygonize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	areas := map[float64]float64{}
+	holes := map[float64]int{}
+	for _, f := range fc.Features {
+		polygons, err := f.Geometry.Polygons()
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := f.Properties["value"].(float64)
+		areas[v] += polygonArea(polygons[0])
+		holes[v] += len(polygons[0]) - 1
+	}
+	if len(fc.Features) != 4 || areas[1] != 8 || holes[1] != 1 || areas[2] != 1 || areas[3] != 2 {
+		t.Errorf("4 connected: %d features, areas %v, holes %v", len(fc.Features), areas, holes)
+	}
+
+	g.Data.Data[15] = 2
+	g.Data.Data[10] = 0
+	if fc, err = g.Polygonize(GeoTiff.WithEightConnected(true), GeoTiff.WithPolygonizeField("class")); err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 4 {
+		t.Errorf("8 connected: require 4 features, but got %d", len(fc.Features))
+	}
+
+	mask := GeoTiff.NewGeoTifLike(g, 8, GeoTiff.SampleFormatUint)
+	mask.Data.Data[5] = 1
+	if fc, err = g.Polygonize(GeoTiff.WithPolygonizeMask(mask)); err != nil {
+		t.Fatal(err)
+	}
+	if len(fc.Features) != 1 || fc.Features[0].Properties["value"] != 2.0 {
+		t.Errorf("masked: %+v", fc.Features)
+	}
+	polygons, _ := fc.Features[0].Geometry.Polygons()
+	if math.Abs(polygonArea(polygons[0])-1) > 1e-9 {
+		t.Errorf("polygon is %v", polygons)
+	}
+}
+
+// the pixels touching by a corner are one polygon only with 8-connectivity
+func TestPolygonizeDiagonal(t *testing.T) {
+	g := newRaster(8, GeoTiff.SampleFormatUint, 2, [6]float64{0, 1, 0, 2, 0, -1},
+		5, 0,
+		0, 5,
+	)
+	for eight, want := range map[bool]int{false: 2, true: 1} {
+		fc, err := g.Polygonize(GeoTiff.WithEightConnected(eight))
+		if err != nil {
+			t.Fatal(err)
+		}
+		area := 0.0
+		for _, f := range fc.Features {
+			polygons, err := f.Geometry.Polygons()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, polygon := range polygons {
+				area += polygonArea(polygon)
+			}
+		}
+		if len(fc.Features) != want || math.Abs(area-2) > 1e-9 {
+			t.Errorf("8 connected %v: %d features of area %v, want %d of area 2", eight, len(fc.Features), area, want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package GeoTiff
+
+import "github.com/SunIBAS/gotool/GeoTiff"
+
+// newRaster is the raster of columns x len(values)/columns pixels on the transform in EPSG 32650,
+// the values are copied row by row and 0 is nodata, the tests change the CRS and nodata they need
+func newRaster(bitsPerSample, sampleFormat, columns uint, transform [6]float64, values ...float64) *GeoTiff.GeoTif {
+	g := GeoTiff.NewGeoTif(columns, uint(len(values))/columns, bitsPerSample, sampleFormat)
+	g.Transform.Data = transform
+	g.Meta.EPSGCode = 32650
+	g.Meta.NodataValue = "0"
+	copy(g.Data.Data, values)
+	return g
+}
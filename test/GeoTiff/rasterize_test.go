@@ -0,0 +1,64 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"testing"
+)
+
+func TestRasterize(t *testing.T) {
+	fc, err := GeoTiff.ParseGeoJSON([]byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"class":2},"geometry":{"type":"Polygon","coordinates":[[[0,0],[2,0],[2,2],[0,2],[0,0]]]}},
+		{"type":"Feature","properties":{"class":"3"},"geometry":{"type":"LineString","coordinates":[[0.5,3.5],[3.5,3.5]]}},
+		{"type":"Feature","properties":{"class":5},"geometry":{"type":"MultiPoint","coordinates":[[1.5,0.5],[1.6,0.6]]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := newZonalRaster()
+	g, err := GeoTiff.Rasterize(fc, template, 8, GeoTiff.SampleFormatUint, GeoTiff.WithBurnAttribute("class"), GeoTiff.WithMergeMode(GeoTiff.MergeAdd))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{
+		3, 3, 3, 3,
+		0, 0, 0, 0,
+		2, 2, 0, 0,
+		2, 7, 0, 0,
+	}
+	for i, v := range want {
+		if g.Data.Data[i] != v {
+			t.Fatalf("Data is %v, want %v", g.Data.Data, want)
+		}
+	}
+
+	if err = g.Burn(fc, GeoTiff.WithBurnValue(4), GeoTiff.WithMergeMode(GeoTiff.MergeMax)); err != nil {
+		t.Fatal(err)
+	}
+	if g.Data.Data[0] != 4 || g.Data.Data[13] != 7 || g.Data.Data[4] != 0 {
+		t.Errorf("Data is %v", g.Data.Data)
+	}
+
+	// the line to a point far away is clipped to the raster, the far point is not burned
+	far, err := GeoTiff.ParseGeoJSON([]byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{},"geometry":{"type":"LineString","coordinates":[[0.5,2.5],[1e300,2.5]]}},
+		{"type":"Feature","properties":{},"geometry":{"type":"LineString","coordinates":[[2.5,-1e9],[2.5,1e9]]}},
+		{"type":"Feature","properties":{},"geometry":{"type":"Point","coordinates":[1e300,0.5]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g, err = GeoTiff.Rasterize(far, template, 8, GeoTiff.SampleFormatUint); err != nil {
+		t.Fatal(err)
+	}
+	want = []float64{
+		0, 0, 1, 0,
+		1, 1, 1, 1,
+		0, 0, 1, 0,
+		0, 0, 1, 0,
+	}
+	for i, v := range want {
+		if g.Data.Data[i] != v {
+			t.Fatalf("far Data is %v, want %v", g.Data.Data, want)
+		}
+	}
+}
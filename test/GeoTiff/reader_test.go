@@ -0,0 +1,89 @@
+package GeoTiff
+
+import (
+	"bytes"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"io/fs"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+// streamFS hide the io.ReaderAt of the files, they can only be read in order
+type streamFS struct {
+	fs.FS
+}
+
+type streamFile struct {
+	f fs.File
+}
+
+func (sf streamFile) Stat() (fs.FileInfo, error) { return sf.f.Stat() }
+func (sf streamFile) Read(p []byte) (int, error) { return sf.f.Read(p) }
+func (sf streamFile) Close() error               { return sf.f.Close() }
+
+func (s streamFS) Open(name string) (fs.File, error) {
+	f, err := s.FS.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return streamFile{f}, nil
+}
+
+func TestOpenReaders(t *testing.T) {
+	file := newTiledFile(t)
+	want, err := GeoTiff.OpenGeoTif(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fsys := fstest.MapFS{"dir/tiled.tif": {Data: data}}
+	open := map[string]func() (*GeoTiff.GeoTif, error){
+		"bytes":  func() (*GeoTiff.GeoTif, error) { return GeoTiff.OpenBytes(data) },
+		"reader": func() (*GeoTiff.GeoTif, error) { return GeoTiff.OpenReader(bytes.NewReader(data), int64(len(data))) },
+		"fs":     func() (*GeoTiff.GeoTif, error) { return GeoTiff.OpenFS(fsys, "dir/tiled.tif") },
+		"stream": func() (*GeoTiff.GeoTif, error) { return GeoTiff.OpenFS(streamFS{fsys}, "dir/tiled.tif") },
+	}
+	for name, fn := range open {
+		g, err := fn()
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if !reflect.DeepEqual(g.Data.Data, want.Data.Data) || g.Meta.Columns != 5 || g.Transform.Data != want.Transform.Data {
+			t.Errorf("%s: got %v, want %v", name, g.Data.Data, want.Data.Data)
+		}
+	}
+	if _, err = GeoTiff.OpenFS(fsys, "dir"); err == nil {
+		t.Error("a directory is opened")
+	}
+	if _, err = GeoTiff.OpenBytes(data[:len(data)/2]); err == nil {
+		t.Error("the half of the file is opened")
+	}
+}
+
+func TestOpenBytesBlockCache(t *testing.T) {
+	cache := GeoTiff.NewBlockCache(1 << 20)
+	a, err := GeoTiff.OpenBytes(rawTIFF(3, 1, 3, 1, false, [][]byte{{1, 2, 3}}), GeoTiff.WithBlockCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := GeoTiff.OpenBytes(rawTIFF(3, 1, 3, 1, false, [][]byte{{7, 8, 9}}), GeoTiff.WithBlockCache(cache))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the two tifs have the same IFD offset, the blocks should not be shared
+	if va, _ := a.Sample(0, 0); va != 1 {
+		t.Errorf("a is %v", va)
+	}
+	if vb, _ := b.Sample(0, 0); vb != 7 {
+		t.Errorf("b is %v", vb)
+	}
+	if s := cache.Stats(); s.Blocks != 2 {
+		t.Errorf("%d blocks are cached", s.Blocks)
+	}
+}
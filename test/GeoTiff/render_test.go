@@ -0,0 +1,62 @@
+package GeoTiff
+
+import (
+	"bytes"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	g := newZonalRaster()
+	img, err := g.Render(GeoTiff.WithRange(1, 16))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.NRGBAAt(0, 0); c != (color.NRGBA{0, 0, 0, 255}) {
+		t.Errorf("min is %v", c)
+	}
+	if c := img.NRGBAAt(3, 3); c != (color.NRGBA{255, 255, 255, 255}) {
+		t.Errorf("max is %v", c)
+	}
+	if c := img.NRGBAAt(2, 2); c.A != 0 {
+		t.Errorf("nodata is %v", c)
+	}
+
+	img, err = g.Render(GeoTiff.WithStretch(GeoTiff.StretchHistogramEqualize), GeoTiff.WithColorRamp(GeoTiff.ColorRamps["viridis"]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.NRGBAAt(3, 3); c != GeoTiff.ColorRamps["viridis"][9].Color {
+		t.Errorf("max of histogram equalize is %v", c)
+	}
+
+	img, err = g.Render(GeoTiff.WithStretch(GeoTiff.StretchPercentile), GeoTiff.WithPercentile(10, 90))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := img.NRGBAAt(0, 0); c.R != 0 {
+		t.Errorf("low percentile is %v", c)
+	}
+
+	red := color.NRGBA{255, 0, 0, 255}
+	hillshade := GeoTiff.NewGeoTifLike(g, 8, GeoTiff.SampleFormatUint)
+	for i := range hillshade.Data.Data {
+		hillshade.Data.Data[i] = 127.5
+	}
+	var buf bytes.Buffer
+	if err = g.RenderPNG(&buf, GeoTiff.WithClassColors(map[float64]color.NRGBA{9: red}), GeoTiff.WithHillshadeBlend(hillshade, 1)); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := png.Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := color.NRGBAModel.Convert(decoded.At(0, 2)).(color.NRGBA); c != (color.NRGBA{127, 0, 0, 255}) {
+		t.Errorf("class color is %v", c)
+	}
+	if c := color.NRGBAModel.Convert(decoded.At(0, 0)).(color.NRGBA); c.A != 0 {
+		t.Errorf("the value out of the classes is %v", c)
+	}
+}
@@ -0,0 +1,221 @@
+package GeoTiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestTileServer(t *testing.T) {
+	s := GeoTiff.NewTileServer(GeoTiff.WithTileCacheSize(2))
+	if err := s.AddLayer("ndvi", newLonLatRaster(), GeoTiff.WithTileRender(GeoTiff.WithColorRamp(GeoTiff.ColorRamps["viridis"]))); err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	for _, path := range []string{"/ndvi/8/135/91.png", "/ndvi/8/135/91.png", "/ndvi/2/0/0.png"} {
+		rec := get(path)
+		if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("%s: %d %s", path, rec.Code, rec.Body.String())
+		}
+		img, err := png.Decode(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds().Dx() != 256 {
+			t.Errorf("%s is %v", path, img.Bounds())
+		}
+	}
+	for _, path := range []string{"/other/8/135/91.png", "/ndvi/8/256/0.png", "/ndvi/a/b/c.png", "/ndvi/nothing"} {
+		if rec := get(path); rec.Code != http.StatusNotFound {
+			t.Errorf("%s: %d", path, rec.Code)
+		}
+	}
+
+	var p GeoTiff.PointValue
+	if err := json.Unmarshal(get("/ndvi/point?lon=10.905&lat=45.5").Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Col != 90 || p.Row != 50 || p.Value == nil || *p.Value != 91 {
+		t.Errorf("point is %+v", p)
+	}
+	if err := json.Unmarshal(get("/ndvi/point?lon=10.1&lat=45.5").Body.Bytes(), &p); err != nil || !p.Nodata {
+		t.Errorf("nodata point is %+v, %v", p, err)
+	}
+	if rec := get("/ndvi/point?lon=20&lat=45.5"); rec.Code != http.StatusNotFound {
+		t.Errorf("point out of the raster: %d", rec.Code)
+	}
+
+	var m GeoTiff.LayerMetadata
+	if err := json.Unmarshal(get("/ndvi/metadata.json").Body.Bytes(), &m); err != nil {
+		t.Fatal(err)
+	}
+	if m.Columns != 100 || m.EPSG != 4326 || m.Nodata == nil || *m.Nodata != 0 || m.Bounds != [4]float64{10, 45, 11, 46} {
+		t.Errorf("metadata is %+v", m)
+	}
+
+	var names []string
+	if err := json.Unmarshal(get("/").Body.Bytes(), &names); err != nil || len(names) != 1 || names[0] != "ndvi" {
+		t.Errorf("layers are %v, %v", names, err)
+	}
+}
+
+// AddFile read only the strips under the tiles, replacing a layer close its tif and keep the tiles of the others
+func TestTileServerFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ndvi.tif")
+	if err := newLonLatRaster().Save(file, GeoTiff.WithRowsPerStrip(10)); err != nil {
+		t.Fatal(err)
+	}
+	style := GeoTiff.WithTileRender(GeoTiff.WithRange(0, 100))
+	cache := GeoTiff.NewBlockCache(1 << 20)
+	s := GeoTiff.NewTileServer(GeoTiff.WithServerBlockCache(cache))
+	memory := GeoTiff.NewTileServer()
+	if err := s.AddFile("file", file, style); err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.AddLayer("file", newLonLatRaster(), style); err != nil {
+		t.Fatal(err)
+	}
+	get := func(s *GeoTiff.TileServer, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	rec := get(s, "/file/12/2172/1465.png")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("%d %s", rec.Code, rec.Body.String())
+	}
+	if !bytes.Equal(rec.Body.Bytes(), get(memory, "/file/12/2172/1465.png").Body.Bytes()) {
+		t.Error("the tile of the file is not the tile of the raster in memory")
+	}
+	if stats := cache.Stats(); stats.Misses == 0 || stats.Misses > 2 {
+		t.Errorf("the tile read %d of the 10 strips", stats.Misses)
+	}
+	var p GeoTiff.PointValue
+	if err := json.Unmarshal(get(s, "/file/point?lon=10.905&lat=45.5").Body.Bytes(), &p); err != nil {
+		t.Fatal(err)
+	}
+	if p.Value == nil || *p.Value != 91 {
+		t.Errorf("point is %+v", p)
+	}
+
+	old, err := GeoTiff.OpenGeoTifHeader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = s.AddLayer("other", old, style); err != nil {
+		t.Fatal(err)
+	}
+	before := cache.Stats()
+	if err = s.AddLayer("other", newLonLatRaster(), style); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = old.Sample(0, 0); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("the replaced layer is not closed: %v", err)
+	}
+	// the tile of "file" is still in the tile cache, its strips are not looked up again
+	get(s, "/file/12/2172/1465.png")
+	if after := cache.Stats(); after.Hits+after.Misses != before.Hits+before.Misses {
+		t.Errorf("the tiles of the other layers are dropped, %+v -> %+v", before, after)
+	}
+	if err = s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(s, "/file/12/2172/1465.png"); rec.Code != http.StatusNotFound {
+		t.Errorf("the layer is served after Close: %d", rec.Code)
+	}
+}
+
+// replacing a layer while its tiles are served does not fail the requests, and the tiles of the old file are not cached for the new one
+func TestTileServerReplace(t *testing.T) {
+	dir := t.TempDir()
+	a, b := newLonLatRaster(), newLonLatRaster()
+	for i, v := range b.Data.Data {
+		if v != 0 {
+			b.Data.Data[i] = 101 - v
+		}
+	}
+	files := []string{filepath.Join(dir, "a.tif"), filepath.Join(dir, "b.tif")}
+	for i, g := range []*GeoTiff.GeoTif{a, b} {
+		if err := g.Save(files[i], GeoTiff.WithRowsPerStrip(10)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	style := GeoTiff.WithTileRender(GeoTiff.WithRange(0, 100))
+	const tile = "/layer/12/2172/1465.png"
+	var want [2][]byte
+	for i, g := range []*GeoTiff.GeoTif{a, b} {
+		memory := GeoTiff.NewTileServer()
+		if err := memory.AddLayer("layer", g, style); err != nil {
+			t.Fatal(err)
+		}
+		rec := httptest.NewRecorder()
+		memory.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tile, nil))
+		want[i] = rec.Body.Bytes()
+	}
+	if bytes.Equal(want[0], want[1]) {
+		t.Fatal("the tiles of the two files are the same")
+	}
+
+	s := GeoTiff.NewTileServer(GeoTiff.WithServerBlockCache(GeoTiff.NewBlockCache(1 << 20)))
+	if err := s.AddFile("layer", files[0], style); err != nil {
+		t.Fatal(err)
+	}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				for _, path := range []string{tile, "/layer/point?lon=10.905&lat=45.5", "/layer/metadata.json"} {
+					rec := httptest.NewRecorder()
+					s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+					if rec.Code != http.StatusOK {
+						t.Errorf("%s: %d %s", path, rec.Code, rec.Body.String())
+						return
+					}
+					if path == tile && !bytes.Equal(rec.Body.Bytes(), want[0]) && !bytes.Equal(rec.Body.Bytes(), want[1]) {
+						t.Errorf("%s is not the tile of a file", path)
+						return
+					}
+				}
+			}
+		}()
+	}
+	for i := 1; i <= 20; i++ {
+		if err := s.AddFile("layer", files[i%2], style); err != nil {
+			t.Error(err)
+		}
+	}
+	close(stop)
+	wg.Wait()
+
+	// the last file is a.tif
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tile, nil))
+	if !bytes.Equal(rec.Body.Bytes(), want[0]) {
+		t.Error("the tile of the old file is served after the layer is replaced")
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,70 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSparseTiles(t *testing.T) {
+	full := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	// the tiles 1 and 2 of the 8x2 image have no bytes
+	data := rawTIFF(8, 4, 4, 2, true, [][]byte{full, nil, nil, full},
+		rawTag{324, 4, []uint32{8, 0, 0, 16}, nil})
+	g, err := GeoTiff.OpenGeoTif(writeRaw(t, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []float64{
+		1, 2, 3, 4, 0, 0, 0, 0,
+		5, 6, 7, 8, 0, 0, 0, 0,
+		0, 0, 0, 0, 1, 2, 3, 4,
+		0, 0, 0, 0, 5, 6, 7, 8,
+	}
+	if !reflect.DeepEqual(g.Data.Data, want) {
+		t.Errorf("Data is %v", g.Data.Data)
+	}
+}
+
+func TestWriteSparse(t *testing.T) {
+	g := GeoTiff.NewGeoTif(4, 6, 16, GeoTiff.SampleFormatInt)
+	g.Transform.Data = [6]float64{100, 10, 0, 200, 0, -10}
+	g.Meta.NodataValue = "-9999"
+	for i := range g.Data.Data {
+		g.Data.Data[i] = float64(i)
+		if i >= 8 && i < 16 {
+			g.Data.Data[i] = -9999
+		}
+	}
+	dir := t.TempDir()
+	dense, sparse := filepath.Join(dir, "dense.tif"), filepath.Join(dir, "sparse.tif")
+	if err := g.Save(dense, GeoTiff.WithRowsPerStrip(2)); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.Save(sparse, GeoTiff.WithRowsPerStrip(2), GeoTiff.WithSparse(true)); err != nil {
+		t.Fatal(err)
+	}
+	denseInfo, err := os.Stat(dense)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sparseInfo, err := os.Stat(sparse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sparseInfo.Size() != denseInfo.Size()-16 {
+		t.Errorf("the empty strip is written, %d and %d bytes", sparseInfo.Size(), denseInfo.Size())
+	}
+	geo, err := GeoTiff.OpenGeoTif(sparse)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(geo.Data.Data, g.Data.Data) {
+		t.Errorf("Data is %v", geo.Data.Data)
+	}
+	if v, err := geo.Sample(1, 2); err != nil || v != -9999 {
+		t.Errorf("sample %v %v", v, err)
+	}
+}
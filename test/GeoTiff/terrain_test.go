@@ -0,0 +1,75 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"math"
+	"testing"
+)
+
+// a plane rising to the east by 1 meter per 10 meters
+func newPlaneRaster() *GeoTiff.GeoTif {
+	values := make([]float64, 16)
+	for i := range values {
+		values[i] = float64(i % 4)
+	}
+	g := newRaster(32, GeoTiff.SampleFormatFloat, 4, [6]float64{0, 10, 0, 40, 0, -10}, values...)
+	g.Meta.NodataValue = "-9999"
+	return g
+}
+
+func TestTerrain(t *testing.T) {
+	g := newPlaneRaster()
+	must := func(out *GeoTiff.GeoTif, err error) *GeoTiff.GeoTif {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return out
+	}
+	for _, algorithm := range []GeoTiff.TerrainAlgorithm{GeoTiff.Horn, GeoTiff.ZevenbergenThorne} {
+		slope := must(g.Slope(GeoTiff.WithTerrainAlgorithm(algorithm)))
+		if v := slope.Data.Data[5]; math.Abs(v-math.Atan(0.1)*180/math.Pi) > 1e-9 {
+			t.Errorf("slope is %v", v)
+		}
+		if slope.Data.Data[0] != -9999 {
+			t.Errorf("border should be nodata, but %v", slope.Data.Data[0])
+		}
+	}
+	if v := must(g.Slope(GeoTiff.WithSlopePercent(true))).Data.Data[6]; math.Abs(v-10) > 1e-9 {
+		t.Errorf("slope percent is %v", v)
+	}
+	// facing west
+	if v := must(g.Aspect()).Data.Data[5]; math.Abs(v-270) > 1e-9 {
+		t.Errorf("aspect is %v", v)
+	}
+	// lit from the west it is brighter than from the east
+	west := must(g.Hillshade(GeoTiff.WithSun(270, 45))).Data.Data[5]
+	east := must(g.Hillshade(GeoTiff.WithSun(90, 45))).Data.Data[5]
+	if west <= east {
+		t.Errorf("hillshade west %v, east %v", west, east)
+	}
+	if v := must(g.Roughness()).Data.Data[5]; v != 2 {
+		t.Errorf("roughness is %v", v)
+	}
+	if v := must(g.TPI()).Data.Data[5]; math.Abs(v) > 1e-9 {
+		t.Errorf("TPI is %v", v)
+	}
+
+	g.Data.Data[0] = -9999
+	if v := must(g.Slope()).Data.Data[5]; v != -9999 {
+		t.Errorf("nodata is not propagated, %v", v)
+	}
+
+	// 0.0001 degree at the equator is about 11 meters
+	g.Transform.Data = [6]float64{0, 0.0001, 0, 0.0002, 0, -0.0001}
+	g.Meta.EPSGCode = 4326
+	if v := must(g.Slope(GeoTiff.WithSlopePercent(true))).Data.Data[6]; math.Abs(v-100/11.13) > 0.01 {
+		t.Errorf("geographic slope percent is %v", v)
+	}
+
+	// the pixels which are not in Data is an error, not a raster of nodata
+	g.Data.Data = g.Data.Data[:3]
+	if _, err := g.Hillshade(); err == nil {
+		t.Error("the hillshade of a raster without pixels has no error")
+	}
+}
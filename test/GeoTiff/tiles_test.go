@@ -0,0 +1,151 @@
+package GeoTiff
+
+import (
+	"archive/zip"
+	"errors"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// newLonLatRaster cover lon 10~11, lat 45~46 with 100x100 pixels, the left half is nodata
+func newLonLatRaster() *GeoTiff.GeoTif {
+	values := make([]float64, 100*100)
+	for i := range values {
+		if i%100 >= 50 {
+			values[i] = float64(i%100 + 1)
+		}
+	}
+	g := newRaster(8, GeoTiff.SampleFormatUint, 100, [6]float64{10, 0.01, 0, 46, 0, -0.01}, values...)
+	g.Meta.EPSGCode = 4326
+	return g
+}
+
+func TestTiler(t *testing.T) {
+	tiler, err := GeoTiff.NewTiler(newLonLatRaster(), GeoTiff.WithZoomRange(8, 9), GeoTiff.WithTileWorkers(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x0, y0, x1, y1 := tiler.TileRange(8)
+	// lon 10~11 is x 135~136 at zoom 8, lat 45~46 is y 91~92
+	if x0 != 135 || x1 != 136 || y0 != 91 || y1 != 93 {
+		t.Errorf("tile range of zoom 8 is [%d, %d) x [%d, %d)", x0, x1, y0, y1)
+	}
+	img, ok, err := tiler.Tile(8, 135, 91)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("tile 8/135/91 is empty")
+	}
+	// lon 10.1 is nodata and lon 10.9 is valid, lat 45.5 is in the middle of the tile
+	b := GeoTiff.TileBounds(8, 135, 91)
+	px := func(lon float64) int {
+		return int((lon*math.Pi/180*6378137 - b.MinX) / b.Width() * 256)
+	}
+	row := int((b.MaxY - math.Log(math.Tan(math.Pi/4+45.5*math.Pi/360))*6378137) / b.Height() * 256)
+	if c := img.NRGBAAt(px(10.1), row); c.A != 0 {
+		t.Errorf("nodata is %v", c)
+	}
+	if c := img.NRGBAAt(px(10.9), row); c.A != 255 || c.R == 0 {
+		t.Errorf("valid pixel is %v", c)
+	}
+
+	dir := t.TempDir()
+	if err = tiler.WriteDir(dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(filepath.Join(dir, "8", "135", "91.png")); err != nil {
+		t.Error(err)
+	}
+
+	tms, err := GeoTiff.NewTiler(newLonLatRaster(), GeoTiff.WithZoomRange(8, 8), GeoTiff.WithTMS(true), GeoTiff.WithTileSize(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the zip which exists keeps its entries, the tiles written again are not duplicated
+	file := filepath.Join(dir, "tiles.zip")
+	zf, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(zf)
+	if w, err := zw.Create("readme.txt"); err != nil {
+		t.Fatal(err)
+	} else {
+		w.Write([]byte("tiles"))
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	zf.Close()
+	for i := 0; i < 2; i++ {
+		if err = tms.WriteZip(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := zip.OpenReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	var names []string
+	for _, f := range r.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	// TMS y is 255 - y
+	if strings.Join(names, ",") != "8/135/163.png,8/135/164.png,readme.txt" {
+		t.Errorf("zip has %v", names)
+	}
+}
+
+func TestTilerProjection(t *testing.T) {
+	// lon 15, lat 45 is E 500000, N 4982950.4 in UTM zone 33N
+	g := GeoTiff.NewGeoTif(10, 10, 8, GeoTiff.SampleFormatUint)
+	g.Transform.Data = [6]float64{500000, 100, 0, 4982950.4, 0, -100}
+	g.Meta.EPSGCode = 32633
+	tiler, err := GeoTiff.NewTiler(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := tiler.Bounds()
+	if math.Abs(b.MinX-1669792.36) > 0.5 || math.Abs(b.MaxY-5621521.49) > 0.5 {
+		t.Errorf("bounds is %+v", b)
+	}
+	if min, max := tiler.ZoomRange(); min > max || max != 11 {
+		t.Errorf("zoom range is %d ~ %d", min, max)
+	}
+
+	g.Meta.EPSGCode = 2000
+	if _, err = GeoTiff.NewTiler(g); err == nil {
+		t.Error("unknown CRS is accepted")
+	}
+}
+
+// the tile whose pixels can not be read is an error, not a transparent tile
+func TestTilerReadError(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "ndvi.tif")
+	if err := newLonLatRaster().Save(file); err != nil {
+		t.Fatal(err)
+	}
+	g, err := GeoTiff.OpenGeoTifHeader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tiler, err := GeoTiff.NewTiler(g, GeoTiff.WithTileRender(GeoTiff.WithRange(0, 100)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.Close()
+	if _, _, err = tiler.Tile(8, 135, 91); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("Tile of a closed tif: %v", err)
+	}
+	if _, err = tiler.TilePNG(8, 135, 91); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("TilePNG of a closed tif: %v", err)
+	}
+}
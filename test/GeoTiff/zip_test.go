@@ -0,0 +1,65 @@
+package GeoTiff
+
+import (
+	"archive/zip"
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOpenZip(t *testing.T) {
+	tiled := newTiledFile(t)
+	data, err := os.ReadFile(tiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := GeoTiff.OpenGeoTif(tiled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(t.TempDir(), "rasters.ZIP")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, method := range map[string]uint16{"stored.tif": zip.Store, "2020/deflated.tif": zip.Deflate} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	cache := GeoTiff.NewBlockCache(1 << 20)
+	for _, name := range []string{"stored.tif", "2020/deflated.tif"} {
+		g, err := GeoTiff.OpenZip(file, name, GeoTiff.WithBlockCache(cache))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(g.Data.Data, want.Data.Data) {
+			t.Errorf("%s: got %v, want %v", name, g.Data.Data, want.Data.Data)
+		}
+		h, err := GeoTiff.OpenGeoTifHeader(GeoTiff.VSIZipPath(file, name))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if v, err := h.Sample(4, 2); err != nil || v != 15 {
+			t.Errorf("%s: sample %v %v", name, v, err)
+		}
+	}
+	if s := cache.Stats(); s.Blocks != 8 {
+		t.Errorf("the entries should be cached apart, %d blocks", s.Blocks)
+	}
+	if _, err = GeoTiff.OpenZip(file, "missing.tif"); err == nil {
+		t.Error("missing.tif is opened")
+	}
+}
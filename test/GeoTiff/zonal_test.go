@@ -0,0 +1,49 @@
+package GeoTiff
+
+import (
+	"github.com/SunIBAS/gotool/GeoTiff"
+	"testing"
+)
+
+// 4x4 raster, the pixel size is 1 and the origin is (0, 4)
+func newZonalRaster() *GeoTiff.GeoTif {
+	g := newRaster(8, GeoTiff.SampleFormatUint, 4, [6]float64{0, 1, 0, 4, 0, -1},
+		1, 2, 3, 4,
+		5, 6, 7, 8,
+		9, 9, 255, 12,
+		13, 14, 15, 16,
+	)
+	g.Meta.NodataValue = "255"
+	return g
+}
+
+func TestZonalStatistics(t *testing.T) {
+	fc, err := GeoTiff.ParseGeoJSON([]byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","properties":{"code":"a"},"geometry":{"type":"Polygon","coordinates":[[[0,0],[2,0],[2,2],[0,2],[0,0]]]}},
+		{"type":"Feature","properties":{"code":"b"},"geometry":{"type":"Polygon","coordinates":[[[1.6,1.6],[2.4,1.6],[2.4,2.4],[1.6,2.4],[1.6,1.6]]]}}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newZonalRaster()
+	stats, err := GeoTiff.ZonalStatistics(fc, g, GeoTiff.WithZonalKey("code"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := stats["a"]
+	if a.Count != 4 || a.Sum != 45 || a.Min != 9 || a.Max != 14 || a.Majority != 9 {
+		t.Errorf("a is %+v", a)
+	}
+	if b := stats["b"]; b.Count != 0 {
+		t.Errorf("center mode b is %+v", b)
+	}
+
+	stats, err = GeoTiff.ZonalStatistics(fc, g, GeoTiff.WithZonalKey("code"), GeoTiff.WithZonalAllTouched(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// touches 6, 7, 9, and the nodata pixel
+	if b := stats["b"]; b.Count != 3 || b.Sum != 22 {
+		t.Errorf("all touched b is %+v", b)
+	}
+}
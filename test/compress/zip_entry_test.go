@@ -0,0 +1,85 @@
+package compress
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/SunIBAS/gotool/compress"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// writeTestZip write the content as a.bin (stored) and dir/b.bin (deflated)
+func writeTestZip(t *testing.T, content []byte) string {
+	file := filepath.Join(t.TempDir(), "entries.zip")
+	f, err := os.Create(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, method := range map[string]uint16{"a.bin": zip.Store, "dir/b.bin": zip.Deflate} {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestOpenZipEntry(t *testing.T) {
+	content := make([]byte, 100000)
+	for i := range content {
+		content[i] = byte(i * 7)
+	}
+	file := writeTestZip(t, content)
+	for name, stored := range map[string]bool{"a.bin": true, "dir/b.bin": false, "/dir\\b.bin": false} {
+		entry, err := compress.OpenZipEntry(file, name)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if entry.Stored != stored || entry.Size != int64(len(content)) {
+			t.Errorf("%s: stored %v size %d", name, entry.Stored, entry.Size)
+		}
+		// read the end first, then the parts by many goroutines
+		tail := make([]byte, 10)
+		if _, err = entry.ReadAt(tail, int64(len(content)-10)); err != nil || !bytes.Equal(tail, content[len(content)-10:]) {
+			t.Errorf("%s: tail %v %v", name, tail, err)
+		}
+		var wg sync.WaitGroup
+		for k := 0; k < 8; k++ {
+			wg.Add(1)
+			go func(k int) {
+				defer wg.Done()
+				p := make([]byte, 1000)
+				off := int64(k * 12345)
+				if _, err := entry.ReadAt(p, off); err != nil || !bytes.Equal(p, content[off:off+1000]) {
+					t.Errorf("%s: part %d %v", name, k, err)
+				}
+			}(k)
+		}
+		wg.Wait()
+		if n, err := entry.ReadAt(make([]byte, 20), int64(len(content)-10)); n != 10 || err != io.EOF {
+			t.Errorf("%s: read after the end %d %v", name, n, err)
+		}
+		if err = entry.Close(); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := compress.OpenZipEntry(file, "c.bin"); err == nil {
+		t.Error("c.bin is opened")
+	}
+	if _, err := compress.OpenZipEntry(file, "dir"); err == nil {
+		t.Error("dir is opened")
+	}
+}
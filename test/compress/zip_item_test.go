@@ -7,6 +7,7 @@ import (
 	"github.com/google/uuid"
 	"io"
 	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -130,3 +131,79 @@ func TestReadZipFiles(t *testing.T) {
 	}
 
 }
+
+// Compress on a zip which exists rewrite it at ZipFilePath and keep its entries
+func TestZipAppend(t *testing.T) {
+	zipFilePath := filepath.Join(t.TempDir(), "append.zip")
+	for _, name := range []string{"a.txt", "b.txt"} {
+		cp := compress.Zip{
+			ZipFilePath: zipFilePath,
+			ZipItem:     []compress.ZipItem{compress.NewZipBytesItem(name, []byte(name))},
+		}
+		if err := cp.Compress(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := zip.OpenReader(zipFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.File) != 2 || r.File[0].Name != "a.txt" || r.File[1].Name != "b.txt" {
+		t.Fatalf("zip has %d files", len(r.File))
+	}
+	rc, err := r.File[1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if content, err := io.ReadAll(rc); err != nil || string(content) != "b.txt" {
+		t.Errorf("b.txt is %q, %v", content, err)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(zipFilePath)); len(entries) != 1 {
+		t.Errorf("the directory has %d files", len(entries))
+	}
+}
+
+// Create and Add write the items one by one, Keep drop the entry which is replaced
+func TestZipCreate(t *testing.T) {
+	zipFilePath := filepath.Join(t.TempDir(), "create.zip")
+	cp := compress.Zip{
+		ZipFilePath: zipFilePath,
+		ZipItem:     []compress.ZipItem{compress.NewZipBytesItem("a.txt", []byte("old")), compress.NewZipBytesItem("b.txt", []byte("b"))},
+	}
+	if err := cp.Compress(); err != nil {
+		t.Fatal(err)
+	}
+	z := compress.Zip{
+		ZipFilePath: zipFilePath,
+		Keep: func(name string) bool {
+			return name != "a.txt"
+		},
+	}
+	if err := z.Create(); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Add(compress.NewZipBytesItem("a.txt", []byte("new"))); err != nil {
+		t.Fatal(err)
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err := zip.OpenReader(zipFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	if len(r.File) != 2 || r.File[0].Name != "b.txt" || r.File[1].Name != "a.txt" {
+		t.Fatalf("zip has %d files", len(r.File))
+	}
+	rc, err := r.File[1].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	if content, err := io.ReadAll(rc); err != nil || string(content) != "new" {
+		t.Errorf("a.txt is %q, %v", content, err)
+	}
+}